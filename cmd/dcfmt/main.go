@@ -0,0 +1,108 @@
+// Command dcfmt formats dclass files in canonical form.  Only the layout of a file is changed:
+// its declarations, comments, and the names of constants are kept.
+//
+// Usage:
+//
+//	dcfmt [-w | -check] [file ...]
+//
+// Without files, dcfmt formats its standard input.  By default the formatted source is written
+// to standard output.  With -w the files are rewritten in place, and with -check the names of
+// files which are not formatted are printed and dcfmt exits with status 1.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+var (
+	write = flag.Bool("w", false, "write the result to the source file instead of standard output")
+	check = flag.Bool("check", false, "list files whose formatting differs and exit with status 1")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: dcfmt [-w | -check] [file ...]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *write && *check {
+		usage()
+	}
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "dcfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = format("<stdin>", src, nil)
+		}
+		report(err)
+		os.Exit(exitCode)
+	}
+
+	for _, filename := range flag.Args() {
+		src, err := os.ReadFile(filename)
+		if err == nil {
+			err = format(filename, src, func(out []byte) error {
+				return os.WriteFile(filename, out, 0644)
+			})
+		}
+		report(err)
+	}
+	os.Exit(exitCode)
+}
+
+// errUnformatted is returned by format in -check mode if the source is not formatted.
+var errUnformatted = fmt.Errorf("not formatted")
+
+// format formats src and writes the result according to the flags.  The rewrite function is
+// used by -w to replace the file's contents.
+func format(filename string, src []byte, rewrite func([]byte) error) error {
+	out, err := dclass.Format(src)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	switch {
+	case *check:
+		if !bytes.Equal(src, out) {
+			fmt.Println(filename)
+			return errUnformatted
+		}
+	case *write:
+		if !bytes.Equal(src, out) {
+			return rewrite(out)
+		}
+	default:
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+// exitCode is 1 if any file was not formatted, or 2 if any file could not be formatted.
+var exitCode = 0
+
+// report updates the exit code for the result of formatting a file, printing any error.
+func report(err error) {
+	switch {
+	case err == nil:
+	case err == errUnformatted:
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+	}
+}
@@ -0,0 +1,657 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// A generator writes the Go bindings for a dclass File.
+type generator struct {
+	buf      bytes.Buffer
+	dcf      *dclass.File
+	argTypes map[string]string // the field whose arguments are stored by each generated type
+}
+
+// generate returns the gofmt-formatted Go source of the bindings for the file.
+// The source comment names the files the bindings were generated from.
+func generate(dcf *dclass.File, pkg, source string) ([]byte, error) {
+	// The types of the runtime are named like the types storing arguments
+	g := &generator{dcf: dcf, argTypes: map[string]string{"dcWriter": "", "dcReader": ""}}
+	g.printf("// Code generated by dcgen from %s; DO NOT EDIT.\n\n", source)
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\"encoding/binary\"\n\"fmt\"\n\"math\"\n)\n\n")
+	g.printf("// DCHash is the hash of the dclass file the bindings were generated from.\n")
+	g.printf("const DCHash = %d\n\n", dcf.Hash())
+
+	g.genIDs()
+	for _, e := range dcf.Enums {
+		g.genEnum(e)
+	}
+	for _, typ := range dcf.Classes {
+		var err error
+		switch typ := typ.(type) {
+		case *dclass.Struct:
+			err = g.genStruct(typ)
+		case *dclass.Class:
+			err = g.genClass(typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	g.printf("%s", runtime)
+
+	out, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %v", err)
+	}
+	return out, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// doc writes the comments attached to a declaration as lines of a doc comment, separated by an
+// empty line from the lines already written.
+func (g *generator) doc(text string, separate bool) {
+	if text == "" {
+		return
+	}
+	if separate {
+		g.printf("//\n")
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			g.printf("//\n")
+		} else {
+			g.printf("// %s\n", line)
+		}
+	}
+}
+
+// genIDs writes the constants for the number of each dclass and of each field declared by a dclass.
+func (g *generator) genIDs() {
+	g.printf("// Class IDs\nconst (\n")
+	for _, typ := range g.dcf.Classes {
+		if c, ok := typ.(*dclass.Class); ok {
+			g.printf("%s = %d\n", classID(c), c.Index())
+		}
+	}
+	g.printf(")\n\n// Field IDs\nconst (\n")
+	for _, typ := range g.dcf.Classes {
+		if c, ok := typ.(*dclass.Class); ok {
+			for _, f := range c.Fields() {
+				g.printf("%s = %d\n", fieldID(c, f), f.Number())
+			}
+		}
+	}
+	g.printf(")\n\n")
+}
+
+func classID(c *dclass.Class) string {
+	return "Class" + exported(c.Name())
+}
+
+func fieldID(c *dclass.Class, f dclass.Field) string {
+	return "Field" + exported(c.Name()) + exported(f.Name())
+}
+
+// genEnum writes a named integer type with a constant for each value of the enum.
+func (g *generator) genEnum(e *dclass.Enum) {
+	name := exported(e.Name())
+	g.printf("// %s is the enum %s.\ntype %s %s\n\n", name, e.Name(), name, e.DataType())
+	g.printf("const (\n")
+	for _, v := range e.Values() {
+		g.printf("%s%s %s = %s\n", name, exported(v.Name), name, v.Value)
+	}
+	g.printf(")\n\n")
+
+	g.printf("// IsValid returns whether v is one of the values of the enum.\n")
+	g.printf("func (v %s) IsValid() bool {\nswitch v {\n", name)
+	seen := make(map[string]bool) // aliased values are listed once, by their first name
+	for _, v := range e.Values() {
+		if seen[v.Value.String()] {
+			continue
+		}
+		if len(seen) > 0 {
+			g.printf(", ")
+		} else {
+			g.printf("case ")
+		}
+		seen[v.Value.String()] = true
+		g.printf("%s%s", name, exported(v.Name))
+	}
+	if len(seen) > 0 {
+		g.printf(":\nreturn true\n")
+	}
+	g.printf("}\nreturn false\n}\n\n")
+}
+
+// genStruct writes a Go struct with a field for each member of the dclass struct, and the
+// functions to pack and unpack it.
+func (g *generator) genStruct(s *dclass.Struct) error {
+	name := exported(s.Name())
+	members := map[string]string{"Pack": "", "Unpack": ""}
+	for _, f := range s.Fields() {
+		member := exported(f.Name())
+		if other, ok := members[member]; ok && other == "" {
+			return fmt.Errorf("struct %s: field %s has the name of the generated method %s",
+				s.Name(), f.Name(), member)
+		} else if ok {
+			return fmt.Errorf("struct %s: fields %s and %s both generate field %s",
+				s.Name(), other, f.Name(), member)
+		}
+		members[member] = f.Name()
+	}
+
+	g.printf("// %s is the struct %s.\n", name, s.Name())
+	g.doc(s.Doc(), true)
+	g.printf("type %s struct {\n", name)
+	for _, f := range s.Fields() {
+		g.doc(f.Doc(), false)
+		g.printf("%s %s\n", exported(f.Name()), goType(f.(*dclass.Parameter)))
+	}
+	g.printf("}\n\n")
+
+	g.printf("// Pack appends the packed value of the struct to b.\n")
+	g.printf("func (v *%s) Pack(b []byte) ([]byte, error) {\nw := &dcWriter{buf: b}\nv.pack(w)\nreturn w.buf, w.err\n}\n\n", name)
+	g.printf("// Unpack sets the struct to the packed value in data.\n")
+	g.printf("func (v *%s) Unpack(data []byte) error {\nr := &dcReader{data: data}\nv.unpack(r)\nreturn r.done()\n}\n\n", name)
+
+	g.printf("func (v *%s) pack(w *dcWriter) {\n", name)
+	for _, f := range s.Fields() {
+		g.packParameter(f.(*dclass.Parameter), "v."+exported(f.Name()), s.Name()+"."+f.Name())
+	}
+	g.printf("}\n\n")
+	g.printf("func (v *%s) unpack(r *dcReader) {\n", name)
+	for _, f := range s.Fields() {
+		g.unpackParameter(f.(*dclass.Parameter), "v."+exported(f.Name()))
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// A classField is a field of a dclass, including inherited fields, and the names used for it
+// in the generated code.
+type classField struct {
+	field   dclass.Field
+	storage string // name of the struct field storing the value
+	args    string // name of the type storing the arguments of an atomic field
+	setter  string
+	getter  string
+}
+
+// genClass writes a Go struct with the values of every field of the dclass, the setters and
+// getters for each field, and the functions to pack and unpack the fields by their ID.
+func (g *generator) genClass(c *dclass.Class) error {
+	name := exported(c.Name())
+	var fields []classField
+	byName := make(map[string]*classField)
+	methods := map[string]string{"PackField": "", "UnpackField": ""}
+	for _, f := range c.InheritedFields() {
+		cf := classField{field: f, storage: unexported(f.Name())}
+		if _, ok := f.(*dclass.MolecularField); !ok {
+			base := exported(strings.TrimPrefix(f.Name(), "set"))
+			if !strings.HasPrefix(f.Name(), "set") || base == "" || !unicode.IsUpper(rune(base[0])) {
+				base = exported(f.Name())
+			}
+			cf.setter, cf.getter = "Set"+base, "Get"+base
+			for _, method := range []string{cf.setter, cf.getter} {
+				if other, ok := methods[method]; ok {
+					return fmt.Errorf("dclass %s: fields %s and %s both generate method %s",
+						c.Name(), other, f.Name(), method)
+				}
+				methods[method] = f.Name()
+			}
+		}
+		if _, ok := f.(*dclass.AtomicField); ok {
+			cf.args = unexported(c.Name()) + exported(f.Name())
+			if other, ok := g.argTypes[cf.args]; ok && other == "" {
+				return fmt.Errorf("dclass %s: the arguments of field %s generate type %s of the runtime",
+					c.Name(), f.Name(), cf.args)
+			} else if ok {
+				return fmt.Errorf("dclass %s: the arguments of fields %s and %s.%s both generate type %s",
+					c.Name(), other, c.Name(), f.Name(), cf.args)
+			}
+			g.argTypes[cf.args] = c.Name() + "." + f.Name()
+		}
+		fields = append(fields, cf)
+	}
+	for i := range fields {
+		byName[fields[i].field.Name()] = &fields[i]
+	}
+
+	// Struct with the value of each field
+	g.printf("// %s is the dclass %s.\n", name, c.Name())
+	g.doc(c.Doc(), true)
+	g.printf("type %s struct {\n", name)
+	for _, cf := range fields {
+		switch f := cf.field.(type) {
+		case *dclass.Parameter:
+			g.printf("%s %s\n", cf.storage, goType(f))
+		case *dclass.AtomicField:
+			g.printf("%s %s\n", cf.storage, cf.args)
+		}
+	}
+	g.printf("}\n\n")
+
+	// Constructor applying the default values
+	g.printf("// New%s returns a new %s with the default value of each field.\n", name, name)
+	g.printf("func New%s() *%s {\no := &%s{}\n", name, name, name)
+	for _, cf := range fields {
+		if _, ok := cf.field.(*dclass.MolecularField); ok {
+			continue
+		}
+		def := cf.field.DefaultValue()
+		if cf.field.HasDefaultValue() || bytes.Count(def.Bytes(), []byte{0}) != def.Len() {
+			g.printf("o.UnpackField(%d, %s)\n", cf.field.Number(), byteSlice(def.Bytes()))
+		}
+	}
+	g.printf("return o\n}\n\n")
+
+	// Setters and getters
+	for _, cf := range fields {
+		switch f := cf.field.(type) {
+		case *dclass.Parameter:
+			g.printf("// %s sets the value of the field %s.\n", cf.setter, f.Name())
+			g.doc(f.Doc(), true)
+			g.printf("func (o *%s) %s(v %s) {\no.%s = v\n}\n\n", name, cf.setter, goType(f), cf.storage)
+			g.printf("// %s returns the value of the field %s.\n", cf.getter, f.Name())
+			g.printf("func (o *%s) %s() %s {\nreturn o.%s\n}\n\n", name, cf.getter, goType(f), cf.storage)
+		case *dclass.AtomicField:
+			var params, results, names []string
+			for i, arg := range f.NestedFields() {
+				argName := argName(arg, i)
+				params = append(params, argName+" "+goType(arg.(*dclass.Parameter)))
+				results = append(results, goType(arg.(*dclass.Parameter)))
+				names = append(names, argName)
+			}
+			resultList := strings.Join(results, ", ")
+			if len(results) > 1 {
+				resultList = "(" + resultList + ")"
+			}
+
+			g.printf("// %s sets the arguments of the field %s.\n", cf.setter, f.Name())
+			g.doc(f.Doc(), true)
+			g.printf("func (o *%s) %s(%s) {\n", name, cf.setter, strings.Join(params, ", "))
+			if len(names) > 0 {
+				g.printf("o.%s = %s{%s}\n", cf.storage, cf.args, strings.Join(names, ", "))
+			}
+			g.printf("}\n\n")
+			g.printf("// %s returns the arguments of the field %s.\n", cf.getter, f.Name())
+			g.printf("func (o *%s) %s() %s {\n", name, cf.getter, resultList)
+			if len(names) > 0 {
+				var values []string
+				for i, arg := range f.NestedFields() {
+					values = append(values, "o."+cf.storage+"."+argField(arg, i))
+				}
+				g.printf("return %s\n", strings.Join(values, ", "))
+			}
+			g.printf("}\n\n")
+		}
+	}
+
+	// Pack and unpack by field ID
+	g.printf("// PackField appends the packed value of the field with the given ID to b.\n")
+	g.printf("func (o *%s) PackField(id int, b []byte) ([]byte, error) {\nw := &dcWriter{buf: b}\nswitch id {\n", name)
+	for _, cf := range fields {
+		g.printf("case %d: // %s\n", cf.field.Number(), cf.field.Name())
+		if err := g.packField(c, cf, byName); err != nil {
+			return err
+		}
+	}
+	g.printf("default:\nreturn b, fmt.Errorf(\"dclass %s has no field %%d\", id)\n}\nreturn w.buf, w.err\n}\n\n", c.Name())
+
+	g.printf("// UnpackField sets the field with the given ID to the packed value in data.\n")
+	g.printf("func (o *%s) UnpackField(id int, data []byte) error {\nr := &dcReader{data: data}\nswitch id {\n", name)
+	for _, cf := range fields {
+		g.printf("case %d: // %s\n", cf.field.Number(), cf.field.Name())
+		if err := g.unpackField(c, cf, byName); err != nil {
+			return err
+		}
+	}
+	g.printf("default:\nreturn fmt.Errorf(\"dclass %s has no field %%d\", id)\n}\nreturn r.done()\n}\n\n", c.Name())
+
+	// Pack and unpack the arguments of each atomic field
+	for _, cf := range fields {
+		if cf.args == "" {
+			continue
+		}
+		args := cf.field.NestedFields()
+		g.printf("type %s struct {\n", cf.args)
+		for i, arg := range args {
+			g.printf("%s %s\n", argField(arg, i), goType(arg.(*dclass.Parameter)))
+		}
+		g.printf("}\n\n")
+
+		g.printf("func (a *%s) pack(w *dcWriter) {\n", cf.args)
+		for i, arg := range args {
+			g.packParameter(arg.(*dclass.Parameter), "a."+argField(arg, i), c.Name()+"."+cf.field.Name()+"."+argField(arg, i))
+		}
+		g.printf("}\n\n")
+		g.printf("func (a *%s) unpack(r *dcReader) {\n", cf.args)
+		for i, arg := range args {
+			g.unpackParameter(arg.(*dclass.Parameter), "a."+argField(arg, i))
+		}
+		g.printf("}\n\n")
+	}
+	return nil
+}
+
+// packField writes the statements packing a field of a class.  Molecular fields pack each
+// of their components.
+func (g *generator) packField(c *dclass.Class, cf classField, byName map[string]*classField) error {
+	switch f := cf.field.(type) {
+	case *dclass.Parameter:
+		g.packParameter(f, "o."+cf.storage, c.Name()+"."+f.Name())
+	case *dclass.AtomicField:
+		g.printf("o.%s.pack(w)\n", cf.storage)
+	case *dclass.MolecularField:
+		for _, component := range f.NestedFields() {
+			other, ok := byName[component.Name()]
+			if !ok || other.field != component {
+				return fmt.Errorf("dclass %s: component %s of %s is overridden", c.Name(), component.Name(), f.Name())
+			}
+			if err := g.packField(c, *other, byName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unpackField writes the statements unpacking a field of a class.
+func (g *generator) unpackField(c *dclass.Class, cf classField, byName map[string]*classField) error {
+	switch f := cf.field.(type) {
+	case *dclass.Parameter:
+		g.unpackParameter(f, "o."+cf.storage)
+	case *dclass.AtomicField:
+		g.printf("o.%s.unpack(r)\n", cf.storage)
+	case *dclass.MolecularField:
+		for _, component := range f.NestedFields() {
+			other, ok := byName[component.Name()]
+			if !ok || other.field != component {
+				return fmt.Errorf("dclass %s: component %s of %s is overridden", c.Name(), component.Name(), f.Name())
+			}
+			if err := g.unpackField(c, *other, byName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// packParameter writes the statements packing the value v of the parameter.
+// The path names the parameter in error messages.
+func (g *generator) packParameter(p *dclass.Parameter, v, path string) {
+	if !p.IsArray() {
+		g.packElement(p, v, path)
+		return
+	}
+
+	if p.ArraySize() > 0 {
+		g.printf("for i := range %s {\n", v)
+		g.packElement(p, v+"[i]", path)
+		g.printf("}\n")
+		return
+	}
+
+	if min, max, ok := dclass.RangeBounds(p.ArrayRange); ok {
+		g.printf("if n := len(%s); %s {\nw.fail(\"%s: array length %%d is out of range\", n)\n}\n",
+			v, outOfBounds("n", min, max, new(big.Rat), nil), path)
+	}
+	g.printf("{\nstart := w.beginArray()\nfor i := range %s {\n", v)
+	g.packElement(p, v+"[i]", path)
+	g.printf("}\nw.endArray(start)\n}\n")
+}
+
+// packElement writes the statements packing the value v of a single element of the parameter.
+func (g *generator) packElement(p *dclass.Parameter, v, path string) {
+	typ := p.DataType()
+	switch {
+	case typ == dclass.StructType:
+		g.printf("%s.pack(w)\n", v)
+	case typ == dclass.StringType || typ == dclass.BlobType:
+		if min, max, ok := dclass.RangeBounds(p.Range); ok {
+			g.printf("if n := len(%s); %s {\nw.fail(\"%s: length %%d is out of range\", n)\n}\n",
+				v, outOfBounds("n", min, max, new(big.Rat), nil), path)
+		}
+		if typ == dclass.StringType {
+			g.printf("w.writeString(%s)\n", v)
+		} else {
+			g.printf("w.writeBytes(%s)\n", v)
+		}
+	case typ == dclass.FloatType:
+		g.printf("{\nf := %s\n", invertExpr(p.Transform, v))
+		if len(p.Transform) > 0 || p.Range != nil {
+			g.printf("if math.IsNaN(f) || math.IsInf(f, 0) {\nw.fail(\"%s: value %%v is not a finite number\", %s)\n}", path, v)
+			if min, max, ok := dclass.RangeBounds(p.Range); ok {
+				g.printf(" else if %s {\nw.fail(\"%s: value %%v is out of range\", f)\n}", outOfBounds("f", min, max, nil, nil), path)
+			}
+			g.printf("\n")
+		}
+		g.printf("w.writeFloat64(f)\n}\n")
+	case len(p.Transform) > 0:
+		g.printf("{\nf := math.Floor(%s + 0.5)\n", invertExpr(p.Transform, v))
+		min, max := typeBounds(typ)
+		if rmin, rmax, ok := dclass.RangeBounds(p.Range); ok {
+			min, max = rmin, rmax
+		}
+		g.printf("if math.IsNaN(f) || math.IsInf(f, 0) {\nw.fail(\"%s: value %%v cannot be transformed to an integer\", %s)\n}", path, v)
+		g.printf(" else if %s {\nw.fail(\"%s: value %%v is out of range\", %s)\n}\n",
+			outOfBounds("f", min, max, nil, nil), path, v)
+		g.printf("w.write%s(%s)\n}\n", wireName(typ), wireValue(typ, "f"))
+	default:
+		g.printf("{\nn := %s\n", v)
+		if min, max, ok := dclass.RangeBounds(p.Range); ok {
+			typMin, typMax := typeBounds(typ)
+			if cond := outOfBounds("n", min, max, typMin, typMax); cond != "" {
+				g.printf("if %s {\nw.fail(\"%s: value %%v is out of range\", n)\n}\n", cond, path)
+			}
+		}
+		if p.Enum() != nil {
+			g.printf("if !n.IsValid() {\nw.fail(\"%s: %%v is not a value of enum %s\", n)\n}\n", path, p.Enum().Name())
+		}
+		g.printf("w.write%s(%s)\n}\n", wireName(typ), wireValue(typ, "n"))
+	}
+}
+
+// unpackParameter writes the statements unpacking a value of the parameter into v.
+func (g *generator) unpackParameter(p *dclass.Parameter, v string) {
+	switch {
+	case !p.IsArray():
+		g.unpackElement(p, v)
+	case p.ArraySize() > 0:
+		g.printf("for i := range %s {\n", v)
+		g.unpackElement(p, v+"[i]")
+		g.printf("}\n")
+	default:
+		g.printf("{\n%s = nil\nr := r.array()\nfor r.more() {\nvar e %s\n", v, goElemType(p))
+		g.unpackElement(p, "e")
+		g.printf("%s = append(%s, e)\n}\n}\n", v, v)
+	}
+}
+
+// unpackElement writes the statements unpacking a single element of the parameter into v.
+func (g *generator) unpackElement(p *dclass.Parameter, v string) {
+	typ := p.DataType()
+	switch {
+	case typ == dclass.StructType:
+		g.printf("%s.unpack(r)\n", v)
+	case typ == dclass.StringType:
+		g.printf("%s = r.readString()\n", v)
+	case typ == dclass.BlobType:
+		g.printf("%s = r.readBytes()\n", v)
+	case typ == dclass.FloatType:
+		g.printf("%s = %s\n", v, applyExpr(p.Transform, "r.readFloat64()"))
+	case len(p.Transform) > 0:
+		g.printf("%s = %s\n", v, applyExpr(p.Transform, "float64("+typ.String()+"(r.read"+wireName(typ)+"()))"))
+	default:
+		g.printf("%s = %s(r.read%s())\n", v, goElemType(p), wireName(typ))
+	}
+}
+
+// goType returns the Go type used for values of the parameter.
+func goType(p *dclass.Parameter) string {
+	switch {
+	case p.IsArray() && p.ArraySize() > 0:
+		return "[" + strconv.Itoa(p.ArraySize()) + "]" + goElemType(p)
+	case p.IsArray():
+		return "[]" + goElemType(p)
+	}
+	return goElemType(p)
+}
+
+// goElemType returns the Go type used for a single element of the parameter.  Parameters with
+// a transform are unpacked as float64.
+func goElemType(p *dclass.Parameter) string {
+	switch {
+	case p.Enum() != nil && len(p.Transform) == 0:
+		return exported(p.Enum().Name())
+	case p.DataType() == dclass.StructType:
+		return exported(p.TypeName())
+	case len(p.Transform) > 0:
+		return "float64"
+	case p.DataType() == dclass.CharType:
+		return "byte"
+	case p.DataType() == dclass.BlobType:
+		return "[]byte"
+	}
+	return p.DataType().String()
+}
+
+// wireName returns the name of the dcWriter and dcReader methods for a fixed-size type.
+func wireName(typ dclass.DataType) string {
+	return "Uint" + strconv.Itoa(typ.Size()*8)
+}
+
+// wireValue converts the value v of an integer DataType to the unsigned type written by wireName.
+func wireValue(typ dclass.DataType, v string) string {
+	unsigned := "uint" + strconv.Itoa(typ.Size()*8)
+	if typ.IsSigned() {
+		return unsigned + "(" + typ.String() + "(" + v + "))"
+	}
+	return unsigned + "(" + v + ")"
+}
+
+// typeBounds returns the minimum and maximum values of an integer DataType.
+func typeBounds(typ dclass.DataType) (min, max *big.Rat) {
+	bits := uint(typ.Size() * 8)
+	one := big.NewInt(1)
+	if typ.IsSigned() {
+		limit := new(big.Int).Lsh(one, bits-1)
+		return new(big.Rat).SetInt(new(big.Int).Neg(limit)), new(big.Rat).SetInt(limit.Sub(limit, one))
+	}
+	limit := new(big.Int).Lsh(one, bits)
+	return new(big.Rat), new(big.Rat).SetInt(limit.Sub(limit, one))
+}
+
+// applyExpr returns an expression performing the transform's operations on the packed value v.
+func applyExpr(trans dclass.Transform, v string) string {
+	for _, op := range trans {
+		v = opExpr(op.Operator, v, op.Operand)
+	}
+	return v
+}
+
+// invertExpr returns an expression performing the inverse of the transform's operations on
+// the unpacked value v.  Modulus has no inverse, so it is applied as is.
+func invertExpr(trans dclass.Transform, v string) string {
+	inverse := map[byte]byte{'+': '-', '-': '+', '*': '/', '/': '*', '%': '%'}
+	for i := len(trans) - 1; i >= 0; i-- {
+		v = opExpr(inverse[trans[i].Operator], v, trans[i].Operand)
+	}
+	return v
+}
+
+func opExpr(op byte, v string, operand float64) string {
+	c := strconv.FormatFloat(operand, 'g', -1, 64)
+	if op == '%' {
+		return "math.Mod(" + v + ", " + c + ")"
+	}
+	return "(" + v + " " + string(op) + " " + c + ")"
+}
+
+// outOfBounds returns a condition testing whether v is outside of the bounds min and max.
+// Bounds which are guaranteed by the type of v, typMin and typMax, are omitted; if both are
+// omitted the condition is empty.  A nil type bound is not guaranteed.
+func outOfBounds(v string, min, max, typMin, typMax *big.Rat) string {
+	var conds []string
+	if typMin == nil || min.Cmp(typMin) > 0 {
+		conds = append(conds, v+" < "+numberLiteral(min))
+	}
+	if typMax == nil || max.Cmp(typMax) < 0 {
+		conds = append(conds, v+" > "+numberLiteral(max))
+	}
+	return strings.Join(conds, " || ")
+}
+
+// numberLiteral formats a number as a Go numeric constant.
+func numberLiteral(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func byteSlice(b []byte) string {
+	elems := make([]string, len(b))
+	for i, c := range b {
+		elems[i] = fmt.Sprintf("0x%02x", c)
+	}
+	return "[]byte{" + strings.Join(elems, ", ") + "}"
+}
+
+// argName returns the name of the Go parameter for an argument of an atomic field.
+func argName(arg dclass.Field, i int) string {
+	if arg.Name() == "" {
+		return "arg" + strconv.Itoa(i)
+	}
+	if name := unexported(arg.Name()); name != "o" {
+		return name
+	}
+	return "o_" // the receiver of the setter
+}
+
+// argField returns the name of the struct field storing an argument of an atomic field.
+func argField(arg dclass.Field, i int) string {
+	if arg.Name() == "" {
+		return "Arg" + strconv.Itoa(i)
+	}
+	return exported(arg.Name())
+}
+
+// exported returns the dclass identifier as an exported Go identifier.
+func exported(name string) string {
+	if name == "" || name[0] == '_' {
+		return "X" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// goKeywords are the Go keywords which are valid dclass identifiers.
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true,
+	"var": true,
+}
+
+// unexported returns the dclass identifier as an unexported Go identifier.
+func unexported(name string) string {
+	name = strings.ToLower(name[:1]) + name[1:]
+	if goKeywords[name] {
+		name += "_"
+	}
+	return name
+}
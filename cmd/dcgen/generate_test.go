@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func parseFile(t *testing.T, filename string) *dclass.File {
+	t.Helper()
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dcf, err := dclass.Parse(bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error parsing %s: %v", filename, err)
+	}
+	return dcf
+}
+
+func TestGenerateGolden(t *testing.T) {
+	dcf := parseFile(t, "testdata/avatar.dc")
+	src, err := generate(dcf, "avatar", "avatar.dc")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	golden := "testdata/avatar.golden"
+	if *update {
+		if err := os.WriteFile(golden, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expected) {
+		t.Errorf("generated code differs from %s; run go test -update to see the changes", golden)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		src, err string
+	}{
+		{"struct S {\n\tuint8 pack;\n};\n", "struct S: field pack has the name of the generated method Pack"},
+		{"struct S {\n\tuint8 x;\n\tuint8 X;\n};\n", "struct S: fields x and X both generate field X"},
+		{"dclass A {\n\tsetX(uint8);\n\tX(uint8);\n};\n", "dclass A: fields setX and X both generate method SetX"},
+		{"dclass A {\n\tbC(uint8);\n};\ndclass AB {\n\tc(uint8);\n};\n",
+			"dclass AB: the arguments of fields A.bC and AB.c both generate type aBC"},
+		{"dclass dc {\n\twriter(uint8);\n};\n",
+			"dclass dc: the arguments of field writer generate type dcWriter of the runtime"},
+	}
+	for _, c := range cases {
+		dcf, err := dclass.Parse(strings.NewReader(c.src))
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", c.src, err)
+		}
+		_, err = generate(dcf, "gen", "test.dc")
+		if err == nil || err.Error() != c.err {
+			t.Errorf("generate(%q): error %v, expected %s", c.src, err, c.err)
+		}
+	}
+}
+
+// generatedMain packs the fields of an Avatar with the generated code, printing the packed value
+// of each field, whether unpacking and repacking it gives the same value, and then the errors
+// packing values which are not finite numbers.
+const generatedMain = `package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+func main() {
+	o := NewAvatar()
+	o.SetMode(ModeWalk)
+	o.SetPath([]Point{{X: 1.5, Y: -2, Weight: 0.25}, {X: -0.1}}, [2]uint8{1, 2})
+	o.SetScale(3)
+	for _, id := range []int{FieldEntitySetName, FieldEntitySetMode, FieldAvatarLevel,
+		FieldAvatarSetPath, FieldAvatarSetScale, FieldAvatarSetNameMode} {
+		b, err := o.PackField(id, nil)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		var u Avatar
+		if err := u.UnpackField(id, b); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		repacked, _ := u.PackField(id, nil)
+		fmt.Println(hex.EncodeToString(b), bytes.Equal(b, repacked))
+	}
+
+	o.SetPath([]Point{{X: math.NaN()}}, [2]uint8{})
+	_, err := o.PackField(FieldAvatarSetPath, nil)
+	fmt.Println(err)
+	o.SetScale(math.Inf(1))
+	_, err = o.PackField(FieldAvatarSetScale, nil)
+	fmt.Println(err)
+}
+`
+
+func TestGeneratedCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping building the generated code in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("skipping building the generated code without the go tool")
+	}
+
+	dcf := parseFile(t, "testdata/avatar.dc")
+	src, err := generate(dcf, "main", "avatar.dc")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":    "module avatar\n\ngo 1.21\n",
+		"avatar.go": string(src),
+		"main.go":   generatedMain,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the generated code: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+
+	// The generated code packs the same data as the dclass package, which formats it as the
+	// values set by generatedMain
+	avatar := dcf.ClassByName["Avatar"].(*dclass.Class)
+	cases := []struct {
+		field, text string
+	}{
+		{"setName", `("entity")`},
+		{"setMode", `(Walk)`},
+		{"level", `1`},
+		{"setPath", `({{1.5, -2, 0.25}, {-0.1, 0, 0}}, {1, 2})`},
+		{"setScale", `(3)`},
+		{"setNameMode", `(("entity"), (Walk))`},
+	}
+	if len(lines) != len(cases)+2 {
+		t.Fatalf("unexpected output of the generated code:\n%s", out)
+	}
+	for i, c := range cases {
+		field := avatar.FieldByName(c.field)
+		expected, err := field.ParseString(c.text)
+		if err != nil {
+			t.Fatalf("ParseString(%s): %v", c.text, err)
+		}
+		if line := hex.EncodeToString(expected.Bytes()) + " true"; lines[i] != line {
+			t.Errorf("%s: generated code printed %s, expected %s", c.field, lines[i], line)
+			continue
+		}
+		if text := field.FormatData(expected, false); text != c.text {
+			t.Errorf("%s: formatted as %s, expected %s", c.field, text, c.text)
+		}
+	}
+
+	// Values which are not finite numbers are rejected by both
+	expectedErrors := []string{
+		"Point.x: value NaN cannot be transformed to an integer",
+		"Avatar.setScale.Scale: value +Inf is not a finite number",
+	}
+	for i, expected := range expectedErrors {
+		if line := lines[len(cases)+i]; line != expected {
+			t.Errorf("generated code printed error %s, expected %s", line, expected)
+		}
+	}
+	scale := &dclass.Value{Kind: dclass.ArgumentsValue, Elems: []*dclass.Value{
+		{Kind: dclass.FloatValue, Float: math.Inf(1)},
+	}}
+	if _, err := dclass.PackValue(avatar.FieldByName("setScale"), scale); err == nil {
+		t.Errorf("expected PackValue to reject a scale of +Inf")
+	}
+}
@@ -0,0 +1,73 @@
+// Command dcgen generates Go bindings for dclass files.
+//
+// Usage:
+//
+//	dcgen [-o output.go] [-package name] file.dc...
+//
+// The files are loaded in order as a single dclass File.  For each dclass, dcgen generates a
+// Go struct storing the value of every field including inherited fields, a setter and getter
+// for each field, and PackField and UnpackField methods which pack values in the dclass wire
+// format without reflection.  Each dc struct becomes a Go struct with Pack and Unpack methods,
+// and each enum a named integer type.  Constants are generated for the dclass and field IDs.
+//
+// The generated code is deterministic and formatted with gofmt, so it may be committed.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+var (
+	output = flag.String("o", "", "write the generated code to `file` instead of standard output")
+	pkg    = flag.String("package", "dclass", "the `name` of the generated package")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: dcgen [-o output.go] [-package name] file.dc...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	var buf bytes.Buffer
+	for _, filename := range flag.Args() {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	dcf, err := dclass.Parse(&buf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dcgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	src, err := generate(dcf, *pkg, strings.Join(flag.Args(), ", "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dcgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+	} else if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
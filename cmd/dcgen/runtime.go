@@ -0,0 +1,146 @@
+package main
+
+// runtime is the code included in every generated file to pack and unpack values in the
+// dclass wire format.
+const runtime = `// dcWriter packs values in the dclass wire format.
+type dcWriter struct {
+	buf []byte
+	err error
+}
+
+func (w *dcWriter) fail(format string, args ...interface{}) {
+	if w.err == nil {
+		w.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (w *dcWriter) writeUint8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *dcWriter) writeUint16(v uint16) {
+	w.buf = append(w.buf, byte(v), byte(v>>8))
+}
+
+func (w *dcWriter) writeUint32(v uint32) {
+	w.buf = append(w.buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (w *dcWriter) writeUint64(v uint64) {
+	w.writeUint32(uint32(v))
+	w.writeUint32(uint32(v >> 32))
+}
+
+func (w *dcWriter) writeFloat64(v float64) {
+	w.writeUint64(math.Float64bits(v))
+}
+
+func (w *dcWriter) writeLength(n int) {
+	if n > math.MaxUint16 {
+		w.fail("length %d exceeds the maximum length of %d bytes", n, math.MaxUint16)
+	}
+	w.writeUint16(uint16(n))
+}
+
+func (w *dcWriter) writeString(v string) {
+	w.writeLength(len(v))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *dcWriter) writeBytes(v []byte) {
+	w.writeLength(len(v))
+	w.buf = append(w.buf, v...)
+}
+
+// beginArray reserves the length of a variable-length array, returning the start of its elements.
+func (w *dcWriter) beginArray() int {
+	w.buf = append(w.buf, 0, 0)
+	return len(w.buf)
+}
+
+// endArray sets the length of the array with elements beginning at start.
+func (w *dcWriter) endArray(start int) {
+	n := len(w.buf) - start
+	if n > math.MaxUint16 {
+		w.fail("length %d exceeds the maximum length of %d bytes", n, math.MaxUint16)
+	}
+	binary.LittleEndian.PutUint16(w.buf[start-2:], uint16(n))
+}
+
+// dcReader unpacks values in the dclass wire format.  After an error, reads return zero values.
+type dcReader struct {
+	data   []byte
+	err    error
+	parent *dcReader // reader of the enclosing array, which records any error
+}
+
+func (r *dcReader) fail(format string, args ...interface{}) {
+	for r.parent != nil {
+		r = r.parent
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+// more returns whether there are elements remaining in an array.
+func (r *dcReader) more() bool {
+	root := r
+	for root.parent != nil {
+		root = root.parent
+	}
+	return len(r.data) > 0 && root.err == nil
+}
+
+func (r *dcReader) next(n int) []byte {
+	if len(r.data) < n {
+		r.fail("unexpected end of data")
+		r.data = nil
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *dcReader) readUint8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *dcReader) readUint16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *dcReader) readUint32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *dcReader) readUint64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *dcReader) readFloat64() float64 {
+	return math.Float64frombits(r.readUint64())
+}
+
+func (r *dcReader) readString() string {
+	return string(r.next(int(r.readUint16())))
+}
+
+func (r *dcReader) readBytes() []byte {
+	return append([]byte(nil), r.next(int(r.readUint16()))...)
+}
+
+// array returns a reader for the elements of a variable-length array.
+func (r *dcReader) array() *dcReader {
+	return &dcReader{data: r.next(int(r.readUint16())), parent: r}
+}
+
+// done returns any error reading the data, or an error if there is data remaining.
+func (r *dcReader) done() error {
+	if r.err == nil && len(r.data) > 0 {
+		r.fail("%d bytes of unexpected data", len(r.data))
+	}
+	return r.err
+}
+`
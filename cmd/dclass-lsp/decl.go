@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// The names which are defined by the dclass language.
+var (
+	declarationKeywords = []string{"keyword", "dclass", "struct", "enum", "const"}
+	builtinKeywords     = []string{"required", "ram", "db", "broadcast", "clrecv", "ownrecv", "clsend", "ownsend", "airecv"}
+	dataTypes           = []string{"int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64",
+		"float64", "string", "blob", "char"}
+)
+
+// typePos returns the position of the declaration of a class or struct.
+func typePos(typ dclass.Type) dclass.Pos {
+	if t, ok := typ.(interface{ Pos() dclass.Pos }); ok {
+		return t.Pos()
+	}
+	return dclass.Pos{}
+}
+
+// typeDoc returns the comments of the declaration of a class or struct.
+func typeDoc(typ dclass.Type) string {
+	if t, ok := typ.(interface{ Doc() string }); ok {
+		return t.Doc()
+	}
+	return ""
+}
+
+// typeDecl returns the declaration of a class or struct.
+func typeDecl(typ dclass.Type) *declaration {
+	decl := &declaration{pos: typePos(typ), doc: typeDoc(typ)}
+	switch t := typ.(type) {
+	case *dclass.Class:
+		decl.kind = "dclass " + strconv.Itoa(t.Index())
+		decl.text = "dclass " + t.Name()
+		for i, parent := range t.Parents() {
+			if i == 0 {
+				decl.text += " : "
+			} else {
+				decl.text += ", "
+			}
+			decl.text += parent.Name()
+		}
+		inherited := t.InheritedFields()
+		decl.info = append(decl.info, fmt.Sprintf("%d fields, %d inherited", len(inherited),
+			len(inherited)-len(t.Fields())))
+	case *dclass.Struct:
+		decl.kind = "struct " + strconv.Itoa(t.Index())
+		decl.text = "struct " + t.Name() + " {\n"
+		for _, f := range t.Fields() {
+			decl.text += "\t" + fmt.Sprint(f) + ";\n"
+		}
+		decl.text += "}"
+	}
+	return decl
+}
+
+// fieldDecl returns the declaration of a field or argument of a class or struct.
+func fieldDecl(f dclass.Field, typ dclass.Type) *declaration {
+	decl := &declaration{pos: f.Pos(), doc: f.Doc(), text: fmt.Sprint(f)}
+	if f.Number() < 0 {
+		decl.kind = "argument"
+	} else {
+		decl.kind = "field " + strconv.Itoa(f.Number()) + " of " + typeKind(typ) + " " + typ.Name()
+		if owner := fieldOwner(f); owner != nil && owner != typ {
+			decl.kind += ", declared by " + typeKind(owner) + " " + owner.Name()
+		}
+	}
+
+	params := []dclass.Field{f}
+	if _, ok := f.(*dclass.AtomicField); ok {
+		params = f.NestedFields()
+	}
+	for _, p := range params {
+		if p, ok := p.(*dclass.Parameter); ok {
+			if info := resolvedType(p); info != "" {
+				decl.info = append(decl.info, info)
+			}
+		}
+	}
+	return decl
+}
+
+// fieldOwner returns the class or struct which declares a field.
+func fieldOwner(f dclass.Field) dclass.Type {
+	for _, typ := range f.File().Classes {
+		for _, field := range typ.Fields() {
+			if field == f {
+				return typ
+			}
+		}
+	}
+	return nil
+}
+
+func typeKind(typ dclass.Type) string {
+	if _, ok := typ.(*dclass.Class); ok {
+		return "dclass"
+	}
+	return "struct"
+}
+
+// resolvedType describes the named type of a parameter, or how it is packed if it has a
+// transform.  Returns an empty string for parameters of a plain data type.
+func resolvedType(p *dclass.Parameter) string {
+	name := p.TypeName()
+	switch {
+	case p.Enum() != nil:
+		return fmt.Sprintf("`%s` is enum %s : %s", name, name, p.Enum().DataType())
+	case p.Struct() != nil:
+		var fields []string
+		for _, f := range p.Struct().Fields() {
+			fields = append(fields, fmt.Sprint(f)+";")
+		}
+		return fmt.Sprintf("`%s` is struct %s { %s }", name, name, strings.Join(fields, " "))
+	case p.DataType() == dclass.StructType:
+		return fmt.Sprintf("`%s` is not defined", name)
+	case len(p.Transform) > 0:
+		return fmt.Sprintf("`%s` is packed as %s", p.TypeString(), p.DataType())
+	}
+	return ""
+}
+
+// enumDecl returns the declaration of an enum.
+func enumDecl(e *dclass.Enum) *declaration {
+	decl := &declaration{pos: e.Pos(), kind: "enum"}
+	decl.text = "enum " + e.Name() + " : " + e.DataType().String() + " {\n"
+	for _, v := range e.Values() {
+		decl.text += "\t" + v.Name + " = " + v.Value.String() + ",\n"
+	}
+	decl.text += "}"
+	return decl
+}
+
+// constDecl returns the declaration of a constant.
+func constDecl(c *dclass.Const) *declaration {
+	decl := &declaration{pos: c.Pos(), kind: "const"}
+	decl.text = "const "
+	if c.DataType != dclass.InvalidType {
+		decl.text += c.DataType.String() + " "
+	}
+	decl.text += c.Name + " = " + c.Value.RatString()
+	return decl
+}
+
+// fieldType returns the type of a field, or the components of a molecular field.
+func fieldType(f dclass.Field) string {
+	var parts []string
+	switch f := f.(type) {
+	case *dclass.Parameter:
+		return f.TypeString()
+	case *dclass.MolecularField:
+		for _, c := range f.NestedFields() {
+			parts = append(parts, c.Name())
+		}
+		return ": " + strings.Join(parts, ", ")
+	default:
+		for _, arg := range f.NestedFields() {
+			parts = append(parts, arg.(*dclass.Parameter).TypeString())
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+}
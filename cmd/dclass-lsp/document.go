@@ -0,0 +1,324 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// A document is an open dclass file, and the File parsed from its text.
+type document struct {
+	uri   string
+	text  string
+	lines []int // the offset of the start of each line in text
+
+	dcf  *dclass.File
+	errs dclass.ErrorList
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+	doc.dcf, doc.errs = dclass.ParsePartial(strings.NewReader(text), dclass.ParseOptions{})
+	return doc
+}
+
+// offset returns the offset in the text of an LSP position.
+func (doc *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	} else if pos.Line >= len(doc.lines) {
+		return len(doc.text)
+	}
+
+	offset := doc.lines[pos.Line]
+	for units := 0; offset < len(doc.text) && doc.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		if units > pos.Character {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// position returns the LSP position of an offset in the text.
+func (doc *document) position(offset int) position {
+	line := sort.Search(len(doc.lines), func(i int) bool { return doc.lines[i] > offset }) - 1
+	units := len(utf16.Encode([]rune(doc.text[doc.lines[line]:offset])))
+	return position{line, units}
+}
+
+// posOffset returns the offset in the text of a position reported by the parser.
+func (doc *document) posOffset(pos dclass.Pos) int {
+	if !pos.IsValid() || pos.Line > len(doc.lines) {
+		return 0
+	}
+	offset := doc.lines[pos.Line-1] + pos.Column - 1
+	if offset > len(doc.text) {
+		return len(doc.text)
+	}
+	return offset
+}
+
+// wordRange returns the range of the word starting at offset, or of the character at offset if
+// it is not the start of a word.
+func (doc *document) wordRange(offset int) lspRange {
+	end := offset
+	for end < len(doc.text) {
+		r, size := utf8.DecodeRuneInString(doc.text[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+	if end == offset && end < len(doc.text) && doc.text[end] != '\n' {
+		_, size := utf8.DecodeRuneInString(doc.text[end:])
+		end += size
+	}
+	return lspRange{doc.position(offset), doc.position(end)}
+}
+
+// wordAt returns the word at an LSP position, and the offset of its start.  Returns an empty
+// word if the position is not within a word.
+func (doc *document) wordAt(pos position) (word string, start int) {
+	offset := doc.offset(pos)
+	start, end := offset, offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(doc.text[:start])
+		if !isWordRune(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(doc.text) {
+		r, size := utf8.DecodeRuneInString(doc.text[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+	return doc.text[start:end], start
+}
+
+// isWordRune reports whether r may be part of an identifier.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// diagnostics returns the errors and warnings of the document.
+func (doc *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+	add := func(errs dclass.ErrorList, severity int) {
+		for _, err := range errs {
+			d := diagnostic{Severity: severity, Source: "dclass", Message: err.Error()}
+			if err, ok := err.(*dclass.PosError); ok {
+				d.Message = err.Kind + ": " + err.Msg
+				d.Range = doc.wordRange(doc.posOffset(err.Pos))
+			}
+			diags = append(diags, d)
+		}
+	}
+	add(doc.errs, severityError)
+	add(doc.dcf.Warnings, severityWarning)
+	return diags
+}
+
+// A declaration is a name declared by the document.
+type declaration struct {
+	pos  dclass.Pos
+	kind string // a description of the declaration, for hovers
+	doc  string
+	text string // the declaration, shown as code in hovers
+	info []string
+}
+
+// enclosingType returns the class or struct whose declaration most closely precedes the offset.
+func (doc *document) enclosingType(offset int) dclass.Type {
+	var enclosing dclass.Type
+	for _, typ := range doc.dcf.Classes {
+		if pos := typePos(typ); pos.IsValid() && doc.posOffset(pos) <= offset {
+			if enclosing == nil || doc.posOffset(pos) > doc.posOffset(typePos(enclosing)) {
+				enclosing = typ
+			}
+		}
+	}
+	return enclosing
+}
+
+// lookup returns the declaration of the name at a position, or nil if the name is not declared.
+func (doc *document) lookup(pos position) (decl *declaration, word string, start int) {
+	word, start = doc.wordAt(pos)
+	if word == "" {
+		return nil, word, start
+	}
+	dcf := doc.dcf
+
+	// Names of fields and arguments in the enclosing class or struct take precedence, so that
+	// fields named like types resolve to the field
+	if typ := doc.enclosingType(start); typ != nil {
+		for _, f := range typ.Fields() {
+			if atomic, ok := f.(*dclass.AtomicField); ok {
+				for _, arg := range atomic.NestedFields() {
+					if arg.Name() == word && doc.posOffset(arg.Pos()) == start {
+						return fieldDecl(arg, typ), word, start
+					}
+				}
+			}
+		}
+		var field dclass.Field
+		if c, ok := typ.(*dclass.Class); ok {
+			field = c.FieldByName(word)
+		} else if s, ok := typ.(*dclass.Struct); ok {
+			field = s.FieldByName(word)
+		}
+		if field != nil && (dcf.ClassByName[word] == nil || doc.posOffset(field.Pos()) == start) {
+			return fieldDecl(field, typ), word, start
+		}
+	}
+
+	if typ, ok := dcf.ClassByName[word]; ok {
+		return typeDecl(typ), word, start
+	}
+	if e, ok := dcf.EnumByName[word]; ok {
+		return enumDecl(e), word, start
+	}
+	if c, ok := dcf.ConstByName[word]; ok {
+		return constDecl(c), word, start
+	}
+	if dcf.HasKeyword(word) {
+		return &declaration{pos: dcf.KeywordPos(word), kind: "keyword",
+			doc: dcf.KeywordDoc(word), text: "keyword " + word}, word, start
+	}
+	for _, keyword := range builtinKeywords {
+		if keyword == word {
+			return &declaration{kind: "built-in keyword", text: word}, word, start
+		}
+	}
+	for _, dataType := range dataTypes {
+		if dataType == word {
+			return &declaration{kind: "built-in type", text: word}, word, start
+		}
+	}
+	return nil, word, start
+}
+
+// definition returns the location of the declaration of the name at a position.
+func (doc *document) definition(pos position) []location {
+	decl, _, _ := doc.lookup(pos)
+	if decl == nil || !decl.pos.IsValid() {
+		return []location{}
+	}
+	return []location{{doc.uri, doc.wordRange(doc.posOffset(decl.pos))}}
+}
+
+// hover returns a description of the name at a position, or nil if it is not declared.
+func (doc *document) hover(pos position) *hover {
+	decl, word, start := doc.lookup(pos)
+	if decl == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("```dclass\n" + decl.text + "\n```\n\n")
+	b.WriteString(decl.kind + "\n")
+	for _, info := range decl.info {
+		b.WriteString("\n" + info + "\n")
+	}
+	if decl.doc != "" {
+		b.WriteString("\n---\n\n" + decl.doc + "\n")
+	}
+
+	rng := lspRange{doc.position(start), doc.position(start + len(word))}
+	return &hover{Contents: markupContent{"markdown", b.String()}, Range: &rng}
+}
+
+// completion returns the keywords, types, and constants which may be used in the document.
+func (doc *document) completion() []completionItem {
+	dcf := doc.dcf
+	items := []completionItem{}
+	for _, keyword := range declarationKeywords {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword})
+	}
+	for _, dataType := range dataTypes {
+		items = append(items, completionItem{Label: dataType, Kind: completionKeyword, Detail: "built-in type"})
+	}
+	for _, keyword := range builtinKeywords {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword, Detail: "built-in keyword"})
+	}
+	for _, keyword := range dcf.Keywords() {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword, Detail: "keyword"})
+	}
+	for _, typ := range dcf.Classes {
+		if _, ok := typ.(*dclass.Class); ok {
+			items = append(items, completionItem{Label: typ.Name(), Kind: completionClass, Detail: "dclass"})
+		} else {
+			items = append(items, completionItem{Label: typ.Name(), Kind: completionStruct, Detail: "struct"})
+		}
+	}
+	for _, e := range dcf.Enums {
+		items = append(items, completionItem{Label: e.Name(), Kind: completionEnum, Detail: "enum"})
+	}
+	for _, c := range dcf.Consts {
+		items = append(items, completionItem{Label: c.Name, Kind: completionConstant, Detail: "const"})
+	}
+	return items
+}
+
+// symbols returns the declarations of the document, with the fields of classes and structs.
+func (doc *document) symbols() []documentSymbol {
+	dcf := doc.dcf
+	symbols := []documentSymbol{}
+	symbol := func(name, detail string, kind int, pos dclass.Pos) documentSymbol {
+		rng := doc.wordRange(doc.posOffset(pos))
+		return documentSymbol{Name: name, Detail: detail, Kind: kind, Range: rng, SelectionRange: rng}
+	}
+
+	for _, keyword := range dcf.Keywords() {
+		if pos := dcf.KeywordPos(keyword); pos.IsValid() {
+			symbols = append(symbols, symbol(keyword, "keyword", symbolKey, pos))
+		}
+	}
+	for _, c := range dcf.Consts {
+		symbols = append(symbols, symbol(c.Name, "const", symbolConstant, c.Pos()))
+	}
+	for _, e := range dcf.Enums {
+		symbols = append(symbols, symbol(e.Name(), "enum", symbolEnum, e.Pos()))
+	}
+	for _, typ := range dcf.Classes {
+		var sym documentSymbol
+		if _, ok := typ.(*dclass.Class); ok {
+			sym = symbol(typ.Name(), "dclass", symbolClass, typePos(typ))
+		} else {
+			sym = symbol(typ.Name(), "struct", symbolStruct, typePos(typ))
+		}
+		for _, f := range typ.Fields() {
+			kind := symbolField
+			if _, ok := f.(*dclass.Parameter); !ok {
+				kind = symbolMethod
+			}
+			field := symbol(f.Name(), fieldType(f), kind, f.Pos())
+			if field.Range.End.Line > sym.Range.End.Line ||
+				field.Range.End.Line == sym.Range.End.Line && field.Range.End.Character > sym.Range.End.Character {
+				sym.Range.End = field.Range.End
+			}
+			sym.Children = append(sym.Children, field)
+		}
+		symbols = append(symbols, sym)
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].SelectionRange.Start, symbols[j].SelectionRange.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return symbols
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOffsetPosition(t *testing.T) {
+	// 😀 is four bytes in UTF-8 and two code units in UTF-16, é two bytes and one code unit
+	doc := newDocument("file:///test.dc", "a😀b\néx\n\nlast")
+	cases := []struct {
+		pos    position
+		offset int
+	}{
+		{position{0, 0}, 0},
+		{position{0, 1}, 1},
+		{position{0, 2}, 1}, // within the surrogate pair of 😀
+		{position{0, 3}, 5},
+		{position{0, 4}, 6},
+		{position{0, 10}, 6}, // past the end of the line
+		{position{1, 0}, 7},
+		{position{1, 1}, 9},
+		{position{1, 2}, 10},
+		{position{2, 0}, 11},
+		{position{3, 4}, 16},
+		{position{-1, 0}, 0},
+		{position{4, 0}, 16},
+	}
+	for _, c := range cases {
+		if offset := doc.offset(c.pos); offset != c.offset {
+			t.Errorf("offset(%v) = %d, expected %d", c.pos, offset, c.offset)
+		}
+	}
+
+	positions := []struct {
+		offset int
+		pos    position
+	}{
+		{0, position{0, 0}},
+		{1, position{0, 1}},
+		{5, position{0, 3}},
+		{6, position{0, 4}},
+		{7, position{1, 0}},
+		{9, position{1, 1}},
+		{11, position{2, 0}},
+		{16, position{3, 4}},
+	}
+	for _, c := range positions {
+		if pos := doc.position(c.offset); pos != c.pos {
+			t.Errorf("position(%d) = %v, expected %v", c.offset, pos, c.pos)
+		}
+	}
+}
+
+// caseBroken has errors after the declarations which are looked up, which the parser recovers
+// from.
+const caseBroken = `keyword p2p;
+const uint8 Max = 10;
+enum Mode : uint8 {
+	Idle = 1,
+};
+struct Point {
+	int16 x;
+};
+// An avatar.
+dclass Avatar {
+	setPos(Point pos, Mode mode) p2p;
+	setLevel(uint8(0-Max) level) ram;
+};
+dclass Child : Avatar, Missing {
+	setBroken(uint8 x;
+};
+`
+
+func TestLookup(t *testing.T) {
+	doc := newDocument("file:///test.dc", caseBroken)
+	if len(doc.errs) == 0 {
+		t.Fatalf("expected errors parsing the document")
+	}
+
+	cases := []struct {
+		pos  position
+		word string
+		kind string   // the kind of the declaration, or empty if it is not declared
+		def  position // the start of the declaration, if it has a position
+	}{
+		{position{10, 9}, "Point", "struct 0", position{5, 7}},
+		{position{10, 22}, "Mode", "enum", position{2, 5}},
+		{position{10, 31}, "p2p", "keyword", position{0, 8}},
+		{position{10, 16}, "pos", "argument", position{10, 14}},
+		{position{10, 2}, "setPos", "field 1 of dclass Avatar", position{10, 1}},
+		{position{11, 19}, "Max", "const", position{1, 12}},
+		{position{11, 30}, "ram", "built-in keyword", position{}},
+		{position{11, 10}, "uint8", "built-in type", position{}},
+		{position{13, 16}, "Avatar", "dclass 1", position{9, 7}},
+		{position{13, 25}, "Missing", "", position{}},
+		{position{12, 0}, "", "", position{}},
+	}
+	for _, c := range cases {
+		decl, word, _ := doc.lookup(c.pos)
+		switch {
+		case word != c.word:
+			t.Errorf("lookup(%v): found %q, expected %q", c.pos, word, c.word)
+		case c.kind == "" && decl != nil:
+			t.Errorf("lookup(%v): found %s %s, expected no declaration", c.pos, decl.kind, decl.text)
+		case c.kind != "" && (decl == nil || decl.kind != c.kind):
+			t.Errorf("lookup(%v): found %+v, expected a %s", c.pos, decl, c.kind)
+		}
+
+		defs := doc.definition(c.pos)
+		if c.def == (position{}) {
+			if len(defs) != 0 {
+				t.Errorf("definition(%v) = %v, expected none", c.pos, defs)
+			}
+		} else if len(defs) != 1 || defs[0].Range.Start != c.def || defs[0].URI != doc.uri {
+			t.Errorf("definition(%v) = %v, expected %v", c.pos, defs, c.def)
+		}
+
+		h := doc.hover(c.pos)
+		if c.kind == "" {
+			if h != nil {
+				t.Errorf("hover(%v) = %q, expected none", c.pos, h.Contents.Value)
+			}
+			continue
+		}
+		if h == nil || !strings.Contains(h.Contents.Value, "\n"+c.kind+"\n") || h.Range == nil ||
+			h.Range.Start.Line != c.pos.Line {
+			t.Errorf("hover(%v) = %+v, expected a description of the %s", c.pos, h, c.kind)
+		}
+	}
+
+	h := doc.hover(position{13, 16})
+	expected := "```dclass\ndclass Avatar\n```\n\ndclass 1\n\n2 fields, 0 inherited\n\n---\n\nAn avatar.\n"
+	if h == nil || h.Contents.Value != expected {
+		t.Errorf("hover of Avatar = %+v, expected %q", h, expected)
+	}
+}
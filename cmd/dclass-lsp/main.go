@@ -0,0 +1,41 @@
+// Command dclass-lsp is a Language Server Protocol server for dclass files.
+//
+// Usage:
+//
+//	dclass-lsp
+//
+// The server speaks LSP over standard input and output, and is started by an editor for files
+// with the .dc extension.  Each open file is parsed as a separate dclass File whenever it
+// changes, and the server provides:
+//
+//   - diagnostics for the errors and warnings of the file
+//   - go to definition of classes, structs, enums, constants, keywords, and fields
+//   - hover showing the declaration of a name with its resolved types, field IDs, and comments
+//   - completion of declared keywords, type names, and constants
+//   - the symbols declared by the file
+//
+// Files with errors are parsed with the parser's error recovery, so that the declarations
+// around the errors can still be used.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: dclass-lsp")
+		os.Exit(2)
+	}
+
+	s := newServer(bufio.NewReader(os.Stdin), os.Stdout)
+	if err := s.run(); err != nil {
+		fmt.Fprintln(os.Stderr, "dclass-lsp:", err)
+		os.Exit(1)
+	}
+	if !s.shutdown {
+		os.Exit(1) // exit without a shutdown request
+	}
+}
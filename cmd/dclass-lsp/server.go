@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// A server handles the messages of a single client.
+type server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs     map[string]*document // the open documents, by URI
+	shutdown bool                 // whether a shutdown request was received
+}
+
+func newServer(in *bufio.Reader, out io.Writer) *server {
+	return &server{in: in, out: out, docs: make(map[string]*document)}
+}
+
+// run handles messages until the exit notification, or until the input is closed.
+func (s *server) run() error {
+	for {
+		content, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			s.reply(json.RawMessage("null"), nil, &responseError{codeParseError, err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(&req)
+		if req.ID != nil {
+			var rerr *responseError
+			if err != nil {
+				var ok bool
+				if rerr, ok = err.(*responseError); !ok {
+					rerr = &responseError{codeInternalError, err.Error()}
+				}
+				result = nil
+			}
+			if err := s.reply(req.ID, result, rerr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle dispatches a request or notification.  A panic while handling a message is returned
+// as an error, so that one bad message does not stop the server.
+func (s *server) handle(req *request) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &responseError{codeInternalError, fmt.Sprintf("%s: %v\n%s", req.Method, r, debug.Stack())}
+		}
+	}()
+
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       map[string]interface{}{"openClose": true, "change": 1},
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"completionProvider":     map[string]interface{}{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "dclass-lsp"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil || len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Only full changes are requested by the server's capabilities
+		return nil, s.update(doc.uri, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics",
+			publishDiagnosticsParams{params.TextDocument.URI, []diagnostic{}})
+
+	case "textDocument/definition":
+		doc, pos, err := s.position(req)
+		if doc == nil {
+			return nil, err
+		}
+		return doc.definition(pos), nil
+	case "textDocument/hover":
+		doc, pos, err := s.position(req)
+		if doc == nil {
+			return nil, err
+		}
+		return doc.hover(pos), nil
+	case "textDocument/completion":
+		doc, _, err := s.position(req)
+		if doc == nil {
+			return nil, err
+		}
+		return doc.completion(), nil
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.docs[params.TextDocument.URI]; doc != nil {
+			return doc.symbols(), nil
+		}
+		return []documentSymbol{}, nil
+	}
+
+	if req.ID == nil {
+		return nil, nil // notifications which are not handled are ignored
+	}
+	return nil, &responseError{codeMethodNotFound, "method not found: " + req.Method}
+}
+
+// update parses the new text of a document, and publishes its diagnostics.
+func (s *server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, doc.diagnostics()})
+}
+
+// position returns the open document and position of a textDocument/positionParams request.
+// A nil document is returned if the document is not open or the params are invalid.
+func (s *server) position(req *request) (*document, position, error) {
+	var params textDocumentPositionParams
+	if err := unmarshalParams(req, &params); err != nil {
+		return nil, position{}, err
+	}
+	return s.docs[params.TextDocument.URI], params.Position, nil
+}
+
+func unmarshalParams(req *request, v interface{}) error {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{codeInvalidParams, "invalid params of " + req.Method + ": " + err.Error()}
+	}
+	return nil
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, err *responseError) error {
+	return writeMessage(s.out, response{"2.0", id, result, err})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{"2.0", method, params})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	uri := "file:///test.dc"
+	textDocument := map[string]interface{}{"uri": uri}
+	messages := []interface{}{
+		map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "initialized", "params": map[string]interface{}{}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": caseBroken},
+		}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "textDocument/definition", "params": map[string]interface{}{
+			"textDocument": textDocument, "position": position{10, 9},
+		}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": "hover", "method": "textDocument/hover", "params": map[string]interface{}{
+			"textDocument": textDocument, "position": position{11, 19},
+		}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "textDocument/hover", "params": "invalid"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 4, "method": "textDocument/unknown"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didClose", "params": map[string]interface{}{
+			"textDocument": textDocument,
+		}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 5, "method": "shutdown"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "exit"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 6, "method": "shutdown"}, // after exit
+	}
+
+	var in bytes.Buffer
+	for _, msg := range messages {
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	s := newServer(bufio.NewReader(&in), &out)
+	if err := s.run(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !s.shutdown {
+		t.Errorf("expected the server to be shut down")
+	}
+
+	// The replies and notifications, in the order they were sent
+	expected := []string{
+		`"id":1,"result":{"capabilities"`,
+		`"method":"textDocument/publishDiagnostics","params":{"uri":"file:///test.dc","diagnostics":[{"range":{"start":{"line":13,"character":23}`,
+		`"id":2,"result":[{"uri":"file:///test.dc","range":{"start":{"line":5,"character":7},"end":{"line":5,"character":12}}}]}`,
+		`"id":"hover","result":{"contents":{"kind":"markdown","value":"` + "```dclass\\nconst uint8 Max = 10\\n```\\n\\nconst\\n" + `"}`,
+		`"id":3,"result":null,"error":{"code":-32602,"message":"invalid params of textDocument/hover: `,
+		`"id":4,"result":null,"error":{"code":-32601,"message":"method not found: textDocument/unknown"}}`,
+		`"method":"textDocument/publishDiagnostics","params":{"uri":"file:///test.dc","diagnostics":[]}}`,
+		`"id":5,"result":null}`,
+	}
+	r := bufio.NewReader(&out)
+	for i, e := range expected {
+		content, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("reading message %d: %v", i, err)
+		}
+		if !json.Valid(content) || !bytes.Contains(content, []byte(e)) {
+			t.Errorf("message %d is %s, expected it to contain %s", i, content, e)
+		}
+	}
+	if content, err := readMessage(r); err != io.EOF {
+		t.Errorf("unexpected message %s after the last reply, error %v", content, err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	cases := []struct {
+		input, content, err string
+	}{
+		{"Content-Length: 2\r\n\r\n{}", "{}", ""},
+		{"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 4\r\n\r\nnull", "null", ""},
+		{"Content-Length: x\r\n\r\n{}", "", `invalid Content-Length "x"`},
+		{"Content-Length: 10\r\n\r\n{}", "", "unexpected EOF"},
+		{"Content-Length", "", "invalid message header: "},
+	}
+	for _, c := range cases {
+		content, err := readMessage(bufio.NewReader(bytes.NewBufferString(c.input)))
+		if c.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+				t.Errorf("readMessage(%q): error %v, expected %s", c.input, err, c.err)
+			}
+		} else if err != nil || string(content) != c.content {
+			t.Errorf("readMessage(%q) = %q, %v, expected %q", c.input, content, err, c.content)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// A diffReport is the machine-readable report printed by `dclass diff -json`.
+type diffReport struct {
+	OldHash       uint64               `json:"old_hash"`
+	NewHash       uint64               `json:"new_hash"`
+	Compatibility dclass.Compatibility `json:"compatibility"`
+	Changes       dclass.ChangeList    `json:"changes"`
+}
+
+// runDiff prints the changes between two versions of a dclass file.  The exit status is 3 if
+// any change is at least as severe as the -fail flag.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	fail := flags.String("fail", "client-breaking", "exit with status 3 for changes of this `compatibility` or worse")
+	flags.Usage = func() {
+		commandUsage("diff")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var failAt dclass.Compatibility
+	if err := failAt.UnmarshalText([]byte(*fail)); err != nil || flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	old, _ := load(flags.Args()[:1], dclass.ParseOptions{})
+	new, _ := load(flags.Args()[1:], dclass.ParseOptions{})
+	if old == nil || new == nil {
+		return 1
+	}
+
+	changes := dclass.Diff(old, new)
+	if *asJSON {
+		report := diffReport{old.Hash(), new.Hash(), changes.Compatibility(), changes}
+		if report.Changes == nil {
+			report.Changes = dclass.ChangeList{}
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		fmt.Fprintf(os.Stderr, "%d changes, %s\n", len(changes), changes.Compatibility())
+	}
+
+	if len(changes) > 0 && changes.Compatibility() >= failAt {
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// runDoc writes browsable documentation of the files to a directory: an index page, and a page
+// for each class and struct.
+func runDoc(args []string) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	format := flags.String("format", "html", "write the pages in `format`, either html or markdown")
+	outDir := flags.String("o", "doc", "write the pages to `dir`")
+	flags.Usage = func() {
+		commandUsage("doc")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var templates *docTemplates
+	switch *format {
+	case "html":
+		templates = htmlTemplates
+	case "markdown", "md":
+		templates = markdownTemplates
+	}
+	if templates == nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	dcf, _ := load(flags.Args(), dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+
+	site := newDocSite(dcf, templates.ext)
+	if err := site.write(*outDir, templates); err != nil {
+		fmt.Fprintln(os.Stderr, "dclass:", err)
+		return 1
+	}
+	return 0
+}
+
+// A docSite is the documentation of a File, made of an index page and a page for each class and
+// struct.  It holds the content of the pages, which is rendered by docTemplates.
+type docSite struct {
+	Classes  []docLink // the classes of the file, with the first line of their doc
+	Structs  []docLink // the structs of the file, with the first line of their doc
+	Enums    []docEnum
+	Consts   []docConst
+	Keywords []docKeyword
+	Pages    []*docPage
+
+	ext string // the extension of page files
+}
+
+// A docLink links to a page, or to an anchor of a page.
+type docLink struct {
+	Name    string
+	Href    string // empty if the link is to the current page
+	Summary string // the first line of the doc of the linked declaration
+}
+
+// A docSpan is part of a type, which is linked to the documentation of a struct or enum.
+type docSpan struct {
+	Text string
+	Href string
+}
+
+// A docPage documents a class or struct.
+type docPage struct {
+	File       string
+	Kind       string // either "dclass" or "struct"
+	Name       string
+	Doc        string
+	Chain      []docLink // the class and its ancestors, in the order inherited fields are resolved
+	Subclasses []docLink // the classes which directly inherit from the class
+	Fields     []docField
+}
+
+// A docField documents a field of a class or struct, or an argument of an atomic field.
+type docField struct {
+	ID       int
+	Name     string
+	Owner    docLink // the class which declares the field
+	Type     []docSpan
+	Default  string // the formatted default value of a parameter, if it has one
+	Keywords []docKeyword
+	Doc      string
+	Args     []docField // the arguments of an atomic field, if any of them are documented
+}
+
+type docEnum struct {
+	Name   string
+	Type   string
+	Values []dclass.EnumValue
+}
+
+type docConst struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+type docKeyword struct {
+	Name string
+	Doc  string
+}
+
+// builtinKeywords describes the keywords which are defined by the dclass language.
+var builtinKeywords = []docKeyword{
+	{"required", "The field must have a value when the object is created."},
+	{"ram", "The field's value is kept by the State Server while the object exists."},
+	{"db", "The field's value is stored in the database."},
+	{"broadcast", "Updates are sent to every client and AI which can see the object."},
+	{"clrecv", "Updates are received by clients which can see the object."},
+	{"ownrecv", "Updates are received by the client which owns the object."},
+	{"clsend", "Any client which can see the object may send updates."},
+	{"ownsend", "The client which owns the object may send updates."},
+	{"airecv", "Updates are received by the AI which controls the object."},
+}
+
+// newDocSite returns the documentation of a File, with pages using the file extension ext.
+func newDocSite(dcf *dclass.File, ext string) *docSite {
+	site := &docSite{ext: ext}
+
+	site.Keywords = append(site.Keywords, builtinKeywords...)
+	for _, keyword := range dcf.Keywords() {
+		site.Keywords = append(site.Keywords, site.keyword(dcf, keyword))
+	}
+	for _, e := range dcf.Enums {
+		site.Enums = append(site.Enums, docEnum{e.Name(), e.DataType().String(), e.Values()})
+	}
+	for _, c := range dcf.Consts {
+		dc := docConst{Name: c.Name, Value: c.Value.RatString()}
+		if c.DataType != dclass.InvalidType {
+			dc.Type = c.DataType.String()
+		}
+		site.Consts = append(site.Consts, dc)
+	}
+
+	// Find the type which declares each field, and the subclasses of each class
+	owners := make(map[dclass.Field]dclass.Type)
+	subclasses := make(map[*dclass.Class][]docLink)
+	for _, typ := range dcf.Classes {
+		for _, f := range typ.Fields() {
+			owners[f] = typ
+		}
+		if c, ok := typ.(*dclass.Class); ok {
+			for _, parent := range c.Parents() {
+				subclasses[parent] = append(subclasses[parent], site.typeLink(c))
+			}
+		}
+	}
+
+	for _, typ := range dcf.Classes {
+		page := &docPage{File: site.pageFile(typ), Name: typ.Name()}
+		fields := typ.Fields()
+		switch t := typ.(type) {
+		case *dclass.Class:
+			page.Kind, page.Doc = "dclass", t.Doc()
+			page.Chain = site.chain(t, make(map[*dclass.Class]bool), nil)
+			page.Subclasses = subclasses[t]
+			fields = t.InheritedFields()
+			site.Classes = append(site.Classes, site.typeLink(t))
+		case *dclass.Struct:
+			page.Kind, page.Doc = "struct", t.Doc()
+			site.Structs = append(site.Structs, site.typeLink(t))
+		}
+
+		for _, f := range fields {
+			df := site.field(dcf, f)
+			if owner := owners[f]; owner != nil && owner != typ {
+				df.Owner = site.typeLink(owner)
+				df.Owner.Summary = ""
+			} else {
+				df.Owner = docLink{Name: typ.Name()}
+			}
+			page.Fields = append(page.Fields, df)
+		}
+		site.Pages = append(site.Pages, page)
+	}
+	return site
+}
+
+// pageFile returns the file name of the page of a class or struct.  The name is prefixed by the
+// kind of the type, so that no page is named as the index page.
+func (site *docSite) pageFile(typ dclass.Type) string {
+	if _, ok := typ.(*dclass.Struct); ok {
+		return "struct-" + typ.Name() + site.ext
+	}
+	return "dclass-" + typ.Name() + site.ext
+}
+
+// typeLink returns a link to the page of a class or struct.
+func (site *docSite) typeLink(typ dclass.Type) docLink {
+	link := docLink{Name: typ.Name(), Href: site.pageFile(typ)}
+	if d, ok := typ.(interface{ Doc() string }); ok {
+		link.Summary = summary(d.Doc())
+	}
+	return link
+}
+
+// chain appends a class and its ancestors to links, in the order inherited fields are resolved.
+func (site *docSite) chain(c *dclass.Class, visited map[*dclass.Class]bool, links []docLink) []docLink {
+	if visited[c] {
+		return links
+	}
+	visited[c] = true
+	links = append(links, site.typeLink(c))
+	for _, parent := range c.Parents() {
+		links = site.chain(parent, visited, links)
+	}
+	return links
+}
+
+// keyword returns the documentation of a keyword.
+func (site *docSite) keyword(dcf *dclass.File, keyword string) docKeyword {
+	if doc := dcf.KeywordDoc(keyword); doc != "" {
+		return docKeyword{keyword, doc}
+	}
+	for _, builtin := range builtinKeywords {
+		if builtin.Name == keyword {
+			return builtin
+		}
+	}
+	return docKeyword{Name: keyword}
+}
+
+// field returns the documentation of a field or argument.
+func (site *docSite) field(dcf *dclass.File, f dclass.Field) docField {
+	df := docField{ID: f.Number(), Name: f.Name(), Doc: f.Doc()}
+	for _, keyword := range f.Keywords() {
+		df.Keywords = append(df.Keywords, site.keyword(dcf, keyword))
+	}
+
+	switch f := f.(type) {
+	case *dclass.Parameter:
+		df.Type = site.parameterType(f)
+		if f.HasDefaultValue() {
+			df.Default = f.FormatData(f.DefaultValue(), false)
+		}
+	case *dclass.AtomicField:
+		df.Type = append(df.Type, docSpan{Text: "("})
+		documented := false
+		for i, arg := range f.NestedFields() {
+			if i > 0 {
+				df.Type = append(df.Type, docSpan{Text: ", "})
+			}
+			df.Type = append(df.Type, site.parameterType(arg.(*dclass.Parameter))...)
+			if arg.Name() != "" {
+				df.Type = append(df.Type, docSpan{Text: " " + arg.Name()})
+			}
+
+			da := site.field(dcf, arg)
+			documented = documented || da.Doc != "" || da.Default != ""
+			df.Args = append(df.Args, da)
+		}
+		df.Type = append(df.Type, docSpan{Text: ")"})
+		if !documented {
+			df.Args = nil
+		}
+	case *dclass.MolecularField:
+		df.Type = append(df.Type, docSpan{Text: ": "})
+		for i, c := range f.NestedFields() {
+			if i > 0 {
+				df.Type = append(df.Type, docSpan{Text: ", "})
+			}
+			df.Type = append(df.Type, docSpan{c.Name(), "#" + c.Name()})
+		}
+	}
+	return df
+}
+
+// parameterType returns the type of a parameter, linked to the documentation of its struct or enum.
+func (site *docSite) parameterType(p *dclass.Parameter) []docSpan {
+	name := p.TypeName()
+	span := docSpan{Text: name}
+	switch {
+	case p.Struct() != nil:
+		span.Href = site.pageFile(p.Struct())
+	case p.Enum() != nil:
+		span.Href = "index" + site.ext + "#" + name
+	}
+	return []docSpan{span, {Text: strings.TrimPrefix(p.TypeString(), name)}}
+}
+
+// write renders the pages of the site into the directory dir.
+func (site *docSite) write(dir string, templates *docTemplates) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	files := map[string]interface{}{"index" + site.ext: site}
+	for _, page := range site.Pages {
+		files[page.File] = page
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var buf bytes.Buffer
+		tmpl := templates.page
+		if _, ok := files[name].(*docSite); ok {
+			tmpl = templates.index
+		}
+		if err := tmpl.Execute(&buf, files[name]); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summary returns the first line of a doc.
+func summary(doc string) string {
+	if i := strings.IndexByte(doc, '\n'); i >= 0 {
+		return doc[:i]
+	}
+	return doc
+}
+
+// paragraphs splits a doc into paragraphs, which are separated by blank lines.
+func paragraphs(doc string) []string {
+	var paras []string
+	for _, para := range strings.Split(doc, "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			paras = append(paras, para)
+		}
+	}
+	return paras
+}
+
+// markdownEscaper escapes the characters of plain text which have a meaning in Markdown.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "|", `\|`)
+
+// markdownText escapes plain text so that it can be written in a Markdown page.
+func markdownText(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// markdownCell formats a doc so that it can be written in a cell of a Markdown table.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+// docTemplates render the index page and the pages of classes and structs of a docSite.
+type docTemplates struct {
+	ext   string
+	index executer
+	page  executer
+}
+
+// an executer is either a text or html template.
+type executer interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+var docFuncs = map[string]interface{}{
+	"paragraphs": paragraphs,
+	"cell":       markdownCell,
+	"md":         markdownText,
+}
+
+var htmlTemplates = &docTemplates{
+	ext:   ".html",
+	index: htmltemplate.Must(htmltemplate.New("index").Funcs(docFuncs).Parse(htmlCommon + htmlIndex)),
+	page:  htmltemplate.Must(htmltemplate.New("page").Funcs(docFuncs).Parse(htmlCommon + htmlPage)),
+}
+
+var markdownTemplates = &docTemplates{
+	ext:   ".md",
+	index: template.Must(template.New("index").Funcs(docFuncs).Parse(markdownCommon + markdownIndex)),
+	page:  template.Must(template.New("page").Funcs(docFuncs).Parse(markdownCommon + markdownPage)),
+}
+
+const htmlCommon = `
+{{- define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: sans-serif; max-width: 64em; margin: 2em auto; padding: 0 1em; color: #222; }
+code, .type { font-family: monospace; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em; text-align: left; vertical-align: top; }
+tr:target { background: #ffc; }
+.inherited { color: #777; }
+.kw { display: inline-block; border-radius: .8em; padding: 0 .6em; margin: 1px; font-size: 85%; background: #ddd; }
+.kw-ram { background: #cde; } .kw-db { background: #dcf; } .kw-broadcast { background: #cec; }
+.kw-clsend { background: #fcb; } .kw-ownsend { background: #fdb; } .kw-airecv { background: #eec; }
+dl.args dt { font-family: monospace; }
+</style>
+</head>
+<body>
+{{end}}
+{{- define "doc"}}{{range paragraphs .}}<p>{{.}}</p>
+{{end}}{{end}}
+{{- define "type"}}<span class="type">{{range .}}{{if .Href}}<a href="{{.Href}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}{{end}}</span>{{end}}
+{{- define "keywords"}}{{range .}}<span class="kw kw-{{.Name}}"{{with .Doc}} title="{{.}}"{{end}}>{{.Name}}</span> {{end}}{{end}}
+{{- define "links"}}<ul>
+{{range .}}<li><a href="{{.Href}}">{{.Name}}</a>{{with .Summary}} — {{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}`
+
+const htmlIndex = `{{template "head" "dclass reference"}}<h1>dclass reference</h1>
+{{with .Classes}}<h2>Classes</h2>
+{{template "links" .}}{{end}}
+{{- with .Structs}}<h2>Structs</h2>
+{{template "links" .}}{{end}}
+{{- with .Enums}}<h2>Enums</h2>
+{{range .}}<h3 id="{{.Name}}">enum {{.Name}} : {{.Type}}</h3>
+<table>
+<tr><th>Name</th><th>Value</th></tr>
+{{range .Values}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+{{- with .Consts}}<h2>Constants</h2>
+<table>
+<tr><th>Name</th><th>Type</th><th>Value</th></tr>
+{{range .}}<tr id="{{.Name}}"><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+{{- with .Keywords}}<h2>Keywords</h2>
+<table>
+<tr><th>Keyword</th><th>Description</th></tr>
+{{range .}}<tr id="keyword-{{.Name}}"><td><span class="kw kw-{{.Name}}">{{.Name}}</span></td><td>{{template "doc" .Doc}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`
+
+const htmlPage = `{{template "head" (print .Kind " " .Name)}}<p><a href="index.html">Index</a></p>
+<h1>{{.Kind}} {{.Name}}</h1>
+{{template "doc" .Doc}}
+{{- if gt (len .Chain) 1}}<p>Inheritance: {{range $i, $c := .Chain}}{{if $i}} → <a href="{{.Href}}">{{.Name}}</a>{{else}}<strong>{{.Name}}</strong>{{end}}{{end}}</p>
+{{end}}
+{{- with .Subclasses}}<p>Subclasses: {{range $i, $c := .}}{{if $i}}, {{end}}<a href="{{.Href}}">{{.Name}}</a>{{end}}</p>
+{{end}}<h2>Fields</h2>
+<table>
+<tr><th>ID</th><th>Name</th>{{if eq .Kind "dclass"}}<th>Declared by</th>{{end}}<th>Type</th><th>Keywords</th><th>Description</th></tr>
+{{range .Fields}}<tr id="{{.Name}}"{{if .Owner.Href}} class="inherited"{{end}}><td>{{.ID}}</td><td><code>{{.Name}}</code></td>
+{{- if eq $.Kind "dclass"}}<td>{{if .Owner.Href}}<a href="{{.Owner.Href}}">{{.Owner.Name}}</a>{{else}}{{.Owner.Name}}{{end}}</td>{{end -}}
+<td>{{template "type" .Type}}{{with .Default}} = <code>{{.}}</code>{{end}}</td><td>{{template "keywords" .Keywords}}</td><td>{{template "doc" .Doc}}
+{{- with .Args}}<dl class="args">
+{{range .}}<dt>{{template "type" .Type}} {{.Name}}{{with .Default}} = {{.}}{{end}}</dt><dd>{{.Doc}}</dd>
+{{end}}</dl>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+const markdownCommon = `
+{{- define "type"}}{{range .}}{{if .Href}}[{{.Text}}]({{.Href}}){{else}}{{md .Text}}{{end}}{{end}}{{end}}
+{{- define "keywords"}}{{range $i, $k := .}}{{if $i}} {{end}}` + "`{{.Name}}`" + `{{end}}{{end}}
+{{- define "links"}}{{range .}}- [{{.Name}}]({{.Href}}){{with .Summary}} — {{.}}{{end}}
+{{end}}{{end}}`
+
+const markdownIndex = `# dclass reference
+{{with .Classes}}
+## Classes
+
+{{template "links" .}}{{end}}
+{{- with .Structs}}
+## Structs
+
+{{template "links" .}}{{end}}
+{{- with .Enums}}
+## Enums
+{{range .}}
+### <a id="{{.Name}}"></a>{{.Name}}
+
+` + "`enum {{.Name}} : {{.Type}}`" + `
+
+| Name | Value |
+| --- | --- |
+{{range .Values}}| {{.Name}} | {{.Value}} |
+{{end}}{{end}}{{end}}
+{{- with .Consts}}
+## Constants
+
+| Name | Type | Value |
+| --- | --- | --- |
+{{range .}}| {{.Name}} | {{.Type}} | {{.Value}} |
+{{end}}{{end}}
+{{- with .Keywords}}
+## Keywords
+
+| Keyword | Description |
+| --- | --- |
+{{range .}}| ` + "`{{.Name}}`" + ` | {{cell .Doc}} |
+{{end}}{{end}}`
+
+const markdownPage = `[Index](index.md)
+
+# {{.Kind}} {{.Name}}
+{{with .Doc}}
+{{.}}
+{{end}}
+{{- if gt (len .Chain) 1}}
+Inheritance: {{range $i, $c := .Chain}}{{if $i}} → [{{.Name}}]({{.Href}}){{else}}**{{.Name}}**{{end}}{{end}}
+{{end}}
+{{- with .Subclasses}}
+Subclasses: {{range $i, $c := .}}{{if $i}}, {{end}}[{{.Name}}]({{.Href}}){{end}}
+{{end}}
+## Fields
+
+{{if eq .Kind "dclass" -}}
+| ID | Name | Declared by | Type | Keywords | Description |
+| --- | --- | --- | --- | --- | --- |
+{{else -}}
+| ID | Name | Type | Keywords | Description |
+| --- | --- | --- | --- | --- |
+{{end -}}
+{{range .Fields}}{{$doc := .Doc}}| {{.ID}} | <a id="{{.Name}}"></a>` + "`{{.Name}}`" + ` |
+{{- if eq $.Kind "dclass"}} {{if .Owner.Href}}[{{.Owner.Name}}]({{.Owner.Href}}){{else}}{{.Owner.Name}}{{end}} |{{end}} {{template "type" .Type}}{{with .Default}} = ` + "`{{.}}`" + `{{end}} | {{template "keywords" .Keywords}} | {{cell .Doc}}
+{{- range $i, $arg := .Args}}{{if or $i $doc}}<br>{{end}}` + "`{{.Name}}`" + `{{with .Default}} = ` + "`{{.}}`" + `{{end}}{{with .Doc}}: {{cell .}}{{end}}{{end}} |
+{{end}}`
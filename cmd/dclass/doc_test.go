@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const caseDoc = `// Sent to every client.
+keyword broadcastAll;
+
+enum Mode : uint8 {
+	Idle = 1,
+};
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+// The class named like the index page.
+dclass index {
+	setMode(Mode mode) ram;
+};
+
+dclass Avatar : index {
+	setPos(Point pos) broadcastAll;
+};
+`
+
+func TestDoc(t *testing.T) {
+	paths := writeFiles(t, map[string]string{"test.dc": caseDoc}, "test.dc")
+	cases := []struct {
+		format string
+		pages  map[string][]string // the text expected on each page
+	}{
+		{"markdown", map[string][]string{
+			"index.md":         {"# dclass reference", "[index](dclass-index.md) — The class named like the index page.", "[Point](struct-Point.md)"},
+			"dclass-index.md":  {"# dclass index", "[Mode](index.md#Mode)"},
+			"dclass-Avatar.md": {"**Avatar** → [index](dclass-index.md)", "[Point](struct-Point.md)", "`broadcastAll`"},
+			"struct-Point.md":  {"# struct Point"},
+		}},
+		{"html", map[string][]string{
+			"index.html":         {"<h1>dclass reference</h1>", `<a href="dclass-index.html">index</a>`, "<p>Sent to every client.</p>"},
+			"dclass-index.html":  {"<h1>dclass index</h1>", `<a href="index.html#Mode">Mode</a>`},
+			"dclass-Avatar.html": {`<a href="dclass-index.html">index</a>`, `<a href="struct-Point.html">Point</a>`},
+			"struct-Point.html":  {"<h1>struct Point</h1>"},
+		}},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		if status, _, stderr := run(t, "doc", "-format", c.format, "-o", dir, paths[0]); status != 0 {
+			t.Fatalf("doc -format %s exited with status %d: %s", c.format, status, stderr)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(c.pages) {
+			t.Errorf("doc -format %s wrote %d pages, expected %d", c.format, len(entries), len(c.pages))
+		}
+		for name, texts := range c.pages {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("doc -format %s: %v", c.format, err)
+				continue
+			}
+			for _, text := range texts {
+				if !strings.Contains(string(data), text) {
+					t.Errorf("doc -format %s: expected %s to contain %s, got\n%s", c.format, name, text, data)
+				}
+			}
+		}
+	}
+}
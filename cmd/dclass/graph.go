@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// runGraph prints a Graphviz DOT graph of the class inheritance or struct usage of the files.
+func runGraph(args []string) int {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	structs := flags.Bool("structs", false, "graph which classes and structs contain each struct")
+	root := flags.String("root", "", "only graph the subtree rooted at the `class`")
+	flags.Usage = func() {
+		commandUsage("graph")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	dcf, _ := load(flags.Args(), dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+
+	opts := dclass.GraphOptions{Kind: dclass.InheritanceGraph, Root: *root}
+	if *structs {
+		opts.Kind = dclass.StructGraph
+	}
+	if err := dclass.WriteGraph(os.Stdout, dcf, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "dclass:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestGraph(t *testing.T) {
+	cases := []struct {
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{nil, 0, `digraph inheritance {
+	rankdir=BT;
+	node [shape=box];
+	"Base";
+	"Avatar";
+	"Avatar" -> "Base" [arrowhead=empty];
+}
+`, ""},
+		{[]string{"-structs"}, 0, `digraph structs {
+	rankdir=LR;
+	node [shape=box];
+	"Point" [shape=ellipse];
+	"Avatar";
+	"Avatar" -> "Point" [label="setPos.pos"];
+}
+`, ""},
+		{[]string{"-root", "Nope"}, 1, "", "dclass: no dclass named Nope\n"},
+	}
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	for _, c := range cases {
+		status, stdout, stderr := run(t, "graph", append(c.args, paths...)...)
+		if status != c.status || stdout != c.stdout || stderr != c.stderr {
+			t.Errorf("graph %v: exited with status %d and printed\n%s%s\nexpected status %d and\n%s%s",
+				c.args, status, stdout, stderr, c.status, c.stdout, c.stderr)
+		}
+	}
+}
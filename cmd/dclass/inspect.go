@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// runCheck parses the files, printing errors and warnings.  Warnings alone do not fail the check.
+func runCheck(args []string) int {
+	if len(args) == 0 {
+		return commandUsage("check")
+	}
+
+	dcf, src := load(args, dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+	src.printErrors(dcf.Warnings)
+	return 0
+}
+
+// runHash prints the hash of the files.
+func runHash(args []string) int {
+	if len(args) == 0 {
+		return commandUsage("hash")
+	}
+
+	dcf, _ := load(args, dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+	fmt.Println(dcf.Hash())
+	return 0
+}
+
+// runSchema prints the schema of the files as a JSON document.
+func runSchema(args []string) int {
+	if len(args) == 0 {
+		return commandUsage("schema")
+	}
+
+	dcf, _ := load(args, dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+	if err := dclass.WriteSchema(os.Stdout, dcf); err != nil {
+		fmt.Fprintln(os.Stderr, "dclass:", err)
+		return 1
+	}
+	return 0
+}
+
+// runList prints each class and struct with its fields, their numbers, and keywords.
+func runList(args []string) int {
+	if len(args) == 0 {
+		return commandUsage("list")
+	}
+
+	dcf, _ := load(args, dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+
+	for i, typ := range dcf.Classes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(typeHeader(typ))
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, f := range typ.Fields() {
+			fmt.Fprintf(w, "\t%d\t%s\n", f.Number(), f)
+		}
+		w.Flush()
+	}
+	return 0
+}
+
+// runShow prints the table of fields of a class, including the fields it inherits.
+func runShow(args []string) int {
+	if len(args) < 2 {
+		return commandUsage("show")
+	}
+
+	dcf, _ := load(args[1:], dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+
+	typ, ok := dcf.ClassByName[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "dclass: no class or struct named %s\n", args[0])
+		return 1
+	}
+	fields := typ.Fields()
+	if c, ok := typ.(*dclass.Class); ok {
+		fields = c.InheritedFields()
+	}
+
+	// Find the type which declares each field
+	owners := make(map[dclass.Field]dclass.Type)
+	for _, typ := range dcf.Classes {
+		for _, f := range typ.Fields() {
+			owners[f] = typ
+		}
+	}
+
+	fmt.Println(typeHeader(typ))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDECLARED BY\tKEYWORDS\tTYPE")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", f.Number(), f.Name(), owners[f].Name(),
+			strings.Join(f.Keywords(), " "), fieldType(f))
+	}
+	w.Flush()
+	return 0
+}
+
+// typeHeader returns the declaration line of a class or struct, ie. `dclass A : B, C`.
+func typeHeader(typ dclass.Type) string {
+	c, ok := typ.(*dclass.Class)
+	if !ok {
+		return "struct " + typ.Name()
+	}
+
+	header := "dclass " + c.Name()
+	for i, parent := range c.Parents() {
+		if i == 0 {
+			header += " : "
+		} else {
+			header += ", "
+		}
+		header += parent.Name()
+	}
+	return header
+}
+
+// fieldType returns the type of a field, or the components of a molecular field.
+func fieldType(f dclass.Field) string {
+	var parts []string
+	switch f := f.(type) {
+	case *dclass.Parameter:
+		return f.TypeString()
+	case *dclass.MolecularField:
+		for _, c := range f.NestedFields() {
+			parts = append(parts, c.Name())
+		}
+		return ": " + strings.Join(parts, ", ")
+	default:
+		for _, arg := range f.NestedFields() {
+			parts = append(parts, arg.(*dclass.Parameter).TypeString())
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+var caseFiles = map[string]string{
+	"base.dc": `keyword p2p;
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+dclass Base {
+	setName(string name) required broadcast;
+};
+`,
+	"avatar.dc": `dclass Avatar : Base {
+	setPos(Point pos, uint8) p2p ram;
+	uint32 level;
+	setAll : setName, setPos;
+};
+`,
+	"shadow.dc": `dclass Child : Base {
+	setName(uint8 name);
+};
+`,
+	"broken.dc": `dclass Broken : Missing {
+	setX(uint8 x;
+};
+`,
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		files  []string
+		status int
+		stderr string
+	}{
+		{[]string{"base.dc", "avatar.dc"}, 0, ""},
+		{[]string{"base.dc", "shadow.dc"}, 0,
+			"shadow.dc:2:2: semantic warning: field 'setName' of dclass Child"},
+		{[]string{"base.dc", "broken.dc"}, 1, `broken.dc:1:17: definition error: used dclass 'Missing', but 'Missing' was never defined
+broken.dc:2:14: parse error: expected ',' or ')' after argument of 'setX', found ";"
+broken.dc:3:2: parse error: missing semicolon (;) at end of statement
+broken.dc:4:1: lex error: unclosed left paren
+`},
+		{[]string{"base.dc", "missing.dc"}, 1, "missing.dc: no such file or directory"},
+		{nil, 2, "usage: dclass check <file>...\n"},
+	}
+	for _, c := range cases {
+		paths := writeFiles(t, caseFiles, c.files...)
+		status, stdout, stderr := run(t, "check", paths...)
+		if len(paths) > 0 {
+			stderr = strings.ReplaceAll(stderr, filepath.Dir(paths[0])+string(filepath.Separator), "")
+		}
+		if status != c.status || stdout != "" || !strings.Contains(stderr, c.stderr) {
+			t.Errorf("check %s: exited with status %d and printed\n%s%s\nexpected status %d and\n%s",
+				strings.Join(c.files, " "), status, stdout, stderr, c.status, c.stderr)
+		}
+		if c.stderr == "" && stderr != "" {
+			t.Errorf("check %s: unexpected output\n%s", strings.Join(c.files, " "), stderr)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	dcf, err := dclass.Parse(strings.NewReader(caseFiles["base.dc"] + caseFiles["avatar.dc"]))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := strconv.FormatUint(dcf.Hash(), 10) + "\n"
+
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	if status, stdout, stderr := run(t, "hash", paths...); status != 0 || stdout != expected {
+		t.Errorf("hash: exited with status %d and printed %q %q, expected %q", status, stdout, stderr, expected)
+	}
+}
+
+func TestList(t *testing.T) {
+	expected := `struct Point
+  0  int16 x
+  1  int16 y
+
+dclass Base
+  2  setName(string name) required broadcast
+
+dclass Avatar : Base
+  3  setPos(Point pos, uint8) ram p2p
+  4  uint32 level
+  5  setAll : setName, setPos
+`
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	if status, stdout, stderr := run(t, "list", paths...); status != 0 || stdout != expected {
+		t.Errorf("list: exited with status %d and printed\n%s%s\nexpected\n%s", status, stdout, stderr, expected)
+	}
+}
+
+func TestShow(t *testing.T) {
+	expected := `dclass Avatar : Base
+ID  NAME     DECLARED BY  KEYWORDS                    TYPE
+2   setName  Base         required broadcast          (string)
+3   setPos   Avatar       ram p2p                     (Point, uint8)
+4   level    Avatar                                   uint32
+5   setAll   Avatar       required ram broadcast p2p  : setName, setPos
+`
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	if status, stdout, stderr := run(t, "show", append([]string{"Avatar"}, paths...)...); status != 0 || stdout != expected {
+		t.Errorf("show: exited with status %d and printed\n%s%s\nexpected\n%s", status, stdout, stderr, expected)
+	}
+
+	status, _, stderr := run(t, "show", append([]string{"Nope"}, paths...)...)
+	if expected := "dclass: no class or struct named Nope\n"; status != 1 || stderr != expected {
+		t.Errorf("show Nope: exited with status %d and printed %q, expected %q", status, stderr, expected)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// runLint prints the issues found by the lint rules in the files.  The exit status is 3 if any
+// issue is at least as severe as the -fail flag.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := flags.String("format", "text", "print the issues in `format`, either text or sarif")
+	config := flags.String("config", "", "read the lint options from a JSON `file`")
+	maxSize := flags.Int("max-size", 0, "report fields with a fixed size larger than `bytes` (default 1024)")
+	fail := flags.String("fail", "error", "exit with status 3 for issues of this `severity` or worse")
+	list := flags.Bool("rules", false, "list the lint rules and their default severities")
+	rules := make(map[string]dclass.Severity)
+	flags.Func("rule", "set the severity of a rule as `name=severity`, where off disables the rule", func(s string) error {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return fmt.Errorf("%q is not of the form name=severity", s)
+		}
+		var severity dclass.Severity
+		if err := severity.UnmarshalText([]byte(s[i+1:])); err != nil {
+			return err
+		}
+		rules[s[:i]] = severity
+		return nil
+	})
+	flags.Usage = func() {
+		commandUsage("lint")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, rule := range dclass.LintRules {
+			fmt.Printf("%-22s %-8s %s\n", rule.Name, rule.Severity, rule.Doc)
+		}
+		return 0
+	}
+	var failAt dclass.Severity
+	if err := failAt.UnmarshalText([]byte(*fail)); err != nil || failAt == dclass.LintOff ||
+		(*format != "text" && *format != "sarif") || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var opts dclass.LintOptions
+	if *config != "" {
+		data, err := os.ReadFile(*config)
+		if err == nil {
+			err = json.Unmarshal(data, &opts)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "dclass:", err)
+			return 1
+		}
+	}
+	for name, severity := range rules {
+		if opts.Rules == nil {
+			opts.Rules = make(map[string]dclass.Severity)
+		}
+		opts.Rules[name] = severity
+	}
+	if *maxSize > 0 {
+		opts.MaxFixedSize = *maxSize
+	}
+
+	dcf, src := load(flags.Args(), dclass.ParseOptions{})
+	if dcf == nil {
+		return 1
+	}
+	issues, err := dclass.Lint(dcf, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dclass:", err)
+		return 2
+	}
+
+	if *format == "sarif" {
+		data, _ := json.MarshalIndent(newSarifLog(src, issues), "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("%s: %s: %s: %s (%s)\n", src.position(issue.Pos), issue.Severity, issue.Path,
+				issue.Msg, issue.Rule)
+		}
+		fmt.Fprintf(os.Stderr, "%d issues\n", len(issues))
+	}
+
+	for _, issue := range issues {
+		if issue.Severity >= failAt {
+			return 3
+		}
+	}
+	return 0
+}
+
+// A sarifLog is the report printed by `dclass lint -format sarif`, a subset of the Static
+// Analysis Results Interchange Format understood by code scanning tools.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// newSarifLog returns the report of the issues found in the source.
+func newSarifLog(src *source, issues []dclass.LintIssue) *sarifLog {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "dclass lint"
+	for _, rule := range dclass.LintRules {
+		r := sarifRule{ID: rule.Name, ShortDescription: sarifMessage{rule.Doc}}
+		r.DefaultConfiguration.Level = sarifLevel(rule.Severity)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, r)
+	}
+
+	for _, issue := range issues {
+		result := sarifResult{
+			RuleID:  issue.Rule,
+			Level:   sarifLevel(issue.Severity),
+			Message: sarifMessage{issue.Path + ": " + issue.Msg},
+		}
+		if filename, line := src.location(issue.Pos); filename != "" {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = filename
+			loc.PhysicalLocation.Region.StartLine = line
+			loc.PhysicalLocation.Region.StartColumn = issue.Pos.Column
+			result.Locations = append(result.Locations, loc)
+		}
+		run.Results = append(run.Results, result)
+	}
+	return &sarifLog{Version: "2.1.0", Runs: []sarifRun{run}}
+}
+
+// sarifLevel returns the SARIF level of results of a severity.
+func sarifLevel(severity dclass.Severity) string {
+	switch severity {
+	case dclass.LintError:
+		return "error"
+	case dclass.LintWarning:
+		return "warning"
+	case dclass.LintInfo:
+		return "note"
+	}
+	return "none"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	files := map[string]string{
+		"base.dc":    caseFiles["base.dc"],
+		"avatar.dc":  caseFiles["avatar.dc"],
+		"ignored.dc": "// lint:file-ignore broadcast-without-ram\n" + caseFiles["base.dc"],
+		"lint.json":  `{"rules": {"broadcast-without-ram": "error"}}`,
+	}
+	paths := writeFiles(t, files, "base.dc", "avatar.dc", "ignored.dc", "lint.json")
+	dir := filepath.Dir(paths[0]) + string(filepath.Separator)
+	issue := "base.dc:9:2: %s: Base.setName: broadcast field is not ram (broadcast-without-ram)\n"
+
+	cases := []struct {
+		args   []string
+		status int
+		stdout string
+	}{
+		{[]string{paths[0], paths[1]}, 0, fmt.Sprintf(issue, "warning")},
+		{[]string{"-fail", "warning", paths[0], paths[1]}, 3, fmt.Sprintf(issue, "warning")},
+		{[]string{"-config", paths[3], paths[0], paths[1]}, 3, fmt.Sprintf(issue, "error")},
+		{[]string{"-rule", "broadcast-without-ram=off", paths[0], paths[1]}, 0, ""},
+		{[]string{paths[2], paths[1]}, 0, ""},
+		{[]string{"-rule", "broadcast-without-ram", paths[0]}, 2, ""},
+		{[]string{"-fail", "off", paths[0]}, 2, ""},
+	}
+	for _, c := range cases {
+		status, stdout, _ := run(t, "lint", c.args...)
+		if stdout = strings.ReplaceAll(stdout, dir, ""); status != c.status || stdout != c.stdout {
+			t.Errorf("lint %v: exited with status %d and printed\n%s\nexpected status %d and\n%s",
+				c.args, status, stdout, c.status, c.stdout)
+		}
+	}
+
+	status, stdout, _ := run(t, "lint", "-format", "sarif", paths[0], paths[1])
+	var log sarifLog
+	if err := json.Unmarshal([]byte(stdout), &log); err != nil || status != 0 || len(log.Runs) != 1 {
+		t.Fatalf("lint -format sarif: exited with status %d and printed\n%s", status, stdout)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != "broadcast-without-ram" || results[0].Level != "warning" ||
+		len(results[0].Locations) != 1 {
+		t.Fatalf("lint -format sarif: unexpected results %+v", results)
+	}
+	loc := results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != paths[0] || loc.Region.StartLine != 9 || loc.Region.StartColumn != 2 {
+		t.Errorf("lint -format sarif: issue at %+v, expected %s:9:2", loc, paths[0])
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// A source is a set of dclass files which are loaded in order as a single File.
+type source struct {
+	filenames []string
+	firstLine []int // the line of the combined source each file begins on
+}
+
+// load parses the files in order as a single dclass File.  If the files could not be read, or
+// parsed without errors, the errors are printed and a nil File is returned.
+func load(filenames []string, opts dclass.ParseOptions) (*dclass.File, *source) {
+	src := &source{filenames: filenames}
+
+	var buf bytes.Buffer
+	line := 1
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, src
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+
+		src.firstLine = append(src.firstLine, line)
+		line += bytes.Count(data, []byte{'\n'})
+		buf.Write(data)
+	}
+
+	dcf, err := dclass.ParseWithOptions(&buf, opts)
+	if err != nil {
+		src.printErrors(err)
+		return nil, src
+	}
+	return dcf, src
+}
+
+// loadSyntax parses the syntax trees of the files, which are loaded in order as a single File by
+// refactorings.  If the files could not be read or parsed, the errors are printed and nil is
+// returned.
+func loadSyntax(filenames []string) ([]*dclass.SyntaxNode, *source) {
+	src := &source{filenames: filenames}
+
+	var trees []*dclass.SyntaxNode
+	line := 1
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, src
+		}
+		tree, err := dclass.ParseSyntax(bytes.NewReader(data))
+		if err != nil {
+			(&source{[]string{filename}, []int{1}}).printErrors(err)
+			return nil, src
+		}
+		trees = append(trees, tree)
+
+		src.firstLine = append(src.firstLine, line)
+		line += bytes.Count(data, []byte{'\n'})
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			line++
+		}
+	}
+	return trees, src
+}
+
+// printErrors prints each error of an ErrorList, prefixed by its position.
+func (src *source) printErrors(err error) {
+	list, ok := err.(dclass.ErrorList)
+	if !ok {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	for _, err := range list {
+		if err, ok := err.(*dclass.PosError); ok {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", src.position(err.Pos), err.Kind, err.Msg)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// position formats a position in the combined source as `file:line:col`.
+func (src *source) position(pos dclass.Pos) string {
+	filename, line := src.location(pos)
+	if filename == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d:%d", filename, line, pos.Column)
+}
+
+// location returns the file and line of a position in the combined source, or an empty filename
+// if the position is not known.
+func (src *source) location(pos dclass.Pos) (filename string, line int) {
+	if !pos.IsValid() || len(src.filenames) == 0 {
+		return "", 0
+	}
+
+	i := len(src.firstLine) - 1
+	for i > 0 && src.firstLine[i] > pos.Line {
+		i--
+	}
+	return src.filenames[i], pos.Line - src.firstLine[i] + 1
+}
@@ -0,0 +1,95 @@
+// Command dclass validates, inspects, and refactors dclass files.
+//
+// Usage:
+//
+//	dclass <command> [arguments]
+//
+// The commands are:
+//
+//	check <file>...         parse the files and print any errors and warnings
+//	hash <file>...          print the hash of the files
+//	list <file>...          list the classes and structs with their fields
+//	show <class> <file>...  print every field of a class, including inherited fields
+//	diff <old> <new>        print the changes between two versions of a file
+//	schema <file>...        print the schema of the files as a JSON document
+//	doc <file>...           write HTML or Markdown documentation of the files
+//	graph <file>...         print a Graphviz graph of class inheritance or struct usage
+//	lint <file>...          print likely mistakes found by configurable lint rules
+//	rename <old> <new> <file>...
+//	                        rename a class, struct, field, or keyword and its uses
+//	move <class.field> <file>...
+//	                        move a field of a class to its parent
+//
+// Multiple files are loaded in order as a single dclass File.  The rename and move commands name
+// fields as Class.field, and write the changed files to standard output, or with -w rewrite them
+// in place.
+//
+// The exit status is 1 if the files could not be loaded, and 2 if the command was used
+// incorrectly.  The diff command exits with status 3 if it finds changes as severe as its -fail
+// flag, by default client-breaking, and the lint command exits with status 3 if it finds issues
+// as severe as its -fail flag, by default error.  Lint rules are suppressed in the files by
+// "// lint:ignore rule" comments for the same or next line, and "// lint:file-ignore rule"
+// comments, which apply to all the files loaded together.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// A command is a subcommand of the dclass tool.
+type command struct {
+	name  string
+	args  string // usage of the command's arguments
+	short string // short description of the command
+	run   func(args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"check", "<file>...", "parse the files and print any errors and warnings", runCheck},
+		{"hash", "<file>...", "print the hash of the files", runHash},
+		{"list", "<file>...", "list the classes and structs with their fields", runList},
+		{"show", "<class> <file>...", "print every field of a class, including inherited fields", runShow},
+		{"diff", "[-json] [-fail compatibility] <old> <new>", "print the changes between two versions of a file", runDiff},
+		{"schema", "<file>...", "print the schema of the files as a JSON document", runSchema},
+		{"doc", "[-format html|markdown] [-o dir] <file>...", "write HTML or Markdown documentation of the files", runDoc},
+		{"graph", "[-structs] [-root class] <file>...", "print a Graphviz graph of class inheritance or struct usage", runGraph},
+		{"lint", "[-format text|sarif] [-config file] [-rule name=severity]... <file>...", "print likely mistakes found by configurable lint rules", runLint},
+		{"rename", "[-w] <old> <new> <file>...", "rename a class, struct, field, or keyword and its uses", runRename},
+		{"move", "[-w] [-to parent] <class.field> <file>...", "move a field of a class to its parent", runMove},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: dclass <command> [arguments]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-8s %s\n", cmd.name, cmd.short)
+	}
+	os.Exit(2)
+}
+
+// commandUsage prints the usage of a single command and returns the exit status for misuse.
+func commandUsage(name string) int {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			fmt.Fprintf(os.Stderr, "usage: dclass %s %s\n", cmd.name, cmd.args)
+		}
+	}
+	return 2
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "dclass: unknown command %q\n", os.Args[1])
+	usage()
+}
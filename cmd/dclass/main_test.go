@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes the files, named by their base names, to a temporary directory and returns
+// their paths in the order of names.
+func writeFiles(t *testing.T, files map[string]string, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range names {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
+
+// run runs the dclass command with the arguments, and returns its exit status and what it
+// printed to standard output and standard error.
+func run(t *testing.T, name string, args ...string) (status int, stdout, stderr string) {
+	t.Helper()
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		t.Fatalf("unknown command %s", name)
+	}
+
+	outFile, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outFile.Close()
+	defer errFile.Close()
+
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	status = cmd.run(args)
+	os.Stdout, os.Stderr = savedOut, savedErr
+
+	return status, readAll(t, outFile), readAll(t, errFile)
+}
+
+func readAll(t *testing.T, f *os.File) string {
+	t.Helper()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
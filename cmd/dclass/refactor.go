@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Astron/astron.libgo/dclass"
+)
+
+// runRename renames a class, struct, field, or keyword and its uses in the files.
+func runRename(args []string) int {
+	flags := flag.NewFlagSet("rename", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the files instead of standard output")
+	flags.Usage = func() {
+		commandUsage("rename")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 3 {
+		flags.Usage()
+		return 2
+	}
+
+	trees, src := loadSyntax(flags.Args()[2:])
+	if trees == nil {
+		return 1
+	}
+	before := sources(trees)
+	if err := dclass.Rename(trees, flags.Arg(0), flags.Arg(1)); err != nil {
+		printRefactorError(src, err)
+		return 1
+	}
+	return writeSyntax(src, trees, before, *write)
+}
+
+// runMove moves a field of a class to its parent, and prints the fields it renumbers.
+func runMove(args []string) int {
+	flags := flag.NewFlagSet("move", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the files instead of standard output")
+	to := flags.String("to", "", "move the field to the parent `class`, if the class has several parents")
+	flags.Usage = func() {
+		commandUsage("move")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+
+	trees, src := loadSyntax(flags.Args()[1:])
+	if trees == nil {
+		return 1
+	}
+	before := sources(trees)
+	changes, err := dclass.MoveField(trees, flags.Arg(0), *to)
+	if err != nil {
+		printRefactorError(src, err)
+		return 1
+	}
+
+	for _, c := range changes {
+		fmt.Fprintln(os.Stderr, c)
+	}
+	fmt.Fprintf(os.Stderr, "%d fields renumbered\n", len(changes))
+	return writeSyntax(src, trees, before, *write)
+}
+
+func printRefactorError(src *source, err error) {
+	if _, ok := err.(dclass.ErrorList); ok {
+		src.printErrors(err)
+	} else {
+		fmt.Fprintln(os.Stderr, "dclass:", err)
+	}
+}
+
+// sources returns the source of each syntax tree.
+func sources(trees []*dclass.SyntaxNode) []string {
+	srcs := make([]string, len(trees))
+	for i, tree := range trees {
+		srcs[i] = tree.String()
+	}
+	return srcs
+}
+
+// writeSyntax writes the files changed by a refactoring, in place if write is set.  Otherwise the
+// files are written to standard output, each preceded by a comment naming the file if several
+// files were loaded.
+func writeSyntax(src *source, trees []*dclass.SyntaxNode, before []string, write bool) int {
+	status := 0
+	for i, tree := range trees {
+		out := tree.String()
+		if out == before[i] {
+			continue
+		}
+		if write {
+			if err := os.WriteFile(src.filenames[i], []byte(out), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			}
+			continue
+		}
+		if len(trees) > 1 {
+			fmt.Printf("// %s\n", src.filenames[i])
+		}
+		fmt.Print(out)
+	}
+	return status
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	dir := filepath.Dir(paths[0]) + string(filepath.Separator)
+
+	status, stdout, stderr := run(t, "rename", append([]string{"Base.setName", "setLabel"}, paths...)...)
+	expected := `// base.dc
+keyword p2p;
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+dclass Base {
+	setLabel(string name) required broadcast;
+};
+// avatar.dc
+dclass Avatar : Base {
+	setPos(Point pos, uint8) p2p ram;
+	uint32 level;
+	setAll : setLabel, setPos;
+};
+`
+	if stdout = strings.ReplaceAll(stdout, dir, ""); status != 0 || stdout != expected {
+		t.Errorf("rename: exited with status %d and printed\n%s%s\nexpected\n%s", status, stdout, stderr, expected)
+	}
+
+	// Only the files which change are written
+	status, stdout, _ = run(t, "rename", append([]string{"-w", "Point", "Vec"}, paths...)...)
+	data, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = strings.Replace(caseFiles["avatar.dc"], "Point pos", "Vec pos", 1)
+	if status != 0 || stdout != "" || string(data) != expected {
+		t.Errorf("rename -w: exited with status %d and wrote\n%s\nexpected\n%s", status, data, expected)
+	}
+
+	status, _, stderr = run(t, "rename", append([]string{"Nope", "X"}, paths...)...)
+	if expected := "dclass: no dclass, struct, or keyword named Nope\n"; status != 1 || stderr != expected {
+		t.Errorf("rename Nope: exited with status %d and printed %q, expected %q", status, stderr, expected)
+	}
+}
+
+func TestMove(t *testing.T) {
+	paths := writeFiles(t, caseFiles, "base.dc", "avatar.dc")
+	status, stdout, stderr := run(t, "move", append([]string{"-w", "Avatar.level"}, paths...)...)
+	expectedErr := `client-breaking: Avatar.setPos: field-renumbered: 3 -> 4
+client-breaking: Avatar.level: field-renumbered: 4 -> 3
+2 fields renumbered
+`
+	if status != 0 || stdout != "" || stderr != expectedErr {
+		t.Errorf("move: exited with status %d and printed\n%s%s\nexpected\n%s", status, stdout, stderr, expectedErr)
+	}
+
+	expected := []string{
+		strings.Replace(caseFiles["base.dc"], "broadcast;\n", "broadcast;\n\tuint32 level;\n", 1),
+		strings.Replace(caseFiles["avatar.dc"], "\tuint32 level;\n", "", 1),
+	}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected[i] {
+			t.Errorf("move: wrote %s as\n%s\nexpected\n%s", filepath.Base(path), data, expected[i])
+		}
+	}
+}
@@ -1,20 +1,14 @@
 package dclass
 
-var definedKeywords = keywords{
-	"required",
-	"ram",
-	"broadcast",
-	"clrecv",
-	"clsend",
-	"ownrecv",
-	"ownsend",
-	"airecv",
-	"db",
-}
-
 type Type interface {
 	Hashable // inherits from Hashable
 
+	// Name returns the name of the type
+	Name() string
+
+	// Fields returns the fields declared by the type, in order of declaration
+	Fields() []Field
+
 	// AddField creates a new field and adds it to the object. The typ argument
 	// can be one of "parameter", "atomic", or "molecular".  Will return nil if
 	// the specified field type cannot be added to the type.
@@ -23,26 +17,164 @@ type Type interface {
 
 // a typeBase is a parent type that the Class and Struct types should extend
 type typeBase struct {
-	dcf   *File  // file this type is associated with
-	name  string // name of the type
-	index int    // the unique index of the type within the dclass file
+	dcf    *File   // file this type is associated with
+	name   string  // name of the type
+	index  int     // the unique index of the type within the dclass file
+	pos    Pos     // position of the type's declaration in the source file
+	doc    string  // text of the comments attached to the type's declaration
+	fields []Field // the fields declared by the type, in order
+}
+
+// Name returns the name of the type
+func (t *typeBase) Name() string {
+	return t.name
+}
+
+// Index returns the unique index of the type within its dclass File
+func (t *typeBase) Index() int {
+	return t.index
+}
+
+// File returns the dclass File this type is associated with
+func (t *typeBase) File() *File {
+	return t.dcf
+}
+
+// Pos returns the position of the type's declaration in the source file
+func (t *typeBase) Pos() Pos {
+	return t.pos
+}
+
+// Doc returns the text of the comments directly above the type's declaration, followed by those
+// on the same line after it.  Comments are not part of the type's Hash.
+func (t *typeBase) Doc() string {
+	return t.doc
+}
+
+// Fields returns the fields declared by the type, in order of declaration
+func (t *typeBase) Fields() []Field {
+	return t.fields
+}
+
+// FieldByName returns the field declared by the type with the given name, or nil if the type
+// has no such field.
+func (t *typeBase) FieldByName(name string) Field {
+	for _, f := range t.fields {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
 }
 
 type Class struct {
 	typeBase // inherits from typeBase
+
+	parents []*Class // the classes this class inherits from, in order of declaration
+}
+
+// Parents returns the classes this class directly inherits from, in order of declaration.
+func (c *Class) Parents() []*Class {
+	return c.parents
+}
+
+// AddParent appends a class to the list of classes this class inherits from.
+func (c *Class) AddParent(parent *Class) {
+	c.parents = append(c.parents, parent)
+}
+
+// FieldByName returns the field with the given name declared by the class, or inherited from
+// one of its parents, or nil if the class has no such field.  Fields declared by the class take
+// precedence over inherited fields, and earlier parents take precedence over later parents.
+func (c *Class) FieldByName(name string) Field {
+	return c.fieldByName(name, make(map[*Class]bool))
+}
+
+func (c *Class) fieldByName(name string, visited map[*Class]bool) Field {
+	if visited[c] {
+		return nil
+	}
+	visited[c] = true
+
+	if f := c.typeBase.FieldByName(name); f != nil {
+		return f
+	}
+	for _, parent := range c.parents {
+		if f := parent.fieldByName(name, visited); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// InheritedFields returns all of the fields of the class, including the fields inherited from its
+// parents.  Inherited fields come first, in the order of the parents; a field declared by the class
+// with the same name as an inherited field replaces it in place, and other fields are appended.
+func (c *Class) InheritedFields() []Field {
+	return c.inheritedFields(map[*Class]bool{})
+}
+
+func (c *Class) inheritedFields(visited map[*Class]bool) []Field {
+	if visited[c] {
+		return nil
+	}
+	visited[c] = true
+
+	var fields []Field
+	index := make(map[string]int)
+	add := func(f Field) {
+		if i, ok := index[f.Name()]; ok {
+			fields[i] = f
+			return
+		}
+		index[f.Name()] = len(fields)
+		fields = append(fields, f)
+	}
+
+	for _, parent := range c.parents {
+		for _, f := range parent.inheritedFields(visited) {
+			if _, ok := index[f.Name()]; !ok {
+				add(f)
+			}
+		}
+	}
+	for _, f := range c.fields {
+		add(f)
+	}
+	return fields
 }
 
 // Hash returns a hash of the class's structure. Hash implements the Hashable interface.
-// TODO: Implement
 func (c *Class) Hash() uint64 {
-	return 0
+	return hashOf(c)
 }
 
 // AddField creates a new field and adds it to the class. The typ argument
 // can be any one of "parameter", "atomic", or "molecular".
-// TODO: Implement
 func (c *Class) AddField(name, typ string) Field {
-	return nil
+	var field Field
+	switch typ {
+	case "parameter":
+		p := new(Parameter)
+		p.dcf, p.name, p.KeywordSet = c.dcf, name, c.dcf.newKeywordSet()
+		p.index = c.dcf.addField(p)
+		field = p
+	case "atomic":
+		f := new(AtomicField)
+		f.dcf, f.name, f.KeywordSet = c.dcf, name, c.dcf.newKeywordSet()
+		f.index = c.dcf.addField(f)
+		field = f
+	case "molecular":
+		f := new(MolecularField)
+		f.dcf, f.name, f.KeywordSet = c.dcf, name, c.dcf.newKeywordSet()
+		f.index = c.dcf.addField(f)
+		field = f
+	default:
+		return nil
+	}
+
+	c.fields = append(c.fields, field)
+	return field
 }
 
 type Struct struct {
@@ -50,14 +182,22 @@ type Struct struct {
 }
 
 // Hash returns a hash of the struct's structure. Hash implements the Hashable interface.
-// TODO: Implement
 func (s *Struct) Hash() uint64 {
-	return 0
+	return hashOf(s)
 }
 
 // AddField creates a new field and adds it to the struct.
 // Structs can only accept a "Parameter" field type.
-// TODO: Implement
 func (s *Struct) AddField(name, typ string) Field {
-	return nil
+	if typ != "parameter" {
+		return nil
+	}
+
+	p := new(Parameter)
+	p.dcf = s.dcf
+	p.name = name
+	p.index = s.dcf.addField(p)
+	p.KeywordSet = s.dcf.newKeywordSet()
+	s.fields = append(s.fields, p)
+	return p
 }
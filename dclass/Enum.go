@@ -0,0 +1,100 @@
+package dclass
+
+import "math/big"
+
+// An Enum is a named set of integer constants which may be used as the type of a Parameter.
+// A parameter of an enum type is packed as the enum's underlying integer type, and may only
+// hold one of the values declared by the enum.
+type Enum struct {
+	dcf      *File       // file this enum is associated with
+	name     string      // name of the enum
+	index    int         // the unique index of the enum within the dclass file
+	pos      Pos         // position of the enum's declaration in the source file
+	dataType DataType    // the underlying integer type of the enum
+	values   []EnumValue // the enumerated values in order of declaration
+}
+
+// An EnumValue is a single named value of an Enum.
+type EnumValue struct {
+	Name  string
+	Value *big.Int
+}
+
+// Name returns the name of the enum
+func (e *Enum) Name() string {
+	return e.name
+}
+
+// Index returns the unique index of the enum within its dclass File
+func (e *Enum) Index() int {
+	return e.index
+}
+
+// File returns the dclass File this enum is associated with
+func (e *Enum) File() *File {
+	return e.dcf
+}
+
+// Pos returns the position of the enum's declaration in the source file
+func (e *Enum) Pos() Pos {
+	return e.pos
+}
+
+// DataType returns the underlying integer type of the enum
+func (e *Enum) DataType() DataType {
+	return e.dataType
+}
+
+// Values returns the enumerated values in order of declaration
+func (e *Enum) Values() []EnumValue {
+	return e.values
+}
+
+// AddValue appends a named value to the enum.  An error is returned if the name is already
+// used by the enum or the value does not fit in the enum's underlying type.
+func (e *Enum) AddValue(name string, value *big.Int) error {
+	if _, ok := e.ValueByName(name); ok {
+		return Error("enum " + e.name + " already has a value named " + name)
+	}
+	if !fitsType(e.dataType, value) {
+		return Error("value " + value.String() + " of " + e.name + "." + name +
+			" does not fit in type " + e.dataType.String())
+	}
+	e.values = append(e.values, EnumValue{name, new(big.Int).Set(value)})
+	return nil
+}
+
+// ValueByName returns the value of the enumerator with the given name.
+func (e *Enum) ValueByName(name string) (*big.Int, bool) {
+	for _, v := range e.values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// NameOf returns the name of the first enumerator with the value v.
+func (e *Enum) NameOf(v *big.Int) (string, bool) {
+	for _, ev := range e.values {
+		if ev.Value.Cmp(v) == 0 {
+			return ev.Name, true
+		}
+	}
+	return "", false
+}
+
+// A Const is a named numeric constant declared in a dclass File. Constants may be used
+// in place of a number in ranges, transforms, default values, and enum values.
+type Const struct {
+	Name     string
+	DataType DataType // the declared type of the constant, or InvalidType if untyped
+	Value    *big.Rat
+
+	pos Pos // position of the constant's declaration in the source file
+}
+
+// Pos returns the position of the constant's declaration in the source file
+func (c *Const) Pos() Pos {
+	return c.pos
+}
@@ -6,6 +6,7 @@ import "bytes"
 // of a dclass Class object. Field inherits from Hashable, requiring concrete fields to implement Hash.
 type Field interface {
 	Hashable
+	KeywordList
 
 	// Name returns the name of this field parsed from a file
 	Name() string
@@ -21,6 +22,13 @@ type Field interface {
 	// File returns the dclass File this field is associated with
 	File() *File
 
+	// Pos returns the position of the field's declaration in the source file
+	Pos() Pos
+
+	// Doc returns the text of the comments directly above the field's declaration, followed by
+	// those on the same line after it.  Comments are not part of the field's Hash.
+	Doc() string
+
 	// DefaultValue returns the default value specified in the dclass File,
 	// or the null value if no default was specified (typically 0).
 	DefaultValue() bytes.Buffer
@@ -52,39 +60,328 @@ type Field interface {
 
 // a fieldBase is a parent type which other implementations of the Field interface can extend
 type fieldBase struct {
-	dcf      *File  // file this type is associated with
-	name     string // name of the field
-	index    int    // the unique index of the type within the dclass file
-	keywords        // implements KeywordList
+	dcf        *File  // file this type is associated with
+	name       string // name of the field
+	index      int    // the unique index of the type within the dclass file
+	pos        Pos    // position of the field's declaration in the source file
+	doc        string // text of the comments attached to the field's declaration
+	KeywordSet        // implements KeywordList
+}
+
+// Name returns the name of this field parsed from a file. Implements Field.
+func (f *fieldBase) Name() string {
+	return f.name
+}
+
+// Number returns the index of this field which is unqiue within its dclass File. Implements Field.
+func (f *fieldBase) Number() int {
+	return f.index
+}
+
+// File returns the dclass File this field is associated with. Implements Field.
+func (f *fieldBase) File() *File {
+	return f.dcf
+}
+
+// Pos returns the position of the field's declaration in the source file. Implements Field.
+func (f *fieldBase) Pos() Pos {
+	return f.pos
+}
+
+// Doc returns the text of the comments attached to the field's declaration. Implements Field.
+func (f *fieldBase) Doc() string {
+	return f.doc
+}
+
+// setDoc attaches the text of comments to the field's declaration.
+func (f *fieldBase) setDoc(doc string) {
+	f.doc = doc
+}
+
+// implementing Field
+func (f *fieldBase) IsRequired() bool {
+	return f.has(keywordRequired)
+}
+
+// implementing Field
+func (f *fieldBase) IsRam() bool {
+	return f.has(keywordRam)
+}
+
+// implementing Field
+func (f *fieldBase) IsBroadcast() bool {
+	return f.has(keywordBroadcast)
+}
+
+// implementing Field
+func (f *fieldBase) IsClrecv() bool {
+	return f.has(keywordClrecv)
+}
+
+// implementing Field
+func (f *fieldBase) IsClsend() bool {
+	return f.has(keywordClsend)
+}
+
+// implementing Field
+func (f *fieldBase) IsOwnrecv() bool {
+	return f.has(keywordOwnrecv)
+}
+
+// implementing Field
+func (f *fieldBase) IsOwnsend() bool {
+	return f.has(keywordOwnsend)
+}
+
+// implementing Field
+func (f *fieldBase) IsAirecv() bool {
+	return f.has(keywordAirecv)
+}
+
+// implementing Field
+func (f *fieldBase) IsDb() bool {
+	return f.has(keywordDb)
 }
 
 type Parameter struct {
 	fieldBase // inherits from fieldBase
 
-	dataType  DataType
-	isArray   bool
-	Range     Range
-	Transform Transform
+	dataType   DataType
+	typeName   string  // name of the struct or enum type, if the parameter has a named type
+	structType *Struct // struct type of the parameter, if dataType is StructType
+	enum       *Enum   // enum type of the parameter, if the parameter has an enum type
+	isArray    bool
+	arraySize  int   // number of elements in a fixed-size array, or 0 for variable-length arrays
+	ArrayRange Range // optional constraint on the number of elements in a variable-length array
+	Range      Range
+	Transform  Transform
 
-	defVal bytes.Buffer
+	defVal    bytes.Buffer
+	hasDefVal bool
 }
 type AtomicField struct {
 	fieldBase // inherits from fieldBase
+
+	args []Field // the parameters of the atomic field
 }
 type MolecularField struct {
 	fieldBase // inherits from fieldBase
+
+	components []Field // the atomic fields and parameters the molecular field is composed of
 }
 
 // AddField creates a new field and adds it to the object.
-// Atomic fields can only accept a "Parameter" field type.
-// TODO: Implement
+// Atomic fields can only accept a "Parameter" field type. The name may be empty for
+// unnamed arguments.  Arguments are not numbered within the dclass File.
 func (f *AtomicField) AddField(name, typ string) Field {
-	return nil
+	if typ != "parameter" {
+		return nil
+	}
+
+	p := new(Parameter)
+	p.dcf = f.dcf
+	p.name = name
+	p.index = -1
+	p.KeywordSet = f.dcf.newKeywordSet()
+	f.args = append(f.args, p)
+	return p
 }
 
-// AddField creates a new field and adds it to the object.
-// Molecular fields can accept "Parameter" and "AtomicField" field types.
-// TODO: Implement
+// Hash returns a hash of the atomic field's structure. Hash implements the Hashable interface.
+func (f *AtomicField) Hash() uint64 {
+	return hashOf(f)
+}
+
+// NestedFields returns the arguments of the atomic field. Implements Field.
+func (f *AtomicField) NestedFields() []Field {
+	return f.args
+}
+
+// DefaultValue returns the packed default values of each argument. Implements Field.
+func (f *AtomicField) DefaultValue() bytes.Buffer {
+	var buf bytes.Buffer
+	for _, arg := range f.args {
+		val := arg.DefaultValue()
+		buf.Write(val.Bytes())
+	}
+	return buf
+}
+
+// HasDefaultValue returns whether any argument has a default value. Implements Field.
+func (f *AtomicField) HasDefaultValue() bool {
+	for _, arg := range f.args {
+		if arg.HasDefaultValue() {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatData accepts a blob that represents the packed arguments of this atomic field and
+// returns a string formatting it for human consumption, ie. "(1, 2)". Implements Field.
+func (f *AtomicField) FormatData(data bytes.Buffer, showFieldNames bool) string {
+	return formatData(f, data, showFieldNames)
+}
+
+// ParseString accepts a human readable string (the output of FormatData) and returns a buffer
+// that has the packed data for this atomic field. Implements Field.
+func (f *AtomicField) ParseString(s string) (data bytes.Buffer, err error) {
+	return parseString(f, s)
+}
+
+// implements Stringer interface
+func (f *AtomicField) String() string {
+	return fieldString(f)
+}
+
+// AddField returns nil, molecular fields are composed of existing fields. Use AddComponent instead.
 func (f *MolecularField) AddField(name, typ string) Field {
 	return nil
 }
+
+// AddComponent appends an atomic field or parameter to the components of the molecular field,
+// and adds the component's keywords to the molecular field.  Returns false if the field
+// cannot be a component.
+func (f *MolecularField) AddComponent(field Field) bool {
+	switch field.(type) {
+	case *AtomicField, *Parameter:
+		f.components = append(f.components, field)
+		f.AddKeywords(field)
+		return true
+	default:
+		return false
+	}
+}
+
+// Hash returns a hash of the molecular field's structure. Hash implements the Hashable interface.
+func (f *MolecularField) Hash() uint64 {
+	return hashOf(f)
+}
+
+// NestedFields returns the components of the molecular field. Implements Field.
+func (f *MolecularField) NestedFields() []Field {
+	return f.components
+}
+
+// DefaultValue returns the packed default values of each component. Implements Field.
+func (f *MolecularField) DefaultValue() bytes.Buffer {
+	var buf bytes.Buffer
+	for _, c := range f.components {
+		val := c.DefaultValue()
+		buf.Write(val.Bytes())
+	}
+	return buf
+}
+
+// HasDefaultValue returns whether any component has a default value. Implements Field.
+func (f *MolecularField) HasDefaultValue() bool {
+	for _, c := range f.components {
+		if c.HasDefaultValue() {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatData accepts a blob that represents the packed components of this molecular field and
+// returns a string formatting it for human consumption, ie. "((1, 2), 3)". Implements Field.
+func (f *MolecularField) FormatData(data bytes.Buffer, showFieldNames bool) string {
+	return formatData(f, data, showFieldNames)
+}
+
+// ParseString accepts a human readable string (the output of FormatData) and returns a buffer
+// that has the packed data for this molecular field. Implements Field.
+func (f *MolecularField) ParseString(s string) (data bytes.Buffer, err error) {
+	return parseString(f, s)
+}
+
+// implements Stringer interface
+func (f *MolecularField) String() string {
+	return fieldString(f)
+}
+
+// DataType returns the type of data stored by the parameter. Parameters of an enum type
+// return the enum's underlying integer type.
+func (p *Parameter) DataType() DataType {
+	return p.dataType
+}
+
+// TypeName returns the name of the parameter's type as it would be written in a dclass File,
+// not including any transform, range, or array specifiers.
+func (p *Parameter) TypeName() string {
+	if p.typeName != "" {
+		return p.typeName
+	}
+	return p.dataType.String()
+}
+
+// TypeString returns the type of the parameter as it would be written in a dclass File,
+// including any transform, range, and array specifiers.
+func (p *Parameter) TypeString() string {
+	return parameterType(p)
+}
+
+// Struct returns the struct type of the parameter, or nil if the parameter is not a struct.
+func (p *Parameter) Struct() *Struct {
+	return p.structType
+}
+
+// Enum returns the enum type of the parameter, or nil if the parameter is not an enum.
+func (p *Parameter) Enum() *Enum {
+	return p.enum
+}
+
+// IsArray returns whether the parameter is an array of its DataType.
+func (p *Parameter) IsArray() bool {
+	return p.isArray
+}
+
+// ArraySize returns the number of elements of a fixed-size array parameter, or 0 if the
+// parameter is a variable-length array or not an array.
+func (p *Parameter) ArraySize() int {
+	return p.arraySize
+}
+
+// Hash returns a hash of the parameter's structure. Hash implements the Hashable interface.
+func (p *Parameter) Hash() uint64 {
+	return hashOf(p)
+}
+
+// NestedFields returns nil, a parameter has no nested fields. Implements Field.
+func (p *Parameter) NestedFields() []Field {
+	return nil
+}
+
+// DefaultValue returns the packed default value of the parameter, or the null value of the
+// parameter's type if no default was specified. Implements Field.
+func (p *Parameter) DefaultValue() bytes.Buffer {
+	if p.hasDefVal {
+		return *bytes.NewBuffer(p.defVal.Bytes())
+	}
+
+	var buf bytes.Buffer
+	packZero(p, &buf)
+	return buf
+}
+
+// HasDefaultValue returns whether a default value was specified in the dclass File. Implements Field.
+func (p *Parameter) HasDefaultValue() bool {
+	return p.hasDefVal
+}
+
+// FormatData accepts a blob that represents the packed data for this parameter and returns a
+// string formatting it for human consumption. Implements Field.
+func (p *Parameter) FormatData(data bytes.Buffer, showFieldNames bool) string {
+	return formatData(p, data, showFieldNames)
+}
+
+// ParseString accepts a human readable string (the output of FormatData) and returns a buffer
+// that has the packed data for this parameter. Implements Field.
+func (p *Parameter) ParseString(s string) (data bytes.Buffer, err error) {
+	return parseString(p, s)
+}
+
+// implements Stringer interface
+func (p *Parameter) String() string {
+	return fieldString(p)
+}
@@ -1,18 +1,81 @@
 package dclass
 
+import (
+	"crypto/sha256"
+	"math/big"
+	"sync"
+)
+
 type File struct {
-	Classes []Type  // a list of classes and structs associated with the file
-	Fields  []Field // a list of fields associated with the file
+	Classes []Type   // a list of classes and structs associated with the file
+	Fields  []Field  // a list of fields associated with the file
+	Enums   []*Enum  // a list of enums declared in the file
+	Consts  []*Const // a list of constants declared in the file
+
+	ClassByName map[string]Type   // a map of class names to classes and structs
+	EnumByName  map[string]*Enum  // a map of enum names to enums
+	ConstByName map[string]*Const // a map of constant names to constants
+
+	Warnings ErrorList // warnings reported while parsing the file
+
+	KeywordSet // implements KeywordList, the set of keywords declared by the file
 
-	ClassByName map[string]Type // a map of class names to classes and structs
+	keywordDecls     map[string]*keywordDecl // the declarations of the keywords declared by the file
+	lintSuppressions []lintSuppression       // the lint directives in the source of the file
 
-	keywords // implements KeywordList
+	codecs sync.Map           // the codecs used by Marshal and Unmarshal, by codecKey
+	source *[sha256.Size]byte // digest of the source the file was parsed from, if any
+}
+
+// NewFile returns a new empty dclass File.
+func NewFile() *File {
+	return &File{
+		ClassByName: make(map[string]Type),
+		EnumByName:  make(map[string]*Enum),
+		ConstByName: make(map[string]*Const),
+		KeywordSet:  KeywordSet{table: newKeywordTable()},
+	}
 }
 
-// Hash returns a hash of the file's structure. Hash implements the Hashable interface.
-// TODO: Implement
+// Hash returns a hash of the file's structure, which is compared by clients and servers to
+// verify that they use the same dclass File. Hash implements the Hashable interface.
 func (f *File) Hash() uint64 {
-	return 0
+	return hashOf(f)
+}
+
+// a keywordDecl is the declaration of a keyword in the source of a File.
+type keywordDecl struct {
+	pos Pos    // position of the keyword's name
+	doc string // text of the comments attached to the declaration
+}
+
+// KeywordPos returns the position of the declaration of a keyword in the source file, or the
+// zero Pos if the keyword was not declared in source.
+func (f *File) KeywordPos(keyword string) Pos {
+	if decl, ok := f.keywordDecls[keyword]; ok {
+		return decl.pos
+	}
+	return Pos{}
+}
+
+// KeywordDoc returns the text of the comments attached to the declaration of a keyword, or an
+// empty string if the keyword was not declared with comments.
+func (f *File) KeywordDoc(keyword string) string {
+	if decl, ok := f.keywordDecls[keyword]; ok {
+		return decl.doc
+	}
+	return ""
+}
+
+// keywordDecl returns the declaration of a keyword, adding it if the keyword has none yet.
+func (f *File) keywordDecl(keyword string) *keywordDecl {
+	if f.keywordDecls == nil {
+		f.keywordDecls = make(map[string]*keywordDecl)
+	}
+	if f.keywordDecls[keyword] == nil {
+		f.keywordDecls[keyword] = new(keywordDecl)
+	}
+	return f.keywordDecls[keyword]
 }
 
 // AddType returns a new Type initialized with a name and unique index within the dclass file.
@@ -40,9 +103,31 @@ func (f *File) AddType(name, typ string) Type {
 	}
 }
 
+// AddEnum returns a new Enum initialized with a name, underlying integer type, and unique index
+// within the dclass file.  Returns nil if typ is not an integer type.
+func (f *File) AddEnum(name string, typ DataType) *Enum {
+	if !typ.IsInteger() {
+		return nil
+	}
+
+	e := &Enum{dcf: f, name: name, index: len(f.Enums), dataType: typ}
+	f.Enums = append(f.Enums, e)
+	f.EnumByName[name] = e
+	return e
+}
+
+// AddConst declares a new named constant in the dclass file.  The typ argument may be
+// InvalidType for an untyped constant.
+func (f *File) AddConst(name string, typ DataType, value *big.Rat) *Const {
+	c := &Const{Name: name, DataType: typ, Value: new(big.Rat).Set(value)}
+	f.Consts = append(f.Consts, c)
+	f.ConstByName[name] = c
+	return c
+}
+
 // addField is called by classes and structs to add a new field to the file
 // returns the unique index of the field
-// TODO: Implement
-func (f *File) addField(field *Field) int {
-	return 0
+func (f *File) addField(field Field) int {
+	f.Fields = append(f.Fields, field)
+	return len(f.Fields) - 1
 }
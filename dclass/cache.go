@@ -0,0 +1,433 @@
+package dclass
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"runtime/debug"
+)
+
+// A cache of a File holds the File's schema in a compact binary encoding.  It starts with a
+// header recording the versions of the cache format and of this library, and the digest of the
+// source the File was parsed from, so that a cache is only loaded for the same source by the
+// same code that wrote it.
+//
+// Integers are written as varints, and strings as a varint index into the strings written so
+// far, or 0 followed by the length and bytes of a new string.  Lists are written as their length
+// followed by their elements, and optional values as a bool followed by the value if present.
+
+const (
+	cacheMagic   = "dclass-cache\x00"
+	cacheVersion = 4 // incremented whenever the cache format or the parsed model changes
+
+	modulePath = "github.com/Astron/astron.libgo"
+)
+
+// WriteCache writes a compact binary encoding of the File to w, which LoadCache can read faster
+// than the File's source can be parsed.  Only files returned by Parse, or loaded by LoadCache,
+// can be cached.
+func (f *File) WriteCache(w io.Writer) error {
+	if f.source == nil {
+		return Error("cannot cache a dclass File which was not parsed from source")
+	}
+
+	cw := cacheWriter{strings: make(map[string]uint64)}
+	cw.buf.WriteString(cacheMagic)
+	cw.uint(cacheVersion)
+	cw.string(libraryVersion())
+	cw.buf.Write(f.source[:])
+	cw.schema(ExportSchema(f))
+	cw.lintSuppressions(f.lintSuppressions)
+
+	_, err := w.Write(cw.buf.Bytes())
+	return err
+}
+
+// LoadCache returns the File cached in r if it was written from the source src by this version
+// of the library.  Otherwise src is parsed with the default ParseOptions.  The returned bool
+// reports whether the cache was used, so that callers can replace a stale cache.
+func LoadCache(r io.Reader, src []byte) (*File, bool, error) {
+	digest := sha256.Sum256(src)
+	if dcf := loadCache(r, digest); dcf != nil {
+		return dcf, true, nil
+	}
+
+	dcf, err := Parse(bytes.NewReader(src))
+	return dcf, false, err
+}
+
+// loadCache reads the File cached in r, or returns nil if the cache cannot be used.
+func loadCache(r io.Reader, digest [sha256.Size]byte) *File {
+	if r == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.HasPrefix(data, []byte(cacheMagic)) {
+		return nil
+	}
+
+	cr := cacheReader{data: data[len(cacheMagic):]}
+	if cr.uint() != cacheVersion || cr.string() != libraryVersion() ||
+		!bytes.Equal(cr.bytes(sha256.Size), digest[:]) {
+		return nil
+	}
+	s := cr.schema()
+	suppressions := cr.lintSuppressions()
+	if cr.err != nil || len(cr.data) > 0 {
+		return nil
+	}
+
+	dcf, err := ImportSchema(s)
+	if err != nil {
+		return nil
+	}
+	dcf.source = &digest
+	dcf.lintSuppressions = suppressions
+	return dcf
+}
+
+// libraryVersion returns the version of this library recorded in the running binary.
+// Development builds have the version "(devel)", and rely on the cache version.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// a cacheWriter encodes a Schema into buf.
+type cacheWriter struct {
+	buf     bytes.Buffer
+	strings map[string]uint64 // index of each string written so far, starting at 1
+}
+
+func (w *cacheWriter) uint(n uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], n)])
+}
+
+func (w *cacheWriter) int(n int) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutVarint(b[:], int64(n))])
+}
+
+func (w *cacheWriter) bool(b bool) {
+	if b {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *cacheWriter) string(s string) {
+	if i, ok := w.strings[s]; ok {
+		w.uint(i)
+		return
+	}
+	w.strings[s] = uint64(len(w.strings) + 1)
+	w.uint(0)
+	w.uint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *cacheWriter) stringList(list []string) {
+	w.uint(uint64(len(list)))
+	for _, s := range list {
+		w.string(s)
+	}
+}
+
+func (w *cacheWriter) schema(s *Schema) {
+	w.uint(s.Hash)
+	w.stringList(s.Keywords)
+	for _, keyword := range s.Keywords {
+		w.string(s.KeywordDocs[keyword])
+		w.pos(s.KeywordPositions[keyword])
+	}
+
+	w.uint(uint64(len(s.Consts)))
+	for _, c := range s.Consts {
+		w.string(c.Name)
+		w.string(c.Type)
+		w.string(string(c.Value))
+		w.pos(c.Pos)
+	}
+
+	w.uint(uint64(len(s.Enums)))
+	for _, e := range s.Enums {
+		w.string(e.Name)
+		w.string(e.Type)
+		w.uint(uint64(len(e.Values)))
+		for _, v := range e.Values {
+			w.string(v.Name)
+			w.string(string(v.Value))
+		}
+		w.pos(e.Pos)
+	}
+
+	w.uint(uint64(len(s.Types)))
+	for _, t := range s.Types {
+		w.string(t.Kind)
+		w.string(t.Name)
+		w.int(t.ID)
+		w.stringList(t.Parents)
+		w.pos(t.Pos)
+		w.string(t.Doc)
+		w.fields(t.Fields)
+	}
+}
+
+func (w *cacheWriter) pos(pos SchemaPos) {
+	w.int(pos.Line)
+	w.int(pos.Column)
+}
+
+func (w *cacheWriter) lintSuppressions(list []lintSuppression) {
+	w.uint(uint64(len(list)))
+	for _, s := range list {
+		w.int(s.line)
+		w.stringList(s.rules)
+		w.bool(s.file)
+	}
+}
+
+func (w *cacheWriter) fields(fields []SchemaField) {
+	w.uint(uint64(len(fields)))
+	for _, f := range fields {
+		w.string(f.Kind)
+		w.string(f.Name)
+		w.int(f.ID)
+		w.stringList(f.Keywords)
+		w.pos(f.Pos)
+		w.string(f.Doc)
+		w.fields(f.Args)
+		w.stringList(f.Components)
+
+		w.bool(f.SchemaParameter != nil)
+		if p := f.SchemaParameter; p != nil {
+			w.string(p.Type)
+			w.string(p.DataType)
+			w.uint(uint64(len(p.Transform)))
+			for _, op := range p.Transform {
+				w.string(op.Operator)
+				w.uint(math.Float64bits(op.Operand))
+			}
+			w.schemaRange(p.Range)
+			w.bool(p.Array != nil)
+			if p.Array != nil {
+				w.int(p.Array.Size)
+				w.schemaRange(p.Array.Range)
+			}
+			w.uint(uint64(len(p.Default)))
+			w.buf.Write(p.Default)
+		}
+	}
+}
+
+func (w *cacheWriter) schemaRange(rng *SchemaRange) {
+	w.bool(rng != nil)
+	if rng != nil {
+		w.string(string(rng.Min))
+		w.string(string(rng.Max))
+	}
+}
+
+// a cacheReader decodes a Schema from data.  The first error is kept in err, after which each
+// read returns a zero value.
+type cacheReader struct {
+	data    []byte
+	strings []string
+	err     error
+}
+
+func (r *cacheReader) fail() {
+	if r.err == nil {
+		r.err = Error("invalid dclass cache")
+	}
+	r.data = nil
+}
+
+func (r *cacheReader) uint() uint64 {
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[size:]
+	return n
+}
+
+func (r *cacheReader) int() int {
+	n, size := binary.Varint(r.data)
+	if size <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[size:]
+	return int(n)
+}
+
+// len reads the length of a list, which cannot be longer than the remaining data.
+func (r *cacheReader) len() int {
+	n := r.uint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *cacheReader) bool() bool {
+	return r.uint() != 0
+}
+
+func (r *cacheReader) bytes(n int) []byte {
+	if n > len(r.data) {
+		r.fail()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *cacheReader) string() string {
+	i := r.uint()
+	if i == 0 {
+		s := string(r.bytes(r.len()))
+		r.strings = append(r.strings, s)
+		return s
+	}
+	if i > uint64(len(r.strings)) {
+		r.fail()
+		return ""
+	}
+	return r.strings[i-1]
+}
+
+func (r *cacheReader) stringList() []string {
+	n := r.len()
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, n)
+	for i := range list {
+		list[i] = r.string()
+	}
+	return list
+}
+
+func (r *cacheReader) schema() *Schema {
+	s := &Schema{Format: SchemaFormat, Version: SchemaVersion}
+	s.Hash = r.uint()
+	s.Keywords = r.stringList()
+	for _, keyword := range s.Keywords {
+		if doc := r.string(); doc != "" {
+			if s.KeywordDocs == nil {
+				s.KeywordDocs = make(map[string]string)
+			}
+			s.KeywordDocs[keyword] = doc
+		}
+		if pos := r.pos(); pos.Line != 0 {
+			if s.KeywordPositions == nil {
+				s.KeywordPositions = make(map[string]SchemaPos)
+			}
+			s.KeywordPositions[keyword] = pos
+		}
+	}
+
+	s.Consts = make([]SchemaConst, r.len())
+	for i := range s.Consts {
+		s.Consts[i] = SchemaConst{r.string(), r.string(), json.Number(r.string()), r.pos()}
+	}
+
+	s.Enums = make([]SchemaEnum, r.len())
+	for i := range s.Enums {
+		e := &s.Enums[i]
+		e.Name, e.Type = r.string(), r.string()
+		e.Values = make([]SchemaEnumValue, r.len())
+		for j := range e.Values {
+			e.Values[j] = SchemaEnumValue{r.string(), json.Number(r.string())}
+		}
+		e.Pos = r.pos()
+	}
+
+	s.Types = make([]SchemaType, r.len())
+	for i := range s.Types {
+		t := &s.Types[i]
+		t.Kind, t.Name, t.ID = r.string(), r.string(), r.int()
+		t.Parents = r.stringList()
+		t.Pos = r.pos()
+		t.Doc = r.string()
+		t.Fields = r.fields()
+	}
+	return s
+}
+
+func (r *cacheReader) pos() SchemaPos {
+	return SchemaPos{r.int(), r.int()}
+}
+
+func (r *cacheReader) lintSuppressions() []lintSuppression {
+	list := make([]lintSuppression, r.len())
+	for i := range list {
+		list[i] = lintSuppression{line: r.int(), rules: r.stringList(), file: r.bool()}
+	}
+	return list
+}
+
+func (r *cacheReader) fields() []SchemaField {
+	n := r.len()
+	if n == 0 {
+		return nil
+	}
+	fields := make([]SchemaField, n)
+	for i := range fields {
+		f := &fields[i]
+		f.Kind, f.Name, f.ID = r.string(), r.string(), r.int()
+		f.Keywords = r.stringList()
+		f.Pos = r.pos()
+		f.Doc = r.string()
+		f.Args = r.fields()
+		f.Components = r.stringList()
+
+		if r.bool() {
+			p := &SchemaParameter{Type: r.string(), DataType: r.string()}
+			for j := r.len(); j > 0; j-- {
+				p.Transform = append(p.Transform, SchemaTransformOp{r.string(), math.Float64frombits(r.uint())})
+			}
+			p.Range = r.schemaRange()
+			if r.bool() {
+				p.Array = &SchemaArray{Size: r.int(), Range: r.schemaRange()}
+			}
+			if n := r.len(); n > 0 {
+				p.Default = json.RawMessage(r.bytes(n))
+			}
+			f.SchemaParameter = p
+		}
+	}
+	return fields
+}
+
+func (r *cacheReader) schemaRange() *SchemaRange {
+	if !r.bool() {
+		return nil
+	}
+	return &SchemaRange{json.Number(r.string()), json.Number(r.string())}
+}
@@ -0,0 +1,73 @@
+package dclass
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	src := []byte(caseSchema + `
+dclass Shadow : Child {
+	uint8 initial;
+};
+`)
+	dcf := mustParse(t, string(src))
+
+	var cache bytes.Buffer
+	if err := dcf.WriteCache(&cache); err != nil {
+		t.Fatalf("unexpected error writing cache: %s", err)
+	}
+	data := cache.Bytes()
+
+	cached, ok, err := LoadCache(bytes.NewReader(data), src)
+	if err != nil || !ok {
+		t.Fatalf("expected cache to be used, found %v, %v", ok, err)
+	}
+	if cached.Hash() != dcf.Hash() {
+		t.Errorf("cached file has hash %d, expected %d", cached.Hash(), dcf.Hash())
+	}
+	var printed, expected bytes.Buffer
+	Print(&printed, cached)
+	Print(&expected, dcf)
+	if printed.String() != expected.String() {
+		t.Errorf("cached file printed as:\n%s\nexpected:\n%s", printed.String(), expected.String())
+	}
+	checkPositions(t, "cached", cached, dcf)
+	if len(cached.Warnings) != 1 || cached.Warnings[0].Error() != dcf.Warnings[0].Error() {
+		t.Errorf("cached file has warnings %v, expected %v", cached.Warnings, dcf.Warnings)
+	}
+
+	// a file loaded from the cache can be cached again
+	var again bytes.Buffer
+	if err := cached.WriteCache(&again); err != nil || !bytes.Equal(again.Bytes(), data) {
+		t.Errorf("rewriting the cache produced different data, error %v", err)
+	}
+
+	// stale or invalid caches are replaced by parsing the source
+	changed := append(append([]byte{}, src...), "dclass Extra {};\n"...)
+	for _, test := range []struct {
+		name  string
+		cache []byte
+		src   []byte
+	}{
+		{"changed source", data, changed},
+		{"truncated cache", data[:len(data)/2], src},
+		{"invalid cache", []byte("not a cache"), src},
+		{"empty cache", nil, src},
+	} {
+		dcf, ok, err := LoadCache(bytes.NewReader(test.cache), test.src)
+		if err != nil || ok {
+			t.Errorf("%s: expected source to be parsed, found %v, %v", test.name, ok, err)
+		} else if strings.Contains(string(test.src), "Extra") && dcf.ClassByName["Extra"] == nil {
+			t.Errorf("%s: file parsed from source is missing dclass Extra", test.name)
+		}
+	}
+
+	if _, _, err := LoadCache(nil, []byte("dclass {")); err == nil {
+		t.Errorf("expected error parsing invalid source")
+	}
+	if err := NewFile().WriteCache(&cache); err == nil {
+		t.Errorf("expected error caching a file which was not parsed")
+	}
+}
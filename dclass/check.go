@@ -0,0 +1,272 @@
+package dclass
+
+import "strings"
+
+// Check performs the semantic checks on the File which can only be done once the whole file has
+// been read, such as detecting recursive structs, inheritance cycles, and duplicate field names.
+// Check is called by Parse; files built with AddType and AddField should be checked before they
+// are used.  The options select which diagnostics are reported as errors or warnings.
+func (f *File) Check(opts ParseOptions) (errs ErrorList, warnings ErrorList) {
+	errs = append(errs, f.checkStructCycles()...)
+	errs = append(errs, f.checkInheritanceCycles()...)
+	errs = append(errs, f.checkDuplicateNames()...)
+
+	switch opts.Shadowing {
+	case ShadowWarn:
+		warnings = append(warnings, f.checkShadowedFields(semanticWarning)...)
+	case ShadowError:
+		errs = append(errs, f.checkShadowedFields(semanticError)...)
+	}
+	return errs, warnings
+}
+
+// a structStep is a parameter of a struct which contains another struct by value
+type structStep struct {
+	owner *Struct
+	param *Parameter
+}
+
+// checkStructCycles reports structs which contain themselves by value, either directly or through
+// other structs.  A struct may only contain itself through a variable-length array, which can be
+// empty, as any other containment would make the struct infinitely large.
+func (f *File) checkStructCycles() ErrorList {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var errs ErrorList
+	var path []structStep
+	state := make(map[*Struct]int)
+
+	var visit func(s *Struct)
+	visit = func(s *Struct) {
+		state[s] = visiting
+		for _, field := range s.fields {
+			param, ok := field.(*Parameter)
+			if !ok || param.structType == nil || mayBeEmpty(param) {
+				continue
+			}
+
+			path = append(path, structStep{s, param})
+			switch state[param.structType] {
+			case unvisited:
+				visit(param.structType)
+			case visiting:
+				// the cycle is the part of the path starting at the contained struct
+				start := 0
+				for path[start].owner != param.structType {
+					start++
+				}
+				errs = append(errs, structCycleError(path[start:]))
+			}
+			path = path[:len(path)-1]
+		}
+		state[s] = visited
+	}
+
+	for _, typ := range f.Classes {
+		if s, ok := typ.(*Struct); ok && state[s] == unvisited {
+			visit(s)
+		}
+	}
+	return errs
+}
+
+// mayBeEmpty returns whether the parameter is an array which may have no elements, and so does
+// not contain its element type by value: an array without a size, or with a range of sizes
+// starting at 0.
+func mayBeEmpty(p *Parameter) bool {
+	if !p.isArray || p.arraySize != 0 {
+		return false
+	}
+	min, _, ok := rangeBounds(p.ArrayRange)
+	return !ok || min.Sign() == 0
+}
+
+func structCycleError(cycle []structStep) *PosError {
+	head := cycle[0].owner
+	steps := make([]string, 0, len(cycle)+1)
+	for _, step := range cycle {
+		steps = append(steps, step.owner.name+"."+step.param.name+" ("+step.param.pos.String()+")")
+	}
+	steps = append(steps, head.name)
+
+	msg := "struct " + head.name + " (" + head.pos.String() + ") contains itself by value: " +
+		strings.Join(steps, " -> ") + "; a struct may only contain itself through a variable-length array"
+	return semanticError(msg, cycle[0].param.pos)
+}
+
+// checkInheritanceCycles reports classes which inherit from themselves, either directly or
+// through other classes.
+func (f *File) checkInheritanceCycles() ErrorList {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var errs ErrorList
+	var path []*Class
+	state := make(map[*Class]int)
+
+	var visit func(c *Class)
+	visit = func(c *Class) {
+		state[c] = visiting
+		path = append(path, c)
+		for _, parent := range c.parents {
+			switch state[parent] {
+			case unvisited:
+				visit(parent)
+			case visiting:
+				start := 0
+				for path[start] != parent {
+					start++
+				}
+				errs = append(errs, inheritanceCycleError(path[start:]))
+			}
+		}
+		path = path[:len(path)-1]
+		state[c] = visited
+	}
+
+	for _, typ := range f.Classes {
+		if c, ok := typ.(*Class); ok && state[c] == unvisited {
+			visit(c)
+		}
+	}
+	return errs
+}
+
+func inheritanceCycleError(cycle []*Class) *PosError {
+	head := cycle[0]
+	steps := make([]string, 0, len(cycle)+1)
+	for _, c := range cycle {
+		steps = append(steps, c.name+" ("+c.pos.String()+")")
+	}
+	steps = append(steps, head.name)
+
+	msg := "dclass " + head.name + " inherits from itself: " + strings.Join(steps, " -> ")
+	return semanticError(msg, head.pos)
+}
+
+// checkDuplicateNames reports fields with the same name declared by the same class or struct,
+// and arguments with the same name within an atomic field.
+func (f *File) checkDuplicateNames() ErrorList {
+	var errs ErrorList
+	for _, typ := range f.Classes {
+		kind := "struct"
+		if _, ok := typ.(*Class); ok {
+			kind = "dclass"
+		}
+
+		declared := make(map[string]Field)
+		for _, field := range typ.Fields() {
+			if prev, ok := declared[field.Name()]; ok {
+				errs = append(errs, semanticError("field '"+field.Name()+"' ("+field.Pos().String()+
+					") is already declared by "+kind+" "+typ.Name()+" at "+prev.Pos().String(), field.Pos()))
+			} else {
+				declared[field.Name()] = field
+			}
+
+			if atomic, ok := field.(*AtomicField); ok {
+				errs = append(errs, checkDuplicateArgs(atomic)...)
+			}
+		}
+	}
+	return errs
+}
+
+// checkDuplicateArgs reports arguments of an atomic field with the same name.
+func checkDuplicateArgs(atomic *AtomicField) ErrorList {
+	var errs ErrorList
+	declared := make(map[string]Field)
+	for _, arg := range atomic.args {
+		if arg.Name() == "" {
+			continue
+		}
+		if prev, ok := declared[arg.Name()]; ok {
+			errs = append(errs, semanticError("argument '"+arg.Name()+"' of '"+atomic.name+"' ("+
+				arg.Pos().String()+") has the same name as the argument at "+prev.Pos().String(), arg.Pos()))
+		} else {
+			declared[arg.Name()] = arg
+		}
+	}
+	return errs
+}
+
+// checkShadowedFields reports fields which shadow a field inherited from a parent class with a
+// different signature.  A field with the same signature overrides the inherited field.
+// The diagnostic argument creates either an error or a warning.
+func (f *File) checkShadowedFields(diagnostic func(msg string, pos Pos) *PosError) ErrorList {
+	var diags ErrorList
+	for _, typ := range f.Classes {
+		c, ok := typ.(*Class)
+		if !ok {
+			continue
+		}
+
+		for _, field := range c.fields {
+			inherited, owner := c.inheritedField(field.Name())
+			if inherited == nil {
+				continue
+			}
+
+			sig, inheritedSig := fieldSignature(field), fieldSignature(inherited)
+			if sig != inheritedSig {
+				diags = append(diags, diagnostic("field '"+field.Name()+"' of dclass "+c.name+" ("+
+					field.Pos().String()+") shadows '"+inherited.Name()+"' inherited from dclass "+
+					owner.name+" ("+inherited.Pos().String()+") with a different signature: "+
+					sig+" vs "+inheritedSig, field.Pos()))
+			}
+		}
+	}
+	return diags
+}
+
+// inheritedField returns the field with the given name inherited from one of the parents of the
+// class, and the class which declared it.
+func (c *Class) inheritedField(name string) (Field, *Class) {
+	visited := map[*Class]bool{c: true}
+	var search func(c *Class) (Field, *Class)
+	search = func(c *Class) (Field, *Class) {
+		for _, parent := range c.parents {
+			if visited[parent] {
+				continue
+			}
+			visited[parent] = true
+
+			if f := parent.typeBase.FieldByName(name); f != nil {
+				return f, parent
+			}
+			if f, owner := search(parent); f != nil {
+				return f, owner
+			}
+		}
+		return nil, nil
+	}
+	return search(c)
+}
+
+// fieldSignature returns a description of the field's name and types, ignoring keywords,
+// default values, and argument names.  Fields with the same signature are packed identically.
+func fieldSignature(f Field) string {
+	switch field := f.(type) {
+	case *Parameter:
+		return parameterType(field) + " " + field.name
+	case *AtomicField:
+		args := make([]string, len(field.args))
+		for i, arg := range field.args {
+			args[i] = parameterType(arg.(*Parameter))
+		}
+		return field.name + "(" + strings.Join(args, ", ") + ")"
+	case *MolecularField:
+		components := make([]string, len(field.components))
+		for i, c := range field.components {
+			components[i] = c.Name()
+		}
+		return field.name + " : " + strings.Join(components, ", ")
+	}
+	return f.Name()
+}
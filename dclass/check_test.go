@@ -0,0 +1,145 @@
+package dclass
+
+import (
+	"strings"
+	"testing"
+)
+
+var cycleTests = []struct {
+	name     string
+	input    string
+	expected string // a substring of the expected error, or empty if no error is expected
+}{
+	{"struct contains itself", "struct A { uint8 x; A a; };",
+		"struct A (1:8) contains itself by value: A.a (1:23) -> A"},
+	{"mutual structs", "struct A { B b; };\nstruct B { A a; };",
+		"A.b (1:14) -> B.a (2:14) -> A"},
+	{"fixed array of itself", "struct A { A[2] a; };",
+		"struct A (1:8) contains itself by value"},
+	{"variable array of itself", "struct A { A[] children; };", ""},
+	{"sized array of itself", "struct A { A[0-4] children; };", ""},
+	{"non-empty sized array of itself", "struct A { A[2-4] a; };",
+		"struct A (1:8) contains itself by value"},
+	{"inherits from itself", "dclass A : A {};",
+		"dclass A inherits from itself: A (1:8) -> A"},
+	{"inheritance cycle", "dclass A : C {};\ndclass B : A {};\ndclass C : B {};",
+		"A (1:8) -> C (3:8) -> B (2:8) -> A"},
+	{"diamond inheritance", "dclass A {};\ndclass B : A {};\ndclass C : A {};\ndclass D : B, C {};", ""},
+}
+
+func TestCycles(t *testing.T) {
+	for _, test := range cycleTests {
+		_, err := Parse(strings.NewReader(test.input))
+		switch {
+		case test.expected == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.expected != "" && err == nil:
+			t.Errorf("%s: expected an error", test.name)
+		case test.expected != "" && !strings.Contains(err.Error(), test.expected):
+			t.Errorf("%s: expected error containing %q, got\n%v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestInheritedFields(t *testing.T) {
+	dcf := mustParse(t, `
+		dclass Late : Parent, Early {};
+		dclass Early {
+			setX(int8 x);
+		};
+		dclass Parent : Early {
+			setY(int8 y);
+			setXY : setX, setY;
+		};
+	`)
+
+	late := dcf.ClassByName["Late"].(*Class)
+	parents := late.Parents()
+	if len(parents) != 2 || parents[0] != dcf.ClassByName["Parent"] || parents[1] != dcf.ClassByName["Early"] {
+		t.Fatalf("Late: got parents %v", parents)
+	}
+	for _, name := range []string{"setX", "setY", "setXY"} {
+		if late.FieldByName(name) == nil {
+			t.Errorf("Late: expected inherited field %s", name)
+		}
+	}
+}
+
+var duplicateTests = []struct {
+	name     string
+	input    string
+	expected string // a substring of the expected error, or empty if no error is expected
+}{
+	{"duplicate struct field", "struct A {\n\tuint8 x;\n\tint16 x;\n};",
+		"field 'x' (3:8) is already declared by struct A at 2:8"},
+	{"duplicate class field", "dclass A {\n\tsetX(uint8);\n\tsetX(int8);\n};",
+		"field 'setX' (3:2) is already declared by dclass A at 2:2"},
+	{"duplicate argument", "dclass A {\n\tsetXY(uint8 x, uint8 x);\n};",
+		"argument 'x' of 'setXY' (2:23) has the same name as the argument at 2:14"},
+	{"unnamed arguments", "dclass A {\n\tsetXY(uint8, uint8);\n};", ""},
+	{"override", "dclass A {\n\tsetX(uint8 x);\n};\ndclass B : A {\n\tsetX(uint8 y) broadcast;\n};", ""},
+}
+
+func TestDuplicateNames(t *testing.T) {
+	for _, test := range duplicateTests {
+		_, err := Parse(strings.NewReader(test.input))
+		switch {
+		case test.expected == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.expected != "" && err == nil:
+			t.Errorf("%s: expected an error", test.name)
+		case test.expected != "" && !strings.Contains(err.Error(), test.expected):
+			t.Errorf("%s: expected error containing %q, got\n%v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestShadowedFields(t *testing.T) {
+	input := "dclass A {\n\tsetX(uint8 x);\n};\ndclass B : A {\n\tsetX(int16 x);\n};"
+	expected := "field 'setX' of dclass B (5:2) shadows 'setX' inherited from dclass A (2:2) " +
+		"with a different signature: setX(int16) vs setX(uint8)"
+
+	dcf, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(dcf.Warnings) != 1 || !strings.Contains(dcf.Warnings[0].Error(), expected) {
+		t.Errorf("expected a warning containing %q, got %v", expected, dcf.Warnings)
+	}
+
+	dcf, err = ParseWithOptions(strings.NewReader(input), ParseOptions{Shadowing: ShadowIgnore})
+	if err != nil || len(dcf.Warnings) != 0 {
+		t.Errorf("ShadowIgnore: got warnings %v, error %v", dcf.Warnings, err)
+	}
+
+	_, err = ParseWithOptions(strings.NewReader(input), ParseOptions{Shadowing: ShadowError})
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("ShadowError: expected an error containing %q, got %v", expected, err)
+	}
+}
+
+func TestInheritedFieldOrder(t *testing.T) {
+	dcf := mustParse(t, `
+		dclass A {
+			setX(uint8 x);
+			setY(uint8 y);
+		};
+		dclass B {
+			setY(uint8 y);
+			setZ(uint8 z);
+		};
+		dclass C : A, B {
+			setX(uint8 x) broadcast;
+			setW(uint8 w);
+		};
+	`)
+
+	var names []string
+	for _, f := range dcf.ClassByName["C"].(*Class).InheritedFields() {
+		names = append(names, f.Name()+":"+strings.Join(f.Keywords(), " "))
+	}
+	expected := "setX:broadcast setY: setZ: setW:"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("expected fields %q, got %q", expected, got)
+	}
+}
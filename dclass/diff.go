@@ -0,0 +1,401 @@
+package dclass
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// A Compatibility classifies how a change between two versions of a dclass File affects
+// existing clients and stored database values.  Compatibilities are ordered by severity.
+type Compatibility int
+
+const (
+	// WireCompatible changes do not affect the packing of existing fields.
+	WireCompatible Compatibility = iota
+
+	// DBIncompatible changes may invalidate values stored in a database, but do not affect
+	// messages sent by existing clients.
+	DBIncompatible
+
+	// ClientBreaking changes affect the messages sent or expected by existing clients.
+	ClientBreaking
+)
+
+var compatibilityName = map[Compatibility]string{
+	WireCompatible: "wire-compatible",
+	DBIncompatible: "db-incompatible",
+	ClientBreaking: "client-breaking",
+}
+
+// implements Stringer interface
+func (c Compatibility) String() string {
+	return compatibilityName[c]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (c Compatibility) MarshalText() ([]byte, error) {
+	if name, ok := compatibilityName[c]; ok {
+		return []byte(name), nil
+	}
+	return nil, Error("invalid compatibility " + strconv.Itoa(int(c)))
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (c *Compatibility) UnmarshalText(text []byte) error {
+	for compat, name := range compatibilityName {
+		if name == string(text) {
+			*c = compat
+			return nil
+		}
+	}
+	return Error("unknown compatibility " + strconv.Quote(string(text)))
+}
+
+// A ChangeKind describes what changed between two versions of a dclass File.
+type ChangeKind string
+
+const (
+	ClassAdded       ChangeKind = "class-added"
+	ClassRemoved     ChangeKind = "class-removed"
+	ClassRenumbered  ChangeKind = "class-renumbered"
+	ParentsChanged   ChangeKind = "parents-changed"
+	FieldAdded       ChangeKind = "field-added"
+	FieldRemoved     ChangeKind = "field-removed"
+	FieldRenumbered  ChangeKind = "field-renumbered"
+	TypeChanged      ChangeKind = "type-changed"
+	RangeNarrowed    ChangeKind = "range-narrowed"
+	RangeWidened     ChangeKind = "range-widened"
+	TransformChanged ChangeKind = "transform-changed"
+	DefaultChanged   ChangeKind = "default-changed"
+	KeywordAdded     ChangeKind = "keyword-added"
+	KeywordRemoved   ChangeKind = "keyword-removed"
+	EnumAdded        ChangeKind = "enum-added"
+	EnumRemoved      ChangeKind = "enum-removed"
+	EnumValueAdded   ChangeKind = "enum-value-added"
+	EnumValueRemoved ChangeKind = "enum-value-removed"
+	EnumValueChanged ChangeKind = "enum-value-changed"
+)
+
+// A Change is a single difference between two versions of a dclass File.
+type Change struct {
+	Kind          ChangeKind    `json:"kind"`
+	Compatibility Compatibility `json:"compatibility"`
+	Path          string        `json:"path"`          // the changed declaration, ie. "Avatar.setPos.x"
+	Old           string        `json:"old,omitempty"` // the old declaration or value, if any
+	New           string        `json:"new,omitempty"` // the new declaration or value, if any
+}
+
+// implements Stringer interface
+func (c Change) String() string {
+	s := c.Compatibility.String() + ": " + c.Path + ": " + string(c.Kind)
+	switch {
+	case c.Old != "" && c.New != "":
+		s += ": " + c.Old + " -> " + c.New
+	case c.Old != "":
+		s += ": " + c.Old
+	case c.New != "":
+		s += ": " + c.New
+	}
+	return s
+}
+
+// A ChangeList is the list of changes between two versions of a dclass File.
+type ChangeList []Change
+
+// Compatibility returns the most severe compatibility of the changes, or WireCompatible if
+// there are no changes.
+func (list ChangeList) Compatibility() Compatibility {
+	compat := WireCompatible
+	for _, c := range list {
+		if c.Compatibility > compat {
+			compat = c.Compatibility
+		}
+	}
+	return compat
+}
+
+// Diff returns the changes from the old to the new version of a dclass File.  Classes, structs,
+// enums, and fields are matched by name, and atomic field arguments by position.
+//
+// Changes which affect the packing of a field or the numbering of classes and fields break
+// existing clients.  Changes which only constrain the values of a field, such as narrowing a
+// range or changing the db keyword, are incompatible with values stored in a database.
+func Diff(old, new *File) ChangeList {
+	d := differ{}
+	d.diffEnums(old, new)
+	d.diffTypes(old, new)
+	return d.changes
+}
+
+// a differ accumulates the changes between two Files.
+type differ struct {
+	changes ChangeList
+}
+
+func (d *differ) add(kind ChangeKind, compat Compatibility, path, old, new string) {
+	d.changes = append(d.changes, Change{kind, compat, path, old, new})
+}
+
+func (d *differ) diffEnums(old, new *File) {
+	for _, oldEnum := range old.Enums {
+		newEnum, ok := new.EnumByName[oldEnum.name]
+		if !ok {
+			d.add(EnumRemoved, WireCompatible, oldEnum.name, "", "")
+			continue
+		}
+		if oldEnum.dataType != newEnum.dataType {
+			d.add(TypeChanged, ClientBreaking, oldEnum.name, oldEnum.dataType.String(),
+				newEnum.dataType.String())
+		}
+
+		for _, v := range oldEnum.values {
+			path := oldEnum.name + "." + v.Name
+			if n, ok := newEnum.ValueByName(v.Name); !ok {
+				d.add(EnumValueRemoved, ClientBreaking, path, v.Value.String(), "")
+			} else if n.Cmp(v.Value) != 0 {
+				d.add(EnumValueChanged, ClientBreaking, path, v.Value.String(), n.String())
+			}
+		}
+		for _, v := range newEnum.values {
+			if _, ok := oldEnum.ValueByName(v.Name); !ok {
+				d.add(EnumValueAdded, WireCompatible, newEnum.name+"."+v.Name, "", v.Value.String())
+			}
+		}
+	}
+	for _, newEnum := range new.Enums {
+		if _, ok := old.EnumByName[newEnum.name]; !ok {
+			d.add(EnumAdded, WireCompatible, newEnum.name, "", "")
+		}
+	}
+}
+
+func (d *differ) diffTypes(old, new *File) {
+	for _, oldType := range old.Classes {
+		newType, ok := new.ClassByName[oldType.Name()]
+		_, wasClass := oldType.(*Class)
+		_, isClass := newType.(*Class)
+		switch {
+		case !ok && wasClass:
+			d.add(ClassRemoved, ClientBreaking, oldType.Name(), "", "")
+		case !ok:
+			// An unused struct may be removed; any parameters using it are reported as changed.
+			d.add(ClassRemoved, WireCompatible, oldType.Name(), "", "")
+		case wasClass != isClass:
+			d.add(TypeChanged, ClientBreaking, oldType.Name(), typeKind(oldType), typeKind(newType))
+		case isClass:
+			d.diffClass(oldType.(*Class), newType.(*Class))
+		default:
+			d.diffStruct(oldType.(*Struct), newType.(*Struct))
+		}
+	}
+	for _, newType := range new.Classes {
+		if _, ok := old.ClassByName[newType.Name()]; !ok {
+			d.add(ClassAdded, WireCompatible, newType.Name(), "", typeKind(newType))
+		}
+	}
+}
+
+func typeKind(typ Type) string {
+	if _, ok := typ.(*Class); ok {
+		return "dclass"
+	}
+	return "struct"
+}
+
+func (d *differ) diffClass(old, new *Class) {
+	if old.index != new.index {
+		d.add(ClassRenumbered, ClientBreaking, old.name, strconv.Itoa(old.index), strconv.Itoa(new.index))
+	}
+
+	oldParents, newParents := classNames(old.parents), classNames(new.parents)
+	if oldParents != newParents {
+		d.add(ParentsChanged, ClientBreaking, old.name, oldParents, newParents)
+	}
+
+	for _, oldField := range old.fields {
+		path := old.name + "." + oldField.Name()
+		newField := new.typeBase.FieldByName(oldField.Name())
+		if newField == nil {
+			d.add(FieldRemoved, ClientBreaking, path, oldField.(fmt.Stringer).String(), "")
+			continue
+		}
+		if oldField.Number() != newField.Number() {
+			d.add(FieldRenumbered, ClientBreaking, path, strconv.Itoa(oldField.Number()),
+				strconv.Itoa(newField.Number()))
+		}
+		d.diffField(path, oldField, newField)
+	}
+	for _, newField := range new.fields {
+		if old.typeBase.FieldByName(newField.Name()) == nil {
+			compat := WireCompatible
+			if newField.IsRequired() {
+				compat = ClientBreaking // old clients will not send the field when generating objects
+			}
+			d.add(FieldAdded, compat, new.name+"."+newField.Name(), "", newField.(fmt.Stringer).String())
+		}
+	}
+}
+
+func classNames(classes []*Class) string {
+	var names string
+	for i, c := range classes {
+		if i > 0 {
+			names += ", "
+		}
+		names += c.name
+	}
+	return names
+}
+
+// diffStruct compares two versions of a struct.  Structs are packed as the sequence of their
+// fields, so adding or removing a field changes the packing of every parameter using the struct.
+func (d *differ) diffStruct(old, new *Struct) {
+	for i, oldField := range old.fields {
+		path := old.name + "." + oldField.Name()
+		newField := new.typeBase.FieldByName(oldField.Name())
+		if newField == nil {
+			d.add(FieldRemoved, ClientBreaking, path, oldField.(fmt.Stringer).String(), "")
+			continue
+		}
+		for j, f := range new.fields {
+			if f == newField && i != j {
+				d.add(FieldRenumbered, ClientBreaking, path, strconv.Itoa(i), strconv.Itoa(j))
+			}
+		}
+		d.diffField(path, oldField, newField)
+	}
+	for _, newField := range new.fields {
+		if old.typeBase.FieldByName(newField.Name()) == nil {
+			d.add(FieldAdded, ClientBreaking, new.name+"."+newField.Name(), "", newField.(fmt.Stringer).String())
+		}
+	}
+}
+
+// diffField compares two versions of a field with the same name.
+func (d *differ) diffField(path string, old, new Field) {
+	switch oldField := old.(type) {
+	case *Parameter:
+		newField, ok := new.(*Parameter)
+		if !ok {
+			d.add(TypeChanged, ClientBreaking, path, oldField.String(), new.(fmt.Stringer).String())
+			return
+		}
+		d.diffParameter(path, oldField, newField)
+	case *AtomicField:
+		newField, ok := new.(*AtomicField)
+		if !ok || len(oldField.args) != len(newField.args) {
+			d.add(TypeChanged, ClientBreaking, path, oldField.String(), new.(fmt.Stringer).String())
+			return
+		}
+		for i, arg := range oldField.args {
+			argPath := path + "." + arg.Name()
+			if arg.Name() == "" {
+				argPath = path + "." + strconv.Itoa(i)
+			}
+			d.diffParameter(argPath, arg.(*Parameter), newField.args[i].(*Parameter))
+		}
+	case *MolecularField:
+		newField, ok := new.(*MolecularField)
+		if !ok || fieldSignature(oldField) != fieldSignature(newField) {
+			d.add(TypeChanged, ClientBreaking, path, oldField.String(), new.(fmt.Stringer).String())
+		}
+		return // the keywords of a molecular field are those of its components
+	}
+	d.diffKeywords(path, old, new)
+}
+
+// diffParameter compares two versions of a parameter or atomic field argument.
+func (d *differ) diffParameter(path string, old, new *Parameter) {
+	if wireType(old) != wireType(new) {
+		d.add(TypeChanged, ClientBreaking, path, old.TypeString(), new.TypeString())
+		return
+	}
+
+	if oldTrans, newTrans := transformString(old.Transform), transformString(new.Transform); oldTrans != newTrans {
+		d.add(TransformChanged, ClientBreaking, path, old.TypeString(), new.TypeString())
+	}
+
+	narrowed, widened := compareRanges(old.Range, new.Range)
+	arrayNarrowed, arrayWidened := compareRanges(old.ArrayRange, new.ArrayRange)
+	switch {
+	case narrowed || arrayNarrowed:
+		d.add(RangeNarrowed, DBIncompatible, path, old.TypeString(), new.TypeString())
+	case widened || arrayWidened:
+		d.add(RangeWidened, WireCompatible, path, old.TypeString(), new.TypeString())
+	}
+
+	oldDefault, newDefault := old.DefaultValue(), new.DefaultValue()
+	if !bytes.Equal(oldDefault.Bytes(), newDefault.Bytes()) {
+		d.add(DefaultChanged, WireCompatible, path, old.FormatData(oldDefault, true),
+			new.FormatData(newDefault, true))
+	}
+}
+
+// wireType returns the type of the parameter ignoring any transform and ranges, which do not
+// change how the parameter is packed.
+func wireType(p *Parameter) string {
+	typ := p.TypeName()
+	if p.isArray && p.arraySize > 0 {
+		typ += "[" + strconv.Itoa(p.arraySize) + "]"
+	} else if p.isArray {
+		typ += "[]"
+	}
+	return typ
+}
+
+func transformString(trans Transform) string {
+	var s string
+	for _, op := range trans {
+		s += string(op.Operator) + strconv.FormatFloat(op.Operand, 'g', -1, 64)
+	}
+	return s
+}
+
+// compareRanges returns whether the new range excludes values allowed by the old range, and
+// whether it allows values excluded by the old range.  A nil range allows any value.
+func compareRanges(old, new Range) (narrowed, widened bool) {
+	oldMin, oldMax, oldOk := rangeBounds(old)
+	newMin, newMax, newOk := rangeBounds(new)
+	switch {
+	case !oldOk && !newOk:
+		return false, false
+	case !oldOk:
+		return true, false
+	case !newOk:
+		return false, true
+	}
+	narrowed = newMin.Cmp(oldMin) > 0 || newMax.Cmp(oldMax) < 0
+	widened = newMin.Cmp(oldMin) < 0 || newMax.Cmp(oldMax) > 0
+	return narrowed, widened
+}
+
+// diffKeywords reports the keywords added to or removed from a field.
+func (d *differ) diffKeywords(path string, old, new Field) {
+	for _, keyword := range old.Keywords() {
+		if !new.HasKeyword(keyword) {
+			d.add(KeywordRemoved, keywordCompatibility(keyword, false), path, keyword, "")
+		}
+	}
+	for _, keyword := range new.Keywords() {
+		if !old.HasKeyword(keyword) {
+			d.add(KeywordAdded, keywordCompatibility(keyword, true), path, "", keyword)
+		}
+	}
+}
+
+// keywordCompatibility returns the compatibility of adding or removing a keyword from a field.
+func keywordCompatibility(keyword string, added bool) Compatibility {
+	switch keyword {
+	case "required", "ram", "broadcast":
+		// These keywords select the fields sent when an object is generated
+		return ClientBreaking
+	case "clsend", "ownsend":
+		if !added {
+			return ClientBreaking // clients may no longer send the field
+		}
+	case "db":
+		return DBIncompatible
+	}
+	return WireCompatible
+}
@@ -0,0 +1,88 @@
+package dclass
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const caseDiffOld = `
+enum Mode : uint8 { Idle, Walk, Run };
+struct Point { int16 x; int16 y; };
+dclass Avatar {
+	setName(string(1-32) name) required broadcast db;
+	setPos(Point pos, int16 / 10 heading) ram;
+	setMode(Mode) clsend;
+	setLevel(uint8(1-99) level = 1) db;
+	setState : setPos, setMode;
+};
+dclass Removed {};
+`
+
+const caseDiffNew = `
+enum Mode : uint8 { Idle, Walk, Fly = 4 };
+struct Point { int16 x; int16 y; };
+dclass Avatar {
+	setName(string(1-16) name) required broadcast;
+	setPos(Point pos, int16 / 100 heading) ram;
+	setMode(Mode);
+	setLevel(uint8(0-200) level = 2) db;
+	setState : setPos, setMode;
+	setTitle(string);
+};
+`
+
+func TestDiff(t *testing.T) {
+	changes := Diff(mustParse(t, caseDiffOld), mustParse(t, caseDiffNew))
+
+	expected := []string{
+		"client-breaking: Mode.Run: enum-value-removed: 2",
+		"wire-compatible: Mode.Fly: enum-value-added: 4",
+		"db-incompatible: Avatar.setName.name: range-narrowed: string(1-32) -> string(1-16)",
+		"db-incompatible: Avatar.setName: keyword-removed: db",
+		"client-breaking: Avatar.setPos.heading: transform-changed: int16 / 10 -> int16 / 100",
+		"client-breaking: Avatar.setMode: keyword-removed: clsend",
+		"wire-compatible: Avatar.setLevel.level: range-widened: uint8(1-99) -> uint8(0-200)",
+		"wire-compatible: Avatar.setLevel.level: default-changed: 1 -> 2",
+		"wire-compatible: Avatar.setTitle: field-added: setTitle(string)",
+		"client-breaking: Removed: class-removed",
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected changes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if changes.Compatibility() != ClientBreaking {
+		t.Errorf("expected client-breaking changes, got %v", changes.Compatibility())
+	}
+
+	data, err := json.Marshal(changes[0])
+	expectedJSON := `{"kind":"enum-value-removed","compatibility":"client-breaking","path":"Mode.Run","old":"2"}`
+	if err != nil || string(data) != expectedJSON {
+		t.Errorf("expected JSON %s, got %s (%v)", expectedJSON, data, err)
+	}
+}
+
+func TestDiffRenumbered(t *testing.T) {
+	old := mustParse(t, "dclass A { setX(uint8); };\ndclass B { setY(uint8); };")
+	new := mustParse(t, "dclass A { setW(uint8); setX(uint8); };\ndclass B { setY(uint8); };")
+
+	var got []string
+	for _, c := range Diff(old, new) {
+		got = append(got, c.String())
+	}
+	expected := []string{
+		"client-breaking: A.setX: field-renumbered: 0 -> 1",
+		"wire-compatible: A.setW: field-added: setW(uint8)",
+		"client-breaking: B.setY: field-renumbered: 1 -> 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected changes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 || changes.Compatibility() != WireCompatible {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
@@ -0,0 +1,85 @@
+package dclass
+
+import (
+	"math"
+	"strings"
+)
+
+// Comments are attached to the keyword, struct, class, field, or parameter declaration that
+// follows them, and are returned by the declaration's Doc method.  The leading comments of a
+// declaration are the comments directly above it, which are not separated from it by a blank
+// line and do not follow another token on their line.  The trailing comments of a declaration
+// start on the same line as its last token, before the next token.  Lint directives, such as
+// "// lint:ignore rule", are not part of the text of comments.
+
+// leadingDoc returns the text of the leading comments of the declaration starting with the
+// token t.  Any other comments read before t are discarded.
+func (p *parser) leadingDoc(t token) string {
+	comments := p.lex.takeComments(t.pos)
+
+	i, end := len(comments), t.pos
+	for ; i > 0; i-- {
+		c := comments[i-1]
+		blankLine := p.lex.positionOf(end).Line-p.lex.positionOf(c.pos+len(c.val)).Line > 1
+		if blankLine || !p.lex.startsLine(c.pos) {
+			break
+		}
+		end = c.pos
+	}
+	return commentText(comments[i:])
+}
+
+// trailingDoc returns the text of the comments following the last token read on the same line.
+func (p *parser) trailingDoc() string {
+	if p.foundEOF {
+		return ""
+	}
+	p.peek() // read any comments before the next token
+
+	lineEnd := p.lex.lineEnd(p.lex.lastPos)
+	if lineEnd < 0 {
+		lineEnd = math.MaxInt
+	}
+	return commentText(p.lex.takeComments(lineEnd))
+}
+
+// commentText returns the text of a group of comments, without comment delimiters, the leading
+// '*' of lines in block comments, or leading and trailing blank lines.
+func commentText(comments []token) string {
+	var lines []string
+	for _, c := range comments {
+		if isLintDirective(c.val) {
+			continue
+		}
+		if strings.HasPrefix(c.val, leftComment) {
+			line := strings.TrimPrefix(c.val[len(leftComment):], " ")
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+			continue
+		}
+
+		text := strings.TrimSuffix(c.val[len(leftBlockComment):], rightBlockComment)
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "*") {
+				line = strings.TrimSpace(line[1:])
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// joinDoc returns the leading and trailing comment text of a declaration as a single text.
+func joinDoc(leading, trailing string) string {
+	if leading == "" || trailing == "" {
+		return leading + trailing
+	}
+	return leading + "\n" + trailing
+}
@@ -0,0 +1,117 @@
+package dclass
+
+import (
+	"bytes"
+	"testing"
+)
+
+const caseDoc = `// A discarded comment, separated by a blank line.
+
+// Marks fields which are sent to admins.
+keyword admin; // Since 1.2.
+
+/*
+ * A point on the map,
+ * in tiles.
+ */
+struct Point {
+	int16 x; // The column.
+	// The row.
+	int16 y;
+};
+
+// An avatar in the world.
+dclass Avatar {
+	// Sets the position.
+	setPos(
+		// The new position.
+		Point pos, // In tiles.
+		uint8 speed /* Tiles per second. */
+	) admin; // Broadcast to the zone.
+	setName(string name) broadcast;
+	setAll : setPos, setName; /* Both. */
+}; // End of avatar.
+`
+
+func TestDoc(t *testing.T) {
+	dcf := mustParse(t, caseDoc)
+	point := dcf.ClassByName["Point"].(*Struct)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+	setPos := avatar.FieldByName("setPos").(*AtomicField)
+
+	tests := []struct {
+		name     string
+		doc      string
+		expected string
+	}{
+		{"keyword admin", dcf.KeywordDoc("admin"), "Marks fields which are sent to admins.\nSince 1.2."},
+		{"struct Point", point.Doc(), "A point on the map,\nin tiles."},
+		{"Point.x", point.FieldByName("x").Doc(), "The column."},
+		{"Point.y", point.FieldByName("y").Doc(), "The row."},
+		{"dclass Avatar", avatar.Doc(), "An avatar in the world.\nEnd of avatar."},
+		{"Avatar.setPos", setPos.Doc(), "Sets the position.\nBroadcast to the zone."},
+		{"setPos.pos", setPos.args[0].Doc(), "The new position.\nIn tiles."},
+		{"setPos.speed", setPos.args[1].Doc(), "Tiles per second."},
+		{"Avatar.setName", avatar.FieldByName("setName").Doc(), ""},
+		{"Avatar.setAll", avatar.FieldByName("setAll").Doc(), "Both."},
+	}
+	for _, test := range tests {
+		if test.doc != test.expected {
+			t.Errorf("%s has doc %q, expected %q", test.name, test.doc, test.expected)
+		}
+	}
+
+	// comments are not part of the hash
+	plain := mustParse(t, `keyword admin;
+		struct Point { int16 x; int16 y; };
+		dclass Avatar {
+			setPos(Point pos, uint8 speed) admin;
+			setName(string name) broadcast;
+			setAll : setPos, setName;
+		};`)
+	if dcf.Hash() != plain.Hash() {
+		t.Errorf("hash with comments is %d, expected %d", dcf.Hash(), plain.Hash())
+	}
+
+	// comments are kept by schemas and caches
+	var buf bytes.Buffer
+	if err := dcf.WriteCache(&buf); err != nil {
+		t.Fatalf("unexpected error writing cache: %s", err)
+	}
+	cached, ok, err := LoadCache(&buf, []byte(caseDoc))
+	if err != nil || !ok {
+		t.Fatalf("cache was not loaded: %v", err)
+	}
+	if doc := cached.KeywordDoc("admin"); doc != dcf.KeywordDoc("admin") {
+		t.Errorf("cached keyword admin has doc %q, expected %q", doc, dcf.KeywordDoc("admin"))
+	}
+	cachedPos := cached.ClassByName["Avatar"].(*Class).FieldByName("setPos").(*AtomicField)
+	if doc := cachedPos.args[0].Doc(); doc != setPos.args[0].Doc() {
+		t.Errorf("cached setPos.pos has doc %q, expected %q", doc, setPos.args[0].Doc())
+	}
+	if doc := cached.ClassByName["Point"].(*Struct).Doc(); doc != point.Doc() {
+		t.Errorf("cached struct Point has doc %q, expected %q", doc, point.Doc())
+	}
+}
+
+func TestCommentText(t *testing.T) {
+	tests := []struct {
+		comments []string
+		expected string
+	}{
+		{[]string{"//", "// Text", "//   indented ", "//"}, "Text\n  indented"},
+		{[]string{"//no space"}, "no space"},
+		{[]string{"/* inline */"}, "inline"},
+		{[]string{"/**\n * Starred\n *\n * lines\n */"}, "Starred\n\nlines"},
+		{[]string{"/* a */", "// b"}, "a\nb"},
+	}
+	for _, test := range tests {
+		var comments []token
+		for _, c := range test.comments {
+			comments = append(comments, token{tokenComment, 0, c})
+		}
+		if text := commentText(comments); text != test.expected {
+			t.Errorf("text of %q is %q, expected %q", test.comments, text, test.expected)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package dclass
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Format parses the dclass source src and returns it printed in canonical form.  Unlike Print,
+// Format only changes the layout of the source: declarations keep their order, and comments and
+// the names of constants are kept.  Declarations, fields, and enum values are each written on
+// their own line, indented by tabs, with the spacing of the tokens between them normalized.
+// Blank lines between declarations, fields, and enum values are kept, and are added between
+// declarations other than consecutive keywords or constants.
+func Format(src []byte) ([]byte, error) {
+	if _, err := Parse(bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+	tree, err := ParseSyntax(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var f formatter
+	f.file(tree)
+	return f.out.Bytes(), nil
+}
+
+// A formatter writes the canonical source of a syntax tree.  The tree is modified by formatting
+// it, so it must not be used afterwards.
+type formatter struct {
+	out    bytes.Buffer
+	indent int  // indentation of lines which start a declaration, field, or enum value
+	col0   bool // whether the output is at the start of a line
+}
+
+// A separator is the layout between a token and the output before it.
+type separator int
+
+const (
+	sepNone      separator = iota // no space
+	sepSpace                      // a single space
+	sepLine                       // a line break
+	sepParagraph                  // a line break, and a blank line if the source has one
+	sepBlank                      // a line break and a blank line
+)
+
+// file writes the declarations of a file, and the comments at its end.
+func (f *formatter) file(n *SyntaxNode) {
+	f.col0 = true
+	var prev SyntaxKind = -1
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *SyntaxNode:
+			sep := sepBlank
+			if prev == child.Kind && (prev == KeywordSyntax || prev == ConstSyntax) {
+				sep = sepParagraph
+			}
+			f.declaration(child, sep)
+			prev = child.Kind
+		case *SyntaxToken:
+			// The EOF token holds the comments at the end of the file
+			f.comments(child, sepParagraph)
+			f.newline(false)
+		}
+	}
+}
+
+// declaration writes a keyword, struct, class, enum, or const declaration on new lines.
+func (f *formatter) declaration(n *SyntaxNode, sep separator) {
+	// The tokens before the body, or the whole declaration if it has none
+	var header []*SyntaxToken
+	i := 0
+	for ; i < len(n.Children); i++ {
+		t, ok := n.Children[i].(*SyntaxToken)
+		if !ok {
+			break
+		}
+		header = append(header, t)
+		if t.isPunct("{") {
+			i++
+			break
+		}
+	}
+	f.inline(header, sep, nil)
+
+	members := 0
+	for ; i < len(n.Children); i++ {
+		switch child := n.Children[i].(type) {
+		case *SyntaxNode:
+			memberSep := sepParagraph
+			if members == 0 {
+				memberSep = sepLine
+			}
+			members++
+
+			f.indent++
+			tokens := child.Tokens()
+			if child.Kind != EnumValueSyntax {
+				f.inline(tokens, memberSep, argumentParens(child))
+			} else if next, ok := n.Children[i+1].(*SyntaxToken); ok && next.isPunct(",") {
+				f.inline(tokens, memberSep, nil)
+			} else {
+				// Add the ',' after the last value, before any comment following it
+				last := tokens[len(tokens)-1]
+				comma := &SyntaxToken{Kind: PunctToken, Text: ",", Trailing: last.Trailing}
+				last.Trailing = nil
+				f.inline(append(tokens, comma), memberSep, nil)
+			}
+			f.indent--
+		case *SyntaxToken:
+			switch {
+			case child.isPunct("}"):
+				// Comments at the end of the body are indented as its members
+				f.indent++
+				comments := f.comments(child, sepParagraph)
+				f.indent--
+				if members > 0 || comments {
+					f.token(child, sepLine)
+				} else {
+					f.token(child, sepNone)
+				}
+			default:
+				f.token(child, sepNone) // the ',' after an enum value, or the final ';'
+			}
+		}
+	}
+}
+
+// argumentParens returns the parens around the arguments of an atomic field, which unlike the
+// parens of a range are not written without spaces between the tokens inside them.
+func argumentParens(n *SyntaxNode) map[*SyntaxToken]bool {
+	if n.Kind != FieldSyntax || len(n.Children) < 2 {
+		return nil
+	}
+	name, _ := n.Children[0].(*SyntaxToken)
+	open, ok := n.Children[1].(*SyntaxToken)
+	if name == nil || name.Kind != IdentToken || !ok || !open.isPunct("(") {
+		return nil
+	}
+	parens := map[*SyntaxToken]bool{open: true}
+	for _, child := range n.Children[2:] {
+		if t, ok := child.(*SyntaxToken); ok && t.isPunct(")") {
+			parens[t] = true
+			break
+		}
+	}
+	return parens
+}
+
+// inline writes a sequence of tokens, the first of which is separated from the output before it
+// by sep.  Tokens are separated by single spaces, except around the delimiters of lists, after
+// signs, and inside the parens of ranges and the brackets of array sizes.  The args are the
+// parens around the arguments of an atomic field.
+func (f *formatter) inline(tokens []*SyntaxToken, sep separator, args map[*SyntaxToken]bool) {
+	depth := 0 // nesting of range parens and array brackets
+	sign := false
+	var prev *SyntaxToken
+	for _, t := range tokens {
+		switch {
+		case prev == nil:
+		case depth > 0, sign, t.isPunct(","), t.isPunct(";"), t.isPunct(")"), t.isPunct("]"),
+			t.isPunct("}"), t.isPunct("("), t.isPunct("["), prev.isPunct("("), prev.isPunct("["),
+			prev.isPunct("{"):
+			sep = sepNone
+		default:
+			sep = sepSpace
+		}
+		f.token(t, sep)
+
+		// A '-' or '+' is a sign, rather than the operator of a transform, unless it follows a
+		// type, an operand, or a closing delimiter
+		sign = (t.isPunct("-") || t.isPunct("+")) && prev != nil && prev.Kind == PunctToken &&
+			!prev.isPunct(")") && !prev.isPunct("]") && !prev.isPunct("}")
+		if !args[t] {
+			switch {
+			case t.isPunct("("), t.isPunct("["):
+				depth++
+			case t.isPunct(")"), t.isPunct("]"):
+				depth--
+			}
+		}
+		prev = t
+	}
+}
+
+// token writes a token with its comments, separated from the output before it by sep.
+func (f *formatter) token(t *SyntaxToken, sep separator) {
+	// A token which does not start a line is indented as the continuation of the line
+	indent := f.indent
+	if sep < sepLine {
+		indent++
+	}
+	blank := blankBefore(t.Leading, len(t.Leading))
+	if f.comments(t, sep) {
+		if sep >= sepParagraph && blank {
+			sep = sepBlank
+		} else {
+			sep = sepLine
+		}
+	}
+	switch sep {
+	case sepSpace:
+		if !f.col0 {
+			f.out.WriteByte(' ')
+		}
+	case sepLine, sepParagraph, sepBlank:
+		f.newline(sep == sepBlank || sep == sepParagraph && blank)
+	}
+
+	if f.col0 {
+		f.out.WriteString(strings.Repeat("\t", indent))
+	}
+	f.out.WriteString(t.Text)
+	f.col0 = false
+
+	for _, tr := range t.Trailing {
+		if tr.Kind == CommentTrivia {
+			f.out.WriteString(" " + tr.Text)
+			if strings.HasPrefix(tr.Text, "//") {
+				f.newline(false)
+			}
+		}
+	}
+}
+
+// comments writes the comments leading a token, each on its own line, and removes them from the
+// token.  The first comment is separated from the output before it by sep, or by a line break if
+// sep does not start a line, in which case the comments are indented as a continuation of the
+// line.  Returns whether there were any comments.
+func (f *formatter) comments(t *SyntaxToken, sep separator) bool {
+	indent := f.indent
+	if sep < sepLine {
+		indent++
+	}
+	last := -1
+	for i, tr := range t.Leading {
+		if tr.Kind != CommentTrivia {
+			continue
+		}
+		blank := blankBefore(t.Leading, i)
+		if last < 0 {
+			blank = sep == sepBlank || sep == sepParagraph && blank
+		}
+		f.newline(blank)
+		f.out.WriteString(strings.Repeat("\t", indent) + tr.Text)
+		f.col0 = false
+		last = i
+	}
+	if last < 0 {
+		return false
+	}
+	t.Leading = nil
+	return true
+}
+
+// blankBefore returns whether the source has a blank line before the i'th trivia leading a token,
+// or before the token itself if i is len(leading).
+func blankBefore(leading []Trivia, i int) bool {
+	if i == 0 || leading[i-1].Kind != SpaceTrivia {
+		return false
+	}
+	lines := strings.Count(leading[i-1].Text, "\n")
+	if i > 1 {
+		// The space follows a comment, and its first line break ends the comment's line
+		return lines > 1
+	}
+	return lines > 0
+}
+
+// newline ends the current line, if it is not empty, and adds a blank line if blank is true and
+// the output is neither empty nor already ends with a blank line.
+func (f *formatter) newline(blank bool) {
+	if !f.col0 {
+		f.out.WriteByte('\n')
+		f.col0 = true
+	}
+	if blank && f.out.Len() > 0 && !bytes.HasSuffix(f.out.Bytes(), []byte("\n\n")) {
+		f.out.WriteByte('\n')
+	}
+}
@@ -0,0 +1,109 @@
+package dclass
+
+import (
+	"bytes"
+	"testing"
+)
+
+const caseFormat = `// lint:file-ignore empty-class
+keyword p2p;
+keyword   audit ;
+const uint8 MaxLevel=99; // the highest level
+const Scale = -0.5;
+enum Mode:uint8{ Idle=1, // idle
+  Walk,
+
+  // stopped
+  Stop }  ;
+// Point is a position.
+struct Point {
+	int16/10 x=-4.5; // x coord
+  int16 * Scale (-100-MaxLevel) y;
+
+
+	float64 ( 0 - 1.5 ) z ;
+};
+dclass Empty{};
+// Player avatar
+dclass Avatar : Empty {
+	// lint:ignore send-without-airecv
+	setName ( string ( 1 - 32 ) name = "base" , Mode[2] = { Idle , Walk } ) required broadcast db;
+	setPos(int16 x, // first
+		int16 y) /* c */ ram;
+	setAll : setName , setPos ;
+	/* no more fields */
+};
+// end of file
+`
+
+const expectedFormat = `// lint:file-ignore empty-class
+keyword p2p;
+keyword audit;
+
+const uint8 MaxLevel = 99; // the highest level
+const Scale = -0.5;
+
+enum Mode : uint8 {
+	Idle = 1, // idle
+	Walk,
+
+	// stopped
+	Stop,
+};
+
+// Point is a position.
+struct Point {
+	int16 / 10 x = -4.5; // x coord
+	int16 * Scale(-100-MaxLevel) y;
+
+	float64(0-1.5) z;
+};
+
+dclass Empty {};
+
+// Player avatar
+dclass Avatar : Empty {
+	// lint:ignore send-without-airecv
+	setName(string(1-32) name = "base", Mode[2] = {Idle, Walk}) required broadcast db;
+	setPos(int16 x, // first
+		int16 y) /* c */ ram;
+	setAll : setName, setPos;
+	/* no more fields */
+};
+// end of file
+`
+
+func TestFormat(t *testing.T) {
+	formatted, err := Format([]byte(caseFormat))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(formatted) != expectedFormat {
+		t.Errorf("expected\n%s\ngot\n%s", expectedFormat, formatted)
+	}
+
+	again, err := Format(formatted)
+	if err != nil || !bytes.Equal(again, formatted) {
+		t.Errorf("expected formatted source to be unchanged by Format, got\n%s", again)
+	}
+
+	dcf, printed := mustParse(t, caseFormat), mustParse(t, string(formatted))
+	if dcf.Hash() != printed.Hash() {
+		t.Errorf("expected hash %d, got %d after formatting", dcf.Hash(), printed.Hash())
+	}
+	if doc := printed.ClassByName["Point"].(*Struct).Doc(); doc != "Point is a position." {
+		t.Errorf("formatted struct has doc %q", doc)
+	}
+
+	// The lint directives still suppress the same issues
+	before, _ := Lint(dcf, LintOptions{})
+	after, _ := Lint(printed, LintOptions{})
+	if len(before) != len(after) {
+		t.Errorf("formatted source has lint issues %v, expected %v", after, before)
+	}
+	for _, issue := range after {
+		if issue.Rule == "empty-class" || issue.Rule == "send-without-airecv" {
+			t.Errorf("formatted source lost its lint directives: %v", issue)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package dclass
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A GraphKind selects the graph written by WriteGraph.
+type GraphKind int
+
+const (
+	// InheritanceGraph has a node for each class, with an edge from each class to its parents.
+	InheritanceGraph GraphKind = iota
+
+	// StructGraph has a node for each struct and each class using a struct, with an edge from
+	// each class or struct to the structs its fields contain.  Edges are labeled with the names
+	// of the fields, or `field.argument` for arguments of atomic fields.
+	StructGraph
+)
+
+// GraphOptions configures the graph written by WriteGraph.
+type GraphOptions struct {
+	Kind GraphKind
+
+	// Root restricts the graph to the subtree rooted at the named class: the class and every
+	// class inheriting from it, and for a StructGraph the structs they contain.  A StructGraph
+	// may also be rooted at a struct.  The whole File is graphed if Root is empty.
+	Root string
+}
+
+// WriteGraph writes a graph of the File to w in the Graphviz DOT language.
+func WriteGraph(w io.Writer, f *File, opts GraphOptions) error {
+	types := f.Classes
+	if opts.Root != "" {
+		root, ok := f.ClassByName[opts.Root]
+		if _, isStruct := root.(*Struct); !ok || (isStruct && opts.Kind != StructGraph) {
+			return Error("no dclass named " + opts.Root)
+		}
+		types = f.subtree(root)
+	}
+
+	g := graphWriter{w: bufio.NewWriter(w)}
+	switch opts.Kind {
+	case InheritanceGraph:
+		g.inheritance(types)
+	case StructGraph:
+		g.structs(types, opts.Root == "")
+	default:
+		return Error("unknown graph kind " + strconv.Itoa(int(opts.Kind)))
+	}
+	return g.w.Flush()
+}
+
+// subtree returns the type root and the classes inheriting from it, in order of declaration.
+func (f *File) subtree(root Type) []Type {
+	inSubtree := map[Type]bool{root: true}
+	var inherits func(c *Class) bool
+	inherits = func(c *Class) bool {
+		if in, ok := inSubtree[c]; ok {
+			return in
+		}
+		inSubtree[c] = false // guards against inheritance cycles
+		for _, parent := range c.parents {
+			if inherits(parent) {
+				inSubtree[c] = true
+				break
+			}
+		}
+		return inSubtree[c]
+	}
+
+	var types []Type
+	for _, typ := range f.Classes {
+		if c, ok := typ.(*Class); typ == root || (ok && inherits(c)) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// a graphWriter writes DOT graphs.
+type graphWriter struct {
+	w *bufio.Writer
+}
+
+// dotEscaper escapes the text of a quoted DOT identifier.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// printf writes the arguments as quoted DOT identifiers in the format.
+func (g *graphWriter) printf(format string, args ...string) {
+	quoted := make([]interface{}, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + dotEscaper.Replace(arg) + `"`
+	}
+	fmt.Fprintf(g.w, format, quoted...)
+}
+
+// inheritance writes the inheritance graph of the classes among types.
+func (g *graphWriter) inheritance(types []Type) {
+	g.w.WriteString("digraph inheritance {\n\trankdir=BT;\n\tnode [shape=box];\n")
+	included := make(map[*Class]bool)
+	for _, typ := range types {
+		if c, ok := typ.(*Class); ok {
+			included[c] = true
+			g.printf("\t%s;\n", c.name)
+		}
+	}
+	for _, typ := range types {
+		if c, ok := typ.(*Class); ok {
+			for _, parent := range c.parents {
+				if included[parent] {
+					g.printf("\t%s -> %s [arrowhead=empty];\n", c.name, parent.name)
+				}
+			}
+		}
+	}
+	g.w.WriteString("}\n")
+}
+
+// a structEdge is the use of a struct by the fields of a class or struct.
+type structEdge struct {
+	from, to Type
+	fields   []string
+}
+
+// structs writes the struct graph of types, and the structs they contain.  If all is true, the
+// structs which are not contained by any type are included.
+func (g *graphWriter) structs(types []Type, all bool) {
+	var edges []*structEdge
+	index := make(map[[2]Type]*structEdge)
+	addEdge := func(from Type, p *Parameter, name string) {
+		if p.structType == nil {
+			return
+		}
+		key := [2]Type{from, p.structType}
+		if index[key] == nil {
+			index[key] = &structEdge{from: from, to: p.structType}
+			edges = append(edges, index[key])
+		}
+		index[key].fields = append(index[key].fields, name)
+	}
+
+	// Follow the structs contained by the types, adding the contained structs to the graph
+	types = append([]Type(nil), types...)
+	included := make(map[Type]bool)
+	for _, typ := range types {
+		included[typ] = true
+	}
+	for i := 0; i < len(types); i++ {
+		numEdges := len(edges)
+		for _, field := range types[i].Fields() {
+			switch field := field.(type) {
+			case *Parameter:
+				addEdge(types[i], field, field.name)
+			case *AtomicField:
+				for j, arg := range field.args {
+					name := arg.Name()
+					if name == "" {
+						name = strconv.Itoa(j)
+					}
+					addEdge(types[i], arg.(*Parameter), field.name+"."+name)
+				}
+			}
+		}
+		for _, e := range edges[numEdges:] {
+			if !included[e.to] {
+				included[e.to] = true
+				types = append(types, e.to)
+			}
+		}
+	}
+
+	g.w.WriteString("digraph structs {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	used := make(map[Type]bool)
+	for _, e := range edges {
+		used[e.from], used[e.to] = true, true
+	}
+	for _, typ := range types {
+		switch typ.(type) {
+		case *Class:
+			if used[typ] || len(types) == 1 {
+				g.printf("\t%s;\n", typ.Name())
+			}
+		case *Struct:
+			if used[typ] || all || len(types) == 1 {
+				g.printf("\t%s [shape=ellipse];\n", typ.Name())
+			}
+		}
+	}
+	for _, e := range edges {
+		g.printf("\t%s -> %s [label=%s];\n", e.from.Name(), e.to.Name(), strings.Join(e.fields, "\n"))
+	}
+	g.w.WriteString("}\n")
+}
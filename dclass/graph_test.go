@@ -0,0 +1,114 @@
+package dclass
+
+import (
+	"bytes"
+	"testing"
+)
+
+const caseGraph = `
+struct Point {
+	int16 x;
+	int16 y;
+};
+struct Path {
+	Point[] points;
+	Point start;
+};
+struct Color {
+	uint8 r;
+};
+dclass Object {
+	setLocation(Point where, Point);
+};
+dclass Avatar : Object {
+	setPath(Path path);
+};
+dclass Npc : Object {
+};
+dclass Guard : Npc, Avatar {
+	setColor(Color);
+};
+dclass Door {
+};
+`
+
+func TestGraph(t *testing.T) {
+	dcf := mustParse(t, caseGraph)
+
+	tests := []struct {
+		opts     GraphOptions
+		expected string
+	}{
+		{GraphOptions{Kind: InheritanceGraph}, `digraph inheritance {
+	rankdir=BT;
+	node [shape=box];
+	"Object";
+	"Avatar";
+	"Npc";
+	"Guard";
+	"Door";
+	"Avatar" -> "Object" [arrowhead=empty];
+	"Npc" -> "Object" [arrowhead=empty];
+	"Guard" -> "Npc" [arrowhead=empty];
+	"Guard" -> "Avatar" [arrowhead=empty];
+}
+`},
+		{GraphOptions{Kind: InheritanceGraph, Root: "Npc"}, `digraph inheritance {
+	rankdir=BT;
+	node [shape=box];
+	"Npc";
+	"Guard";
+	"Guard" -> "Npc" [arrowhead=empty];
+}
+`},
+		{GraphOptions{Kind: StructGraph}, `digraph structs {
+	rankdir=LR;
+	node [shape=box];
+	"Point" [shape=ellipse];
+	"Path" [shape=ellipse];
+	"Color" [shape=ellipse];
+	"Object";
+	"Avatar";
+	"Guard";
+	"Path" -> "Point" [label="points\nstart"];
+	"Object" -> "Point" [label="setLocation.where\nsetLocation.1"];
+	"Avatar" -> "Path" [label="setPath.path"];
+	"Guard" -> "Color" [label="setColor.0"];
+}
+`},
+		{GraphOptions{Kind: StructGraph, Root: "Avatar"}, `digraph structs {
+	rankdir=LR;
+	node [shape=box];
+	"Avatar";
+	"Guard";
+	"Path" [shape=ellipse];
+	"Color" [shape=ellipse];
+	"Point" [shape=ellipse];
+	"Avatar" -> "Path" [label="setPath.path"];
+	"Guard" -> "Color" [label="setColor.0"];
+	"Path" -> "Point" [label="points\nstart"];
+}
+`},
+		{GraphOptions{Kind: StructGraph, Root: "Color"}, `digraph structs {
+	rankdir=LR;
+	node [shape=box];
+	"Color" [shape=ellipse];
+}
+`},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteGraph(&buf, dcf, test.opts); err != nil {
+			t.Errorf("unexpected error writing graph %+v: %s", test.opts, err)
+		} else if buf.String() != test.expected {
+			t.Errorf("graph %+v is\n%s\nexpected\n%s", test.opts, buf.String(), test.expected)
+		}
+	}
+
+	for _, opts := range []GraphOptions{{Root: "Missing"}, {Root: "Point"}, {Kind: 5}} {
+		if err := WriteGraph(&bytes.Buffer{}, dcf, opts); err == nil {
+			t.Errorf("expected error writing graph %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package dclass
+
+import (
+	"strconv"
+	"sync"
+)
+
+// The hash of a dclass File is modelled on the prime-number hash of the Panda3D dc parser: each
+// value describing the file is multiplied by the next of the first 10000 prime numbers, and the
+// products are summed and truncated to 32 bits.  The values hashed are not the same as those of
+// other dc parsers, so hashes should only be compared with hashes computed by this package.
+// Clients and servers compare hashes to verify that they were built from the same dclass File.
+
+const maxPrimeNumbers = 10000
+
+var (
+	primes     []int64
+	primesOnce sync.Once
+)
+
+// initPrimes computes the first maxPrimeNumbers prime numbers with a sieve of Eratosthenes.
+func initPrimes() {
+	const limit = 104730 // the 10000th prime is 104729
+	composite := make([]bool, limit)
+	primes = make([]int64, 0, maxPrimeNumbers)
+	for n := 2; len(primes) < maxPrimeNumbers; n++ {
+		if composite[n] {
+			continue
+		}
+		primes = append(primes, int64(n))
+		for m := n * n; m < limit; m += n {
+			composite[m] = true
+		}
+	}
+}
+
+// a hashGenerator accumulates the hash of a dclass File or one of its types or fields.
+type hashGenerator struct {
+	hash  int64
+	index int
+}
+
+// a hasher is any dctype which can add itself to a hashGenerator.
+type hasher interface {
+	generateHash(h *hashGenerator)
+}
+
+// hashOf returns the hash of a dctype.
+func hashOf(x hasher) uint64 {
+	primesOnce.Do(initPrimes)
+
+	var h hashGenerator
+	x.generateHash(&h)
+	return uint64(uint32(h.hash))
+}
+
+func (h *hashGenerator) addInt(n int) {
+	h.hash += primes[h.index] * int64(n)
+	h.index = (h.index + 1) % maxPrimeNumbers
+}
+
+func (h *hashGenerator) addString(s string) {
+	h.addInt(len(s))
+	for i := 0; i < len(s); i++ {
+		h.addInt(int(s[i]))
+	}
+}
+
+// keywordHashFlags are the bits hashed for each built-in keyword.
+var keywordHashFlags = []int{
+	keywordRequired:  0x0001,
+	keywordBroadcast: 0x0002,
+	keywordOwnrecv:   0x0004,
+	keywordRam:       0x0008,
+	keywordDb:        0x0010,
+	keywordClsend:    0x0020,
+	keywordClrecv:    0x0040,
+	keywordOwnsend:   0x0080,
+	keywordAirecv:    0x0100,
+}
+
+// typeHashCodes are the numbers hashed for each DataType, after the subatomic types of Panda3D.
+var typeHashCodes = map[DataType]int{
+	Int8Type:   0,
+	Int16Type:  1,
+	Int32Type:  2,
+	Int64Type:  3,
+	Uint8Type:  4,
+	Uint16Type: 5,
+	Uint32Type: 6,
+	Uint64Type: 7,
+	FloatType:  8,
+	StringType: 9,
+	BlobType:   10,
+	CharType:   19,
+}
+
+// implementing hasher
+func (f *File) generateHash(h *hashGenerator) {
+	h.addInt(1) // the file uses virtual inheritance, sorted by file
+	h.addInt(len(f.Classes))
+	for _, typ := range f.Classes {
+		typ.(hasher).generateHash(h)
+	}
+}
+
+// implementing hasher
+func (c *Class) generateHash(h *hashGenerator) {
+	h.addString(c.name)
+	h.addInt(len(c.parents))
+	for _, parent := range c.parents {
+		h.addInt(parent.index)
+	}
+	h.addInt(len(c.fields))
+	for _, f := range c.fields {
+		f.(hasher).generateHash(h)
+	}
+}
+
+// implementing hasher
+func (s *Struct) generateHash(h *hashGenerator) {
+	h.addString(s.name)
+	h.addInt(1) // is a struct
+	h.addInt(0) // structs have no parents
+	h.addInt(len(s.fields))
+	for _, f := range s.fields {
+		f.(hasher).generateHash(h)
+	}
+}
+
+// generateHash adds the name and number of the field to the hash.
+func (f *fieldBase) generateHash(h *hashGenerator) {
+	h.addString(f.name)
+	h.addInt(f.index)
+}
+
+// generateKeywordHash adds the keywords of the field to the hash.  Fields with only built-in
+// keywords are hashed as a set of flags.
+func (f *fieldBase) generateKeywordHash(h *hashGenerator) {
+	keywords := f.Keywords()
+	flags := 0
+	for _, keyword := range keywords {
+		if !isDefinedKeyword(keyword) {
+			h.addInt(len(keywords))
+			for _, keyword := range keywords {
+				h.addString(keyword)
+			}
+			return
+		}
+		i, _ := f.table.lookup(keyword)
+		flags |= keywordHashFlags[i]
+	}
+	h.addInt(flags)
+}
+
+// implementing hasher
+func (p *Parameter) generateHash(h *hashGenerator) {
+	p.fieldBase.generateHash(h)
+
+	switch {
+	case p.dataType == StructType:
+		h.addString(p.typeName)
+		if p.structType != nil {
+			h.addInt(p.structType.index)
+		}
+	case p.enum != nil:
+		h.addInt(typeHashCodes[p.dataType])
+		h.addString(p.enum.name)
+		h.addInt(len(p.enum.values))
+		for _, v := range p.enum.values {
+			h.addString(v.Name)
+			h.addInt(int(v.Value.Int64()))
+		}
+	default:
+		h.addInt(typeHashCodes[p.dataType])
+	}
+
+	h.addInt(len(p.Transform))
+	for _, op := range p.Transform {
+		h.addInt(int(op.Operator))
+		h.addString(strconv.FormatFloat(op.Operand, 'g', -1, 64))
+	}
+	generateRangeHash(h, p.Range)
+
+	if p.isArray {
+		h.addInt(p.arraySize)
+		generateRangeHash(h, p.ArrayRange)
+	}
+	p.generateKeywordHash(h)
+}
+
+// generateRangeHash adds the bounds of a range to the hash.
+func generateRangeHash(h *hashGenerator, rng Range) {
+	min, max, ok := rangeBounds(rng)
+	if !ok {
+		h.addInt(0)
+		return
+	}
+	h.addInt(1)
+	for _, bound := range []string{formatNumber(min), formatNumber(max)} {
+		h.addString(bound)
+	}
+}
+
+// implementing hasher
+func (f *AtomicField) generateHash(h *hashGenerator) {
+	f.fieldBase.generateHash(h)
+	h.addInt(len(f.args))
+	for _, arg := range f.args {
+		arg.(hasher).generateHash(h)
+	}
+	f.generateKeywordHash(h)
+}
+
+// implementing hasher
+func (f *MolecularField) generateHash(h *hashGenerator) {
+	f.fieldBase.generateHash(h)
+	h.addInt(len(f.components))
+	for _, c := range f.components {
+		c.(hasher).generateHash(h)
+	}
+}
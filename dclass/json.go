@@ -0,0 +1,415 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values of fields are mapped to JSON as follows.  The arguments of an atomic field, the
+// components of a molecular field, and the members of a struct are written as an object keyed by
+// their names, or as an array if any of them is unnamed.  Arrays are written as arrays, strings as
+// strings, blobs as base64 strings, chars as strings of one character, and enums as the name of
+// their value.  Numbers are written as numbers, except integers which cannot be represented exactly
+// by a float64, which are written as decimal strings.
+
+// maxJSONInt is the largest integer which can be represented exactly by a JSON number.
+const maxJSONInt = 1 << 53
+
+// FieldToJSON formats the packed value of the field in data as JSON.  The data must contain
+// exactly one value of the field.
+func FieldToJSON(field Field, data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(data)
+	var out bytes.Buffer
+	if err := fieldToJSON(field, buf, &out); err != nil {
+		return nil, err
+	}
+	if buf.Len() > 0 {
+		return nil, Error("unexpected data after value of field '" + field.Name() + "'")
+	}
+	return out.Bytes(), nil
+}
+
+// fieldToJSON reads a packed value of any field from data and writes it to out as JSON.
+func fieldToJSON(f Field, data *bytes.Buffer, out *bytes.Buffer) error {
+	if p, ok := f.(*Parameter); ok {
+		return parameterToJSON(p, data, out)
+	}
+	return membersToJSON(f.NestedFields(), data, out)
+}
+
+// membersToJSON reads the packed values of the arguments, components, or members of a field
+// from data and writes them to out as a JSON object, or as an array if any of them is unnamed.
+func membersToJSON(fields []Field, data *bytes.Buffer, out *bytes.Buffer) error {
+	named := allNamed(fields)
+	open, close := byte('['), byte(']')
+	if named {
+		open, close = '{', '}'
+	}
+
+	out.WriteByte(open)
+	for i, f := range fields {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if named {
+			writeJSONString(out, f.Name())
+			out.WriteByte(':')
+		}
+		if err := fieldToJSON(f, data, out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(close)
+	return nil
+}
+
+// allNamed returns whether each of the fields has a name.
+func allNamed(fields []Field) bool {
+	for _, f := range fields {
+		if f.Name() == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parameterToJSON reads a packed value of the parameter from data and writes it to out as JSON.
+func parameterToJSON(p *Parameter, data *bytes.Buffer, out *bytes.Buffer) error {
+	if !p.isArray {
+		return elementToJSON(p, data, out)
+	}
+
+	elems := data
+	if p.arraySize == 0 {
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		elems = bytes.NewBuffer(data.Next(n))
+	}
+
+	out.WriteByte('[')
+	for i := 0; (p.arraySize == 0 && elems.Len() > 0) || i < p.arraySize; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := elementToJSON(p, elems, out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// elementToJSON reads a single packed element of the parameter from data and writes it to out
+// as JSON.
+func elementToJSON(p *Parameter, data *bytes.Buffer, out *bytes.Buffer) error {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return Error("struct " + p.typeName + " is not defined")
+		}
+		return membersToJSON(p.structType.fields, data, out)
+	case StringType, BlobType:
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		if p.dataType == StringType {
+			writeJSONString(out, string(data.Next(n)))
+		} else {
+			writeJSONString(out, base64.StdEncoding.EncodeToString(data.Next(n)))
+		}
+		return nil
+	case CharType:
+		if data.Len() < 1 {
+			return Error("unexpected end of data reading char")
+		}
+		c, _ := data.ReadByte()
+		writeJSONString(out, string(rune(c)))
+		return nil
+	case FloatType:
+		if data.Len() < 8 {
+			return Error("unexpected end of data reading float64")
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(data.Next(8)))
+		return writeJSONFloat(p, out, p.Transform.apply(f))
+	}
+
+	if !p.dataType.IsInteger() {
+		return Error("cannot format parameter '" + p.name + "' of type " + p.TypeName())
+	}
+	n, err := unpackInt(p.dataType, data)
+	if err != nil {
+		return err
+	}
+	if p.enum != nil {
+		if name, ok := p.enum.NameOf(n); ok {
+			writeJSONString(out, name)
+			return nil
+		}
+	}
+	if len(p.Transform) > 0 {
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return writeJSONFloat(p, out, p.Transform.apply(f))
+	}
+
+	if n.IsInt64() && -maxJSONInt <= n.Int64() && n.Int64() <= maxJSONInt {
+		out.WriteString(n.String())
+	} else {
+		out.WriteString(`"` + n.String() + `"`)
+	}
+	return nil
+}
+
+// writeJSONString writes s to out as a JSON string.
+func writeJSONString(out *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	out.Write(b)
+}
+
+// writeJSONFloat writes f to out as a JSON number.  JSON cannot represent infinities or NaN.
+func writeJSONFloat(p *Parameter, out *bytes.Buffer, f float64) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return Error("value " + strconv.FormatFloat(f, 'g', -1, 64) + " of parameter '" + p.name +
+			"' cannot be represented in JSON")
+	}
+	out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return nil
+}
+
+// JSONToField returns the packed value of the field described by the JSON document js.  Objects
+// must have a value for each argument, component, or member and no others, and may always be
+// written as arrays instead.  Values of 64-bit integers may also be written as decimal strings,
+// and enums and chars as numbers.
+// Each value is checked against the type, range, and enum of its parameter, after being
+// untransformed.
+func JSONToField(field Field, js []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, Error("invalid JSON value of field '" + field.Name() + "': " + err.Error())
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, Error("unexpected data after JSON value of field '" + field.Name() + "'")
+	}
+
+	var buf bytes.Buffer
+	if err := jsonToField(field, v, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonToField packs the decoded JSON value v of any field into buf.
+func jsonToField(f Field, v interface{}, buf *bytes.Buffer) error {
+	if p, ok := f.(*Parameter); ok {
+		return jsonToParameter(p, v, buf)
+	}
+	return jsonToMembers(f.Name(), f.NestedFields(), v, buf)
+}
+
+// jsonToMembers packs the decoded JSON object or array v holding the values of the arguments,
+// components, or members of the named field into buf.
+func jsonToMembers(name string, fields []Field, v interface{}, buf *bytes.Buffer) error {
+	switch v := v.(type) {
+	case []interface{}:
+		if len(v) != len(fields) {
+			return Error("JSON array for '" + name + "' has " + strconv.Itoa(len(v)) + " values, expected " +
+				strconv.Itoa(len(fields)))
+		}
+		for i, f := range fields {
+			if err := jsonToField(f, v[i], buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if !allNamed(fields) {
+			return Error("'" + name + "' has unnamed values, expected a JSON array")
+		}
+		for _, f := range fields {
+			value, ok := v[f.Name()]
+			if !ok {
+				return Error("JSON object for '" + name + "' is missing '" + f.Name() + "'")
+			}
+			if err := jsonToField(f, value, buf); err != nil {
+				return err
+			}
+		}
+		if len(v) > len(fields) {
+			for key := range v {
+				if !hasField(fields, key) {
+					return Error("JSON object for '" + name + "' has unknown key '" + key + "'")
+				}
+			}
+		}
+		return nil
+	}
+	return jsonTypeError(v, name, "an object or array")
+}
+
+// hasField returns whether one of the fields has the given name.
+func hasField(fields []Field, name string) bool {
+	for _, f := range fields {
+		if f.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonToParameter packs the decoded JSON value v of the parameter, which may be an array, into buf.
+func jsonToParameter(p *Parameter, v interface{}, buf *bytes.Buffer) error {
+	if !p.isArray {
+		return jsonToElement(p, v, buf)
+	}
+
+	elems, ok := v.([]interface{})
+	if !ok {
+		return jsonTypeError(v, p.name, "an array")
+	}
+	count := len(elems)
+	if p.arraySize > 0 {
+		if count != p.arraySize {
+			return Error("array value of parameter '" + p.name + "' has " + strconv.Itoa(count) +
+				" elements, expected " + strconv.Itoa(p.arraySize))
+		}
+		for _, elem := range elems {
+			if err := jsonToElement(p, elem, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !inRange(p.ArrayRange, big.NewRat(int64(count), 1)) {
+		return Error("array value has " + strconv.Itoa(count) + " elements, outside of the range of parameter '" +
+			p.name + "'")
+	}
+	var packed bytes.Buffer
+	for _, elem := range elems {
+		if err := jsonToElement(p, elem, &packed); err != nil {
+			return err
+		}
+	}
+	if err := packLength(packed.Len(), buf); err != nil {
+		return err
+	}
+	buf.Write(packed.Bytes())
+	return nil
+}
+
+// jsonToElement packs the decoded JSON value v of a single element of the parameter into buf.
+func jsonToElement(p *Parameter, v interface{}, buf *bytes.Buffer) error {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return Error("struct " + p.typeName + " is not defined")
+		}
+		return jsonToMembers(p.name, p.structType.fields, v, buf)
+	case StringType:
+		s, ok := v.(string)
+		if !ok {
+			return jsonTypeError(v, p.name, "a string")
+		}
+		return packString(p, s, buf)
+	case BlobType:
+		s, ok := v.(string)
+		if !ok {
+			return jsonTypeError(v, p.name, "a base64 string")
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return Error("invalid base64 value of parameter '" + p.name + "': " + err.Error())
+		}
+		return packString(p, string(b), buf)
+	}
+
+	var num *big.Rat
+	switch v := v.(type) {
+	case json.Number:
+		num = parseJSONNumber(string(v))
+	case string:
+		switch {
+		case p.enum != nil:
+			if n, ok := p.enum.ValueByName(v); ok {
+				num = new(big.Rat).SetInt(n)
+			}
+		case p.dataType == CharType:
+			if r, size := utf8.DecodeRuneInString(v); size == len(v) && size > 0 && r <= 0xff {
+				num = big.NewRat(int64(r), 1)
+			} else {
+				return Error("value of char parameter '" + p.name + "' must be a single character")
+			}
+		case p.dataType == Int64Type || p.dataType == Uint64Type:
+			num = parseJSONInteger(v)
+		}
+	default:
+		return jsonTypeError(v, p.name, "a number")
+	}
+	if num == nil {
+		return Error("invalid value " + strconv.Quote(jsonString(v)) + " of parameter '" + p.name + "'")
+	}
+	return packNumber(p, num, buf)
+}
+
+// parseJSONNumber parses a JSON number, or returns nil if s is not a number.
+func parseJSONNumber(s string) *big.Rat {
+	num, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	return num
+}
+
+// parseJSONInteger parses a decimal integer written as a JSON string, or returns nil if s is not
+// a decimal integer.
+func parseJSONInteger(s string) *big.Rat {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil
+	}
+	return parseJSONNumber(s)
+}
+
+// jsonString returns the text of a decoded JSON string or number.
+func jsonString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	}
+	return ""
+}
+
+// jsonTypeError creates an error for a decoded JSON value of the wrong type.
+func jsonTypeError(v interface{}, name, expected string) Error {
+	var found string
+	switch v.(type) {
+	case nil:
+		found = "null"
+	case bool:
+		found = "a bool"
+	case json.Number:
+		found = "a number"
+	case string:
+		found = "a string"
+	case []interface{}:
+		found = "an array"
+	case map[string]interface{}:
+		found = "an object"
+	}
+	return Error("JSON value of '" + name + "' is " + found + ", expected " + expected)
+}
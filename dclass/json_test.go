@@ -0,0 +1,142 @@
+package dclass
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const caseJSON = `
+enum Mode : uint8 {
+	Idle = 1,
+	Walk,
+};
+struct Point {
+	int16 / 10 x;
+	int16(-100-100) y;
+};
+dclass Avatar {
+	setPos(Point pos, Mode mode, char[2] tag);
+	setData(blob data, string name, uint64 id, int64 delta);
+	setPath(Point[0-2] path, uint8, float64 * 2 (0-10));
+	setLevel(uint8(1-99) level);
+	setScale(float64 scale);
+	setState : setPos, setLevel;
+};
+`
+
+func TestJSON(t *testing.T) {
+	dcf := mustParse(t, caseJSON)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+
+	tests := []struct {
+		field    string
+		value    string
+		expected string
+	}{
+		{"setPos", `({-4.5, 7}, Walk, {'a', 'b'})`,
+			`{"pos":{"x":-4.5,"y":7},"mode":"Walk","tag":["a","b"]}`},
+		{"setData", `("hi", "name", 18446744073709551615, -5)`,
+			`{"data":"aGk=","name":"name","id":"18446744073709551615","delta":-5}`},
+		{"setPath", `({{1, 2}, {3, 4}}, 5, 7.5)`,
+			`[[{"x":1,"y":2},{"x":3,"y":4}],5,7.5]`},
+		{"setState", `(({0.5, -1}, Idle, {'x', 'y'}), (99))`,
+			`{"setPos":{"pos":{"x":0.5,"y":-1},"mode":"Idle","tag":["x","y"]},"setLevel":{"level":99}}`},
+	}
+
+	for _, test := range tests {
+		field := avatar.FieldByName(test.field)
+		data, err := field.ParseString(test.value)
+		if err != nil {
+			t.Fatalf("unexpected error parsing value of %s: %s", test.field, err)
+		}
+
+		js, err := FieldToJSON(field, data.Bytes())
+		if err != nil {
+			t.Errorf("unexpected error formatting %s as JSON: %s", test.field, err)
+			continue
+		}
+		if string(js) != test.expected {
+			t.Errorf("formatted %s as %s, expected %s", test.field, js, test.expected)
+		}
+
+		packed, err := JSONToField(field, js)
+		if err != nil {
+			t.Errorf("unexpected error packing JSON value of %s: %s", test.field, err)
+		} else if !bytes.Equal(packed, data.Bytes()) {
+			t.Errorf("packed JSON value of %s as %s, expected %s", test.field,
+				field.FormatData(*bytes.NewBuffer(packed), false), test.value)
+		}
+	}
+
+	// alternate forms of values are accepted
+	alternates := []struct {
+		field    string
+		json     string
+		expected string
+	}{
+		{"setPos", `[[-45e-1, 7], 2, [97, "b"]]`, `({-4.5, 7}, Walk, {'a', 'b'})`},
+		{"setData", `{"delta": "-5", "id": 1, "name": "", "data": ""}`, `("", "", 1, -5)`},
+		{"setScale", `{"scale": 1e300}`, `(1` + strings.Repeat("0", 300) + `)`},
+	}
+	for _, test := range alternates {
+		field := avatar.FieldByName(test.field)
+		packed, err := JSONToField(field, []byte(test.json))
+		if err != nil {
+			t.Errorf("unexpected error packing JSON value %s: %s", test.json, err)
+			continue
+		}
+		if s := field.FormatData(*bytes.NewBuffer(packed), false); s != test.expected {
+			t.Errorf("packed JSON value %s as %s, expected %s", test.json, s, test.expected)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	dcf := mustParse(t, caseJSON)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+
+	tests := []struct {
+		field    string
+		json     string
+		expected string
+	}{
+		{"setLevel", `{"level": 300}`, "value 300 of parameter 'level' overflows uint8"},
+		{"setLevel", `{"level": 0}`, "value 0 is outside of the range of parameter 'level'"},
+		{"setLevel", `{"level": 1.5}`, "must be an integer"},
+		{"setLevel", `{"level": "x"}`, `invalid value "x" of parameter 'level'`},
+		{"setLevel", `{"level": "2"}`, `invalid value "2" of parameter 'level'`},
+		{"setLevel", `{"level": "0x10"}`, `invalid value "0x10" of parameter 'level'`},
+		{"setLevel", `{"level": "4/2"}`, `invalid value "4/2" of parameter 'level'`},
+		{"setLevel", `{"level": true}`, "JSON value of 'level' is a bool, expected a number"},
+		{"setScale", `{"scale": 1e400}`, "overflows float64"},
+		{"setScale", `{"scale": -1e400}`, "overflows float64"},
+		{"setData", `{"data": "", "name": "", "id": "1e3", "delta": 1}`, `invalid value "1e3" of parameter 'id'`},
+		{"setData", `{"data": "", "name": "", "id": 1, "delta": "0x10"}`, `invalid value "0x10" of parameter 'delta'`},
+		{"setData", `{"data": "", "name": "", "id": 1, "delta": "-"}`, `invalid value "-" of parameter 'delta'`},
+		{"setLevel", `{}`, "JSON object for 'setLevel' is missing 'level'"},
+		{"setLevel", `{"level": 1, "other": 2}`, "has unknown key 'other'"},
+		{"setLevel", `[1, 2]`, "has 2 values, expected 1"},
+		{"setLevel", `{"level": 1} 2`, "unexpected data after JSON value"},
+		{"setLevel", `{"level": `, "invalid JSON value"},
+		{"setPos", `{"pos": {"x": 3276.8, "y": 0}, "mode": "Idle", "tag": "ab"}`, "overflows int16"},
+		{"setPos", `{"pos": {"x": 0, "y": 0}, "mode": "Run", "tag": ["a", "b"]}`, `invalid value "Run"`},
+		{"setPos", `{"pos": {"x": 0, "y": 0}, "mode": 3, "tag": ["a", "b"]}`, "is not a member of enum Mode"},
+		{"setPos", `{"pos": {"x": 0, "y": 0}, "mode": 1, "tag": ["a"]}`, "has 1 elements, expected 2"},
+		{"setPos", `{"pos": {"x": 0, "y": 0}, "mode": 1, "tag": ["ab", "b"]}`, "must be a single character"},
+		{"setPos", `{"pos": null, "mode": 1, "tag": ["a", "b"]}`, "JSON value of 'pos' is null"},
+		{"setPath", `{"path": [], "x": 1, "y": 1}`, "has unnamed values, expected a JSON array"},
+		{"setPath", `[[], 1, 25]`, "is outside of the range"},
+		{"setPath", `[[{"x": 0, "y": 0}, {"x": 0, "y": 0}, {"x": 0, "y": 0}], 1, 1]`, "array value has 3 elements"},
+		{"setData", `{"data": "!", "name": "", "id": 1, "delta": 1}`, "invalid base64 value"},
+	}
+
+	for _, test := range tests {
+		_, err := JSONToField(avatar.FieldByName(test.field), []byte(test.json))
+		if err == nil {
+			t.Errorf("expected error packing JSON value %s", test.json)
+		} else if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("unexpected error packing JSON value %s: %s, expected %s", test.json, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package dclass
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// The built-in keywords are always defined, and occupy the first bits of every keyword table
+// so that they can be checked without a table lookup.
+const (
+	keywordRequired = iota
+	keywordRam
+	keywordBroadcast
+	keywordClrecv
+	keywordClsend
+	keywordOwnrecv
+	keywordOwnsend
+	keywordAirecv
+	keywordDb
+)
+
+var definedKeywords = []string{
+	keywordRequired:  "required",
+	keywordRam:       "ram",
+	keywordBroadcast: "broadcast",
+	keywordClrecv:    "clrecv",
+	keywordClsend:    "clsend",
+	keywordOwnrecv:   "ownrecv",
+	keywordOwnsend:   "ownsend",
+	keywordAirecv:    "airecv",
+	keywordDb:        "db",
+}
+
+// isDefinedKeyword returns whether the keyword is one of the built-in keywords.
+func isDefinedKeyword(keyword string) bool {
+	for _, word := range definedKeywords {
+		if keyword == word {
+			return true
+		}
+	}
+	return false
+}
+
+// a keywordTable assigns each keyword used within a dclass File a unique bit index.
+// The table is shared by the File and all of its fields.  Keywords may be added to it through
+// any set using it, concurrently with other sets reading it, so the table is guarded by mu.
+type keywordTable struct {
+	mu    sync.RWMutex
+	names []string       // keyword names by bit index
+	index map[string]int // bit index by keyword name
+}
+
+func newKeywordTable() *keywordTable {
+	t := &keywordTable{index: make(map[string]int)}
+	for _, keyword := range definedKeywords {
+		t.bit(keyword)
+	}
+	return t
+}
+
+// lookup returns the bit index of the keyword, if it is in the table.
+func (t *keywordTable) lookup(keyword string) (int, bool) {
+	t.mu.RLock()
+	i, ok := t.index[keyword]
+	t.mu.RUnlock()
+	return i, ok
+}
+
+// name returns the keyword with bit index i.
+func (t *keywordTable) name(i int) string {
+	t.mu.RLock()
+	name := t.names[i]
+	t.mu.RUnlock()
+	return name
+}
+
+// bit returns the bit index of the keyword, assigning a new index if it is not yet in the table.
+func (t *keywordTable) bit(keyword string) int {
+	if i, ok := t.lookup(keyword); ok {
+		return i
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[keyword]; ok {
+		return i
+	}
+	t.names = append(t.names, keyword)
+	t.index[keyword] = len(t.names) - 1
+	return len(t.names) - 1
+}
+
+// clone returns a copy of the table, which may be modified without modifying t.
+func (t *keywordTable) clone() *keywordTable {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := &keywordTable{names: append([]string(nil), t.names...), index: make(map[string]int, len(t.index))}
+	for keyword, i := range t.index {
+		c.index[keyword] = i
+	}
+	return c
+}
+
+// A KeywordSet is a set of keywords stored as a bitset over the keyword table of a dclass File.
+// KeywordSet satisfies the KeywordList interface.  Set operations between KeywordSets of the same
+// File only compare bits, which makes them suitable for checks on hot paths such as access control.
+//
+// The zero value is an empty set.  The set operations return new sets, while AddKeyword and
+// AddKeywords modify the set in place.
+type KeywordSet struct {
+	table *keywordTable
+	bits  []uint64
+}
+
+// NewKeywordSet returns a set of the keyword arguments using the File's keyword table.
+// Keywords which are not yet used by the File are added to its table.
+func (f *File) NewKeywordSet(keywords ...string) KeywordSet {
+	s := KeywordSet{table: f.keywordTable()}
+	for _, keyword := range keywords {
+		s.AddKeyword(keyword)
+	}
+	return s
+}
+
+// newKeywordSet returns an empty set using the File's keyword table.
+func (f *File) newKeywordSet() KeywordSet {
+	return KeywordSet{table: f.keywordTable()}
+}
+
+// keywordTable returns the table shared by the File and its fields, creating it if necessary.
+func (f *File) keywordTable() *keywordTable {
+	if f.KeywordSet.table == nil {
+		f.KeywordSet.table = newKeywordTable()
+	}
+	return f.KeywordSet.table
+}
+
+// KeywordsOf returns the keywords of the list as a new KeywordSet, so that modifying the set does
+// not modify the list.  For fields and files the set is a copy of their bits, using the File's
+// keyword table, otherwise it is built from the list's keywords.
+func KeywordsOf(list KeywordList) KeywordSet {
+	if s, ok := list.(interface{ keywordSet() KeywordSet }); ok {
+		set := s.keywordSet()
+		set.bits = append([]uint64(nil), set.bits...)
+		return set
+	}
+
+	var s KeywordSet
+	s.AddKeywords(list)
+	return s
+}
+
+func (s KeywordSet) keywordSet() KeywordSet {
+	return s
+}
+
+// has returns whether the bit at index i is set.
+func (s KeywordSet) has(i int) bool {
+	return i/64 < len(s.bits) && s.bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+// set sets the bit at index i.
+func (s *KeywordSet) set(i int) {
+	for i/64 >= len(s.bits) {
+		s.bits = append(s.bits, 0)
+	}
+	s.bits[i/64] |= 1 << uint(i%64)
+}
+
+// implementing KeywordList
+func (s *KeywordSet) AddKeyword(keyword string) {
+	if s.table == nil {
+		s.table = newKeywordTable()
+	}
+	s.set(s.table.bit(keyword))
+}
+
+// implementing KeywordList
+func (s *KeywordSet) AddKeywords(list KeywordList) {
+	if o, ok := list.(interface{ keywordSet() KeywordSet }); ok {
+		if other := o.keywordSet(); other.table == s.table && s.table != nil {
+			for i, word := range other.bits {
+				if i >= len(s.bits) {
+					s.bits = append(s.bits, 0)
+				}
+				s.bits[i] |= word
+			}
+			return
+		}
+	}
+	for _, keyword := range list.Keywords() {
+		s.AddKeyword(keyword)
+	}
+}
+
+// implementing KeywordList
+func (s KeywordSet) CompareKeywords(list KeywordList) bool {
+	if o, ok := list.(interface{ keywordSet() KeywordSet }); ok {
+		if other := o.keywordSet(); other.table == s.table {
+			return s.Equal(other)
+		}
+	}
+	if s.NumKeywords() != list.NumKeywords() {
+		return false
+	}
+	for _, keyword := range list.Keywords() {
+		if !s.HasKeyword(keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// implementing KeywordList
+func (s KeywordSet) HasKeyword(keyword string) bool {
+	if s.table == nil {
+		return false
+	}
+	i, ok := s.table.lookup(keyword)
+	return ok && s.has(i)
+}
+
+// implementing KeywordList.  Keywords are returned in the order of the File's keyword table:
+// the built-in keywords, followed by other keywords in the order they were first used.
+func (s KeywordSet) Keywords() []string {
+	var keywords []string
+	for i, word := range s.bits {
+		for word != 0 {
+			b := bits.TrailingZeros64(word)
+			keywords = append(keywords, s.table.name(i*64+b))
+			word &= word - 1
+		}
+	}
+	return keywords
+}
+
+// implementing KeywordList
+func (s KeywordSet) NumKeywords() int {
+	n := 0
+	for _, word := range s.bits {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+// IsEmpty returns whether the set contains no keywords.
+func (s KeywordSet) IsEmpty() bool {
+	for _, word := range s.bits {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns whether both sets contain the same keywords.
+func (s KeywordSet) Equal(o KeywordSet) bool {
+	s, o = align(s, o)
+	for i := 0; i < len(s.bits) || i < len(o.bits); i++ {
+		if s.word(i) != o.word(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns the set of keywords in either set.
+func (s KeywordSet) Union(o KeywordSet) KeywordSet {
+	s, o = align(s, o)
+	n := len(s.bits)
+	if len(o.bits) > n {
+		n = len(o.bits)
+	}
+	r := KeywordSet{table: s.table, bits: make([]uint64, n)}
+	for i := range r.bits {
+		r.bits[i] = s.word(i) | o.word(i)
+	}
+	return r
+}
+
+// Intersect returns the set of keywords in both sets.
+func (s KeywordSet) Intersect(o KeywordSet) KeywordSet {
+	s, o = align(s, o)
+	n := len(s.bits)
+	if len(o.bits) < n {
+		n = len(o.bits)
+	}
+	r := KeywordSet{table: s.table, bits: make([]uint64, n)}
+	for i := range r.bits {
+		r.bits[i] = s.bits[i] & o.bits[i]
+	}
+	return r
+}
+
+// Difference returns the set of keywords in s which are not in o.
+func (s KeywordSet) Difference(o KeywordSet) KeywordSet {
+	s, o = align(s, o)
+	r := KeywordSet{table: s.table, bits: make([]uint64, len(s.bits))}
+	for i := range r.bits {
+		r.bits[i] = s.bits[i] &^ o.word(i)
+	}
+	return r
+}
+
+// Intersects returns whether the sets have any keyword in common.
+func (s KeywordSet) Intersects(o KeywordSet) bool {
+	s, o = align(s, o)
+	for i := 0; i < len(s.bits) && i < len(o.bits); i++ {
+		if s.bits[i]&o.bits[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether every keyword of o is also in s.
+func (s KeywordSet) Contains(o KeywordSet) bool {
+	s, o = align(s, o)
+	for i, word := range o.bits {
+		if word&^s.word(i) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// word returns the i-th word of the bitset, or 0 if the bitset is shorter.
+func (s KeywordSet) word(i int) uint64 {
+	if i < len(s.bits) {
+		return s.bits[i]
+	}
+	return 0
+}
+
+// align returns both sets using the same keyword table.  Sets of the same File are returned
+// unchanged; otherwise o is converted to the table of s.  Comparing sets should not add keywords
+// to the table of a File, so if s's table lacks any keywords of o, both sets are converted to a
+// copy of s's table with the missing keywords added.
+func align(s, o KeywordSet) (KeywordSet, KeywordSet) {
+	switch {
+	case s.table == o.table:
+		return s, o
+	case s.table == nil:
+		return KeywordSet{table: o.table}, o
+	case o.table == nil:
+		return s, KeywordSet{table: s.table}
+	}
+
+	keywords := o.Keywords()
+	for _, keyword := range keywords {
+		if _, ok := s.table.lookup(keyword); !ok {
+			s.table = s.table.clone()
+			break
+		}
+	}
+	r := KeywordSet{table: s.table}
+	for _, keyword := range keywords {
+		r.set(s.table.bit(keyword))
+	}
+	return s, r
+}
+
+// KeywordSemantics defines the application specific meaning of a keyword.  Semantics are
+// registered with RegisterKeyword and apply to every dclass File parsed afterwards.
+type KeywordSemantics struct {
+	// Validate is called after parsing with each field that has the keyword.  If it returns
+	// an error, parsing fails with that error.  Validate may be nil.
+	Validate func(field Field) error
+
+	// Flags are application defined bits associated with the keyword, for example to map
+	// keywords onto message routing behavior. See KeywordFlags.
+	Flags uint64
+}
+
+var keywordRegistry = struct {
+	sync.RWMutex
+	semantics map[string]KeywordSemantics
+}{semantics: make(map[string]KeywordSemantics)}
+
+// RegisterKeyword associates semantics with a keyword, replacing any semantics previously
+// registered for it.  The keyword may be a built-in keyword or one declared by a dclass File;
+// registering semantics does not declare the keyword.
+func RegisterKeyword(keyword string, semantics KeywordSemantics) {
+	keywordRegistry.Lock()
+	keywordRegistry.semantics[keyword] = semantics
+	keywordRegistry.Unlock()
+}
+
+// UnregisterKeyword removes any semantics registered for the keyword.
+func UnregisterKeyword(keyword string) {
+	keywordRegistry.Lock()
+	delete(keywordRegistry.semantics, keyword)
+	keywordRegistry.Unlock()
+}
+
+// LookupKeyword returns the semantics registered for the keyword.
+func LookupKeyword(keyword string) (KeywordSemantics, bool) {
+	keywordRegistry.RLock()
+	semantics, ok := keywordRegistry.semantics[keyword]
+	keywordRegistry.RUnlock()
+	return semantics, ok
+}
+
+// KeywordFlags returns the union of the flags registered for each keyword in the list.
+func KeywordFlags(list KeywordList) uint64 {
+	var flags uint64
+	keywordRegistry.RLock()
+	for _, keyword := range list.Keywords() {
+		flags |= keywordRegistry.semantics[keyword].Flags
+	}
+	keywordRegistry.RUnlock()
+	return flags
+}
+
+// validateKeywords calls the registered validator of each keyword on the field.
+func validateKeywords(field Field) []error {
+	var errs []error
+	for _, keyword := range field.Keywords() {
+		semantics, ok := LookupKeyword(keyword)
+		if !ok || semantics.Validate == nil {
+			continue
+		}
+		if err := semantics.Validate(field); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,95 @@
+package dclass
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestKeywordSet(t *testing.T) {
+	dcf := NewFile()
+	dcf.AddKeyword("p2p")
+	dcf.AddKeyword("p2p")
+	if !dcf.HasKeyword("p2p") || dcf.NumKeywords() != 1 {
+		t.Errorf("file: got keywords %v, expected [p2p]", dcf.Keywords())
+	}
+
+	c := dcf.AddType("A", "class").(*Class)
+	f := c.AddField("setX", "atomic")
+	f.AddKeyword("p2p")
+	f.AddKeyword("ram")
+	f.AddKeyword("broadcast")
+	if !f.IsRam() || !f.IsBroadcast() || f.IsDb() || !f.HasKeyword("p2p") {
+		t.Errorf("setX: got keywords %v", f.Keywords())
+	}
+	if got, expected := f.Keywords(), []string{"ram", "broadcast", "p2p"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("setX: got keywords %v, expected %v", got, expected)
+	}
+
+	set := KeywordsOf(f)
+	clientSend := dcf.NewKeywordSet("clsend", "ownsend")
+	if set.Intersects(clientSend) {
+		t.Errorf("setX should not intersect %v", clientSend.Keywords())
+	}
+	if got := set.Union(clientSend).NumKeywords(); got != 5 {
+		t.Errorf("union: got %d keywords, expected 5", got)
+	}
+	if got := set.Intersect(dcf.NewKeywordSet("ram", "db")).Keywords(); !reflect.DeepEqual(got, []string{"ram"}) {
+		t.Errorf("intersect: got %v, expected [ram]", got)
+	}
+	if got := set.Difference(dcf.NewKeywordSet("ram")).Keywords(); !reflect.DeepEqual(got, []string{"broadcast", "p2p"}) {
+		t.Errorf("difference: got %v, expected [broadcast p2p]", got)
+	}
+	if !set.Contains(dcf.NewKeywordSet("p2p", "ram")) || set.Contains(clientSend) {
+		t.Errorf("contains: unexpected result for %v", set.Keywords())
+	}
+
+	// sets of different files are compared by name
+	other := NewFile().NewKeywordSet("broadcast", "p2p", "ram")
+	if !set.Equal(other) || !f.CompareKeywords(&other) {
+		t.Errorf("expected %v to equal %v", set.Keywords(), other.Keywords())
+	}
+	if got := set.Difference(other); !got.IsEmpty() {
+		t.Errorf("difference: got %v, expected an empty set", got.Keywords())
+	}
+
+	// comparing with keywords of other files does not add them to the file
+	foreign := NewFile().NewKeywordSet("ram", "audit")
+	if set.Contains(foreign) || !set.Union(foreign).HasKeyword("audit") || set.Equal(foreign) {
+		t.Errorf("unexpected result comparing %v and %v", set.Keywords(), foreign.Keywords())
+	}
+	if _, ok := dcf.keywordTable().index["audit"]; ok {
+		t.Errorf("comparing sets added a foreign keyword to the file's table")
+	}
+
+	// the set of a field is a copy
+	set.AddKeyword("db")
+	set.AddKeywords(&clientSend)
+	if f.IsDb() || f.NumKeywords() != 3 {
+		t.Errorf("modifying the set of setX modified its keywords %v", f.Keywords())
+	}
+}
+
+func TestKeywordSetConcurrent(t *testing.T) {
+	dcf := mustParse(t, "keyword p2p;\ndclass A {\n\tsetX(uint8) p2p;\n};\n")
+	f := dcf.ClassByName["A"].(*Class).FieldByName("setX")
+
+	// sets of fields add keywords to the file's table while other goroutines read it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				set := KeywordsOf(f)
+				set.AddKeyword("k" + strconv.Itoa(i) + "_" + strconv.Itoa(j))
+				if !f.HasKeyword("p2p") || f.HasKeyword("k0_0") || len(set.Keywords()) != 2 {
+					t.Errorf("unexpected keywords %v of setX", set.Keywords())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,8 +1,13 @@
 package dclass
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -34,8 +39,9 @@ type tokenType int
 
 const (
 	// Lexer types
-	tokenError tokenType = iota // error occurred; value is text of error
-	tokenEOF                    // end of file
+	tokenError   tokenType = iota // error occurred; value is text of error
+	tokenEOF                      // end of file
+	tokenComment                  // comment (delimiters included); kept by the lexer, not passed to the parser
 
 	// Value types
 	tokenBool    // boolean constant
@@ -63,6 +69,8 @@ const (
 	tokenKeyword  // 'keyword' keyword
 	tokenDClass   // 'dclass' keyword
 	tokenStruct   // 'struct' keyword
+	tokenEnum     // 'enum' keyword
+	tokenConst    // 'const' keyword
 
 	// Variable-type keyword types
 	tokenTypeDelim // used only to delimit the data type keywords
@@ -85,6 +93,8 @@ var key = map[string]tokenType{
 	"keyword": tokenKeyword,
 	"dclass":  tokenDClass,
 	"struct":  tokenStruct,
+	"enum":    tokenEnum,
+	"const":   tokenConst,
 
 	// variable types
 	"int8":    tokenInt8,
@@ -103,8 +113,9 @@ var key = map[string]tokenType{
 
 // Make the types prettyprint.
 var tokenName = map[tokenType]string{
-	tokenError: "error",
-	tokenEOF:   "EOF",
+	tokenError:   "error",
+	tokenEOF:     "EOF",
+	tokenComment: "comment",
 
 	tokenBool:    "bool",
 	tokenRawchar: "char-constant",
@@ -117,6 +128,8 @@ var tokenName = map[tokenType]string{
 	tokenRightParen:  ")",
 	tokenLeftCurly:   "{",
 	tokenRightCurly:  "}",
+	tokenLeftSquare:  "[",
+	tokenRightSquare: "]",
 	tokenComposition: ":",
 	tokenEndline:     ";",
 	tokenSeperator:   ",",
@@ -126,6 +139,8 @@ var tokenName = map[tokenType]string{
 	tokenKeyword: "keyword",
 	tokenDClass:  "dclass",
 	tokenStruct:  "struct",
+	tokenEnum:    "enum",
+	tokenConst:   "const",
 
 	tokenInt8:   "int8",
 	tokenInt16:  "int16",
@@ -134,7 +149,7 @@ var tokenName = map[tokenType]string{
 	tokenUint8:  "uint8",
 	tokenUint16: "uint16",
 	tokenUint32: "uint32",
-	tokenUint64: "uint65",
+	tokenUint64: "uint64",
 	tokenFloat:  "float64",
 	tokenString: "string",
 	tokenBlob:   "blob",
@@ -154,35 +169,64 @@ const eof = -1
 // lexerFn represents the state of the scanner as a function that returns the next state.
 type lexerFn func(*lexer) lexerFn
 
-// lexer holds the state of the scanner.
+// lexer holds the state of the scanner.  The scanner reads the input as it is needed, and only
+// keeps the text of the token being scanned.
 type lexer struct {
-	input       string  // the string being scanned
-	state       lexerFn // the next lexing function to enter
-	pos         int     // current position in the input
-	start       int     // start position of this token
-	width       int     // width of last rune read from input
-	parenDepth  int     // nesting depth of ( ) exprs
-	curlyDepth  int     // nesting depth of { } blocks
-	squareDepth int     // nesting depth of [ ] arrays -- should never be > 1
-	canBackup   bool    // if backup has been called for this rune
+	input       io.RuneScanner // the input being scanned
+	readErr     error          // the error reading the input, which stopped the scanner
+	text        []byte         // the text of this token, from start to pos
+	state       lexerFn        // the next lexing function to enter
+	pos         int            // current position in the input
+	start       int            // start position of this token
+	width       int            // width of last rune read from input
+	last        rune           // last rune read from input
+	parenDepth  int            // nesting depth of ( ) exprs
+	curlyDepth  int            // nesting depth of { } blocks
+	squareDepth int            // nesting depth of [ ] arrays -- should never be > 1
+	canBackup   bool           // if backup has been called for this rune
+
+	// the positions at which the lines of the input start, which are shared by the scanner
+	// and its client
+	linesMu sync.Mutex
+	lines   []int
 
 	// variables for lexer token output
-	lastPos     int        // position of most recent token returned by nextToken
-	tokens      chan token // channel of scanned tokens
-	peekedToken token      // the previous token to come out (for peek)
-	hasPeeked   bool       // if true peekedToken is the next token
-}
-
-// next returns the next rune in the input.
+	lastPos     int               // position of most recent token returned by nextToken
+	tokens      chan token        // channel of scanned tokens
+	peekedToken token             // the previous token to come out (for peek)
+	hasPeeked   bool              // if true peekedToken is the next token
+	comments    []token           // comments read before the next token, see takeComments
+	lastEnd     int               // end position of the last token received, or -1
+	lineStarts  map[int]bool      // positions of the kept comments which start a line
+	directives  []token           // lint directive comments read so far, see isLintDirective
+	done        chan struct{}     // closed by close to stop the scanner
+	check       func(token) error // if set, checks each token received, see receive
+	err         error             // the error returned by check, which stopped the lexer
+}
+
+// next returns the next rune in the input.  If the input cannot be read, an error token is
+// sent, and the scanner's goroutine exits.
 func (l *lexer) next() rune {
-	if int(l.pos) >= len(l.input) {
+	r, w, err := l.input.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.readErr = err
+			l.errorf("%v", err)
+			runtime.Goexit()
+		}
 		l.width = 0
+		l.canBackup = false
 		return eof
 	}
-	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
-	l.width = w
+	l.width, l.last = w, r
 	l.pos += l.width
+	l.text = utf8.AppendRune(l.text, r)
 	l.canBackup = true
+	if r == '\n' {
+		l.linesMu.Lock()
+		l.lines = append(l.lines, l.pos)
+		l.linesMu.Unlock()
+	}
 	return r
 }
 
@@ -196,20 +240,42 @@ func (l *lexer) peek() rune {
 // backup steps back one rune. Can only be called once per call of next.
 func (l *lexer) backup() {
 	if l.canBackup {
+		l.input.UnreadRune()
 		l.pos -= l.width
+		l.text = l.text[:len(l.text)-l.width]
 		l.canBackup = false
+		if l.last == '\n' {
+			l.linesMu.Lock()
+			l.lines = l.lines[:len(l.lines)-1]
+			l.linesMu.Unlock()
+		}
 	}
 }
 
 // emit passes an token back to the client.
 func (l *lexer) emit(t tokenType) {
-	l.tokens <- token{t, l.start, l.input[l.start:l.pos]}
-	l.start = l.pos
+	l.send(token{t, l.start, string(l.text)})
+	l.ignore()
+}
+
+// send passes a token to the client, or exits the scanner's goroutine if the lexer is closed.
+func (l *lexer) send(t token) {
+	select {
+	case l.tokens <- t:
+	case <-l.done:
+		runtime.Goexit()
+	}
+}
+
+// close stops the scanner, which may be waiting to send a token the client will never receive.
+func (l *lexer) close() {
+	close(l.done)
 }
 
 // ignore skips over the pending input before this point.
 func (l *lexer) ignore() {
 	l.start = l.pos
+	l.text = l.text[:0]
 }
 
 // accept consumes the next rune if it's from the valid set.
@@ -233,13 +299,38 @@ func (l *lexer) acceptRun(valid string) {
 // the previous token returned by nextToken. Doing it this way
 // means we don't have to worry about peek double counting.
 func (l *lexer) lineNumber() int {
-	return 1 + strings.Count(l.input[:l.lastPos], "\n")
+	return l.positionOf(l.lastPos).Line
+}
+
+// position reports the line and column of the previous token returned by nextToken.
+func (l *lexer) position() Pos {
+	return l.positionOf(l.lastPos)
+}
+
+// positionOf reports the line and column of the byte offset pos in the input, which must have
+// been scanned.
+func (l *lexer) positionOf(pos int) Pos {
+	l.linesMu.Lock()
+	defer l.linesMu.Unlock()
+	line := sort.SearchInts(l.lines, pos+1)
+	return Pos{line, pos - l.lines[line-1] + 1}
+}
+
+// lineEnd returns the position of the end of the line of the byte offset pos, after its line
+// break, or -1 if the end of the line has not been scanned.
+func (l *lexer) lineEnd(pos int) int {
+	l.linesMu.Lock()
+	defer l.linesMu.Unlock()
+	if line := sort.SearchInts(l.lines, pos+1); line < len(l.lines) {
+		return l.lines[line]
+	}
+	return -1
 }
 
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.nextToken.
 func (l *lexer) errorf(format string, args ...interface{}) lexerFn {
-	l.tokens <- token{tokenError, l.start, fmt.Sprintf(format, args...)}
+	l.send(token{tokenError, l.start, fmt.Sprintf(format, args...)})
 	return nil
 }
 
@@ -250,7 +341,7 @@ func (l *lexer) nextToken() token {
 		l.hasPeeked = false
 		t = l.peekedToken
 	} else {
-		t = <-l.tokens
+		t = l.receive()
 	}
 
 	l.lastPos = t.pos
@@ -261,16 +352,75 @@ func (l *lexer) nextToken() token {
 func (l *lexer) peekToken() token {
 	if !l.hasPeeked {
 		l.hasPeeked = true
-		l.peekedToken = <-l.tokens
+		l.peekedToken = l.receive()
 	}
 	return l.peekedToken
 }
 
+// receive returns the next token from the scanner which is not a comment.  Comments are
+// kept in order until they are taken by takeComments.  If check returns an error for a token,
+// the lexer is stopped, and a tokenError is returned instead.
+func (l *lexer) receive() token {
+	for {
+		if l.err != nil {
+			return token{tokenError, l.lastPos, l.err.Error()}
+		}
+		t := <-l.tokens
+		if l.check != nil && t.typ != tokenError && t.typ != tokenEOF {
+			if l.err = l.check(t); l.err != nil {
+				return token{tokenError, t.pos, l.err.Error()}
+			}
+		}
+		startsLine := l.lastEnd < 0 || l.positionOf(l.lastEnd).Line < l.positionOf(t.pos).Line
+		l.lastEnd = t.pos + len(t.val)
+		if t.typ != tokenComment {
+			return t
+		}
+		if startsLine {
+			l.lineStarts[t.pos] = true
+		}
+		if isLintDirective(t.val) {
+			l.directives = append(l.directives, t)
+		}
+		l.comments = append(l.comments, t)
+	}
+}
+
+// takeComments removes and returns the kept comments which start before the position pos.
+func (l *lexer) takeComments(pos int) []token {
+	n := 0
+	for n < len(l.comments) && l.comments[n].pos < pos {
+		n++
+	}
+	taken := l.comments[:n:n]
+	l.comments = l.comments[n:]
+	return taken
+}
+
+// startsLine reports whether the comment at the position pos, which was received by the
+// client, is preceded only by spaces on its line.
+func (l *lexer) startsLine(pos int) bool {
+	return l.lineStarts[pos]
+}
+
 // lex creates a new scanner for the input string.
 func lex(input string) *lexer {
+	return lexReader(strings.NewReader(input))
+}
+
+// lexReader creates a new scanner which reads the input from r as it is scanned.
+func lexReader(r io.Reader) *lexer {
+	input, ok := r.(io.RuneScanner)
+	if !ok {
+		input = bufio.NewReader(r)
+	}
 	l := &lexer{
-		input:  input,
-		tokens: make(chan token),
+		input:      input,
+		lines:      []int{0},
+		tokens:     make(chan token),
+		done:       make(chan struct{}),
+		lastEnd:    -1,
+		lineStarts: make(map[int]bool),
 	}
 	go l.run()
 	return l
@@ -286,20 +436,23 @@ func (l *lexer) run() {
 // state functions
 
 const (
-	endlineChars      = "\r\n"
 	leftComment       = "//"
 	leftBlockComment  = "/*"
-	rightComment      = "\n" // Note, also accepts '\r'
 	rightBlockComment = "*/"
 )
 
 // lexAny scans for any token (keyword, struct, or dclass)
 func lexAny(l *lexer) lexerFn {
 	if l.squareDepth > 0 {
-		r := l.next()
-		if r == ']' {
+		switch r := l.next(); r {
+		case ']':
 			l.emit(tokenRightSquare)
-		} else {
+			l.squareDepth--
+		case '-':
+			// the array size is a range, ie. "[min-max]"
+			l.emit(tokenOperator)
+			return lexNumber
+		default:
 			return l.errorf("found opening '[' without matching ']' for array type definition.")
 		}
 	}
@@ -390,24 +543,34 @@ func lexAny(l *lexer) lexerFn {
 
 // lexComment scans a single-line comment. The left delimiter is pre-consumed.
 func lexComment(l *lexer) lexerFn {
-	i := strings.IndexAny(l.input[l.pos:], endlineChars)
-	if i < 0 {
-		return l.errorf("no new line after comment")
+	for {
+		switch r := l.next(); {
+		case r == eof:
+			return l.errorf("no new line after comment")
+		case isEndOfLine(r):
+			l.backup()
+			l.emit(tokenComment)
+			l.next() // consume the first end-line character
+			l.ignore()
+			return lexAny
+		}
 	}
-	l.pos += i + len(rightComment)
-	l.ignore()
-	return lexAny
 }
 
 // lexBlockComment scans a block comment. The left delimiter is pre-consumed.
 func lexBlockComment(l *lexer) lexerFn {
-	i := strings.Index(l.input[l.pos:], rightBlockComment)
-	if i < 0 {
-		return l.errorf("unclosed block comment")
+	for {
+		switch l.next() {
+		case eof:
+			return l.errorf("unclosed block comment")
+		case '*':
+			if l.peek() == '/' {
+				l.next()
+				l.emit(tokenComment)
+				return lexAny
+			}
+		}
 	}
-	l.pos += i + len(rightBlockComment)
-	l.ignore()
-	return lexAny
 }
 
 // lexSpace scans a run of space and/or end-line characters.
@@ -429,7 +592,7 @@ Loop:
 			// absorb.
 		default:
 			l.backup()
-			word := l.input[l.start:l.pos]
+			word := string(l.text)
 			if !l.atTerminator() {
 				return l.errorf("bad character in identifier %#U", r)
 			}
@@ -511,7 +674,7 @@ func lexNumber(l *lexer) lexerFn {
 		} else if l.accept("bB") {
 			encode = binaryEncode
 			digits = binaryDigits
-		} else {
+		} else if strings.ContainsRune(decimalDigits, l.peek()) {
 			encode = octalEncode
 			digits = octalDigits
 		}
@@ -532,7 +695,7 @@ func lexNumber(l *lexer) lexerFn {
 	// Next thing must not be alphanumeric.
 	if isAlphaNumeric(l.peek()) {
 		l.next()
-		return l.errorf("bad number syntax: %q", l.input[l.start:l.pos])
+		return l.errorf("bad number syntax: %q", l.text)
 	}
 
 	// Emit number
@@ -46,6 +46,7 @@ var lexTests = []lexTest{
 	{"hex", "0x5", []token{{tokenNumber, 0, "0x5"}, tEOF}},
 	{"oct", "0107", []token{{tokenNumber, 0, "0107"}, tEOF}},
 	{"bin", "0b110", []token{{tokenNumber, 0, "0b110"}, tEOF}},
+	{"fraction", "0.25", []token{{tokenNumber, 0, "0.25"}, tEOF}},
 	{"characters", `'a' '\n' '\'' '\\' '\u00FF' '\xFF' '本'`, []token{
 		{tokenRawchar, 0, `'a'`},
 		{tokenRawchar, 0, `'\n'`},
@@ -61,10 +62,12 @@ var lexTests = []lexTest{
 		{tokenBool, 0, "false"},
 		tEOF,
 	}},
-	{"declarations", "dclass struct keyword", []token{
+	{"declarations", "dclass struct keyword enum const", []token{
 		{tokenDClass, 0, "dclass"},
 		{tokenStruct, 0, "struct"},
 		{tokenKeyword, 0, "keyword"},
+		{tokenEnum, 0, "enum"},
+		{tokenConst, 0, "const"},
 		tEOF,
 	}},
 	{"variable types", "int8 uint32 uint8 int16 float64 blob string", []token{
@@ -101,6 +104,21 @@ var lexTests = []lexTest{
 		{tokenEndline, 0, `;`},
 		tEOF,
 	}},
+	{"array types", "uint8[4] uint16[2-8] string[]", []token{
+		{tokenUint8, 0, "uint8"},
+		{tokenLeftSquare, 0, "["},
+		{tokenNumber, 0, "4"},
+		{tokenRightSquare, 0, "]"},
+		{tokenUint16, 0, "uint16"},
+		{tokenLeftSquare, 0, "["},
+		{tokenNumber, 0, "2"},
+		{tokenOperator, 0, "-"},
+		{tokenNumber, 0, "8"},
+		{tokenRightSquare, 0, "]"},
+		{tokenString, 0, "string"},
+		{tokenVarArray, 0, "[]"},
+		tEOF,
+	}},
 	{"simple atomic", "interact(uint32) broadcast;", []token{
 		{tokenIdentifier, 0, "interact"},
 		tLeft, {tokenUint32, 0, "uint32"}, tRight,
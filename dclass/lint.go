@@ -0,0 +1,389 @@
+package dclass
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Severity is the level at which a lint rule reports issues.  Severities are ordered, and a
+// rule with the severity LintOff is not checked.
+type Severity int
+
+const (
+	LintOff Severity = iota
+	LintInfo
+	LintWarning
+	LintError
+)
+
+var severityName = map[Severity]string{
+	LintOff:     "off",
+	LintInfo:    "info",
+	LintWarning: "warning",
+	LintError:   "error",
+}
+
+// implements Stringer interface
+func (s Severity) String() string {
+	return severityName[s]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Severity) MarshalText() ([]byte, error) {
+	if name, ok := severityName[s]; ok {
+		return []byte(name), nil
+	}
+	return nil, Error("invalid severity " + strconv.Itoa(int(s)))
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityName {
+		if name == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+	return Error("unknown severity " + strconv.Quote(string(text)))
+}
+
+// A LintRule is an opinionated check of a File made by Lint, for declarations which are valid
+// but likely to be mistakes.
+type LintRule struct {
+	Name     string
+	Severity Severity // the severity of the rule, unless configured by LintOptions
+	Doc      string   // a description of the issues reported by the rule
+
+	check func(l *linter)
+}
+
+// LintRules are the rules checked by Lint.
+var LintRules = []*LintRule{
+	{"clsend-unbounded", LintWarning, "clsend fields with a string, blob, or variable-length " +
+		"array without a range, which clients can send at any size", (*linter).checkClsendUnbounded},
+	{"send-without-airecv", LintWarning, "clsend or ownsend fields which are not airecv, so " +
+		"updates sent by clients are not received by AIs", (*linter).checkSendWithoutAirecv},
+	{"db-without-required", LintWarning, "db fields which are not required, so objects may be " +
+		"stored without a value", (*linter).checkDbWithoutRequired},
+	{"broadcast-without-ram", LintWarning, "broadcast fields which are not ram, so clients " +
+		"which see an object after an update do not receive its value", (*linter).checkBroadcastWithoutRam},
+	{"unused-struct", LintWarning, "structs which are not the type of any parameter",
+		(*linter).checkUnusedStructs},
+	{"empty-class", LintInfo, "classes without any declared or inherited fields",
+		(*linter).checkEmptyClasses},
+	{"large-fixed-size", LintWarning, "fields of a fixed size larger than the maximum size, by default 1024 bytes, " +
+		"which are sent in full with every update", (*linter).checkLargeFixedSize},
+}
+
+// DefaultMaxFixedSize is the size in bytes above which the large-fixed-size rule reports fields,
+// if LintOptions.MaxFixedSize is not set.
+const DefaultMaxFixedSize = 1024
+
+// LintOptions configures the rules checked by Lint.  The zero value checks every rule at its
+// default severity.
+type LintOptions struct {
+	Rules        map[string]Severity `json:"rules,omitempty"`        // the severity of rules, by name
+	MaxFixedSize int                 `json:"maxFixedSize,omitempty"` // see the large-fixed-size rule
+}
+
+// A LintIssue is a problem reported by a lint rule.
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Pos      Pos      `json:"pos"`
+	Path     string   `json:"path"` // the declaration with the problem, ie. "Avatar.setName"
+	Msg      string   `json:"message"`
+}
+
+// implements Stringer interface
+func (issue LintIssue) String() string {
+	return issue.Pos.String() + ": " + issue.Severity.String() + ": " + issue.Path + ": " + issue.Msg +
+		" (" + issue.Rule + ")"
+}
+
+// Lint checks the File with the lint rules configured by opts, and returns the issues found,
+// ordered by position.  Issues are suppressed by comments in the source of the File:
+//
+//	// lint:ignore rule[,rule...] [reason]
+//	// lint:file-ignore rule[,rule...] [reason]
+//
+// The first form suppresses issues reported on the same line as the comment or on the next
+// line, and the second form suppresses issues anywhere in the file.
+func Lint(f *File, opts LintOptions) ([]LintIssue, error) {
+	for name := range opts.Rules {
+		if lintRule(name) == nil {
+			return nil, Error("unknown lint rule " + strconv.Quote(name))
+		}
+	}
+
+	l := linter{f: f, maxFixedSize: opts.MaxFixedSize}
+	if l.maxFixedSize <= 0 {
+		l.maxFixedSize = DefaultMaxFixedSize
+	}
+	for _, rule := range LintRules {
+		l.rule, l.severity = rule, rule.Severity
+		if severity, ok := opts.Rules[rule.Name]; ok {
+			l.severity = severity
+		}
+		if l.severity != LintOff {
+			rule.check(&l)
+		}
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i].Pos, l.issues[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.issues, nil
+}
+
+func lintRule(name string) *LintRule {
+	for _, rule := range LintRules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// A lintSuppression is a lint:ignore or lint:file-ignore comment in the source of a File.
+type lintSuppression struct {
+	line  int
+	rules []string
+	file  bool // whether the comment is a lint:file-ignore
+}
+
+// isLintDirective reports whether the text of a comment is a lint directive.
+func isLintDirective(comment string) bool {
+	return strings.HasPrefix(comment, leftComment) &&
+		strings.HasPrefix(strings.TrimLeft(comment[len(leftComment):], " \t"), "lint:")
+}
+
+// lintSuppressions returns the suppressions of the lint directives read by the lexer, and a
+// warning for each directive which is not valid.
+func (p *parser) lintSuppressions() ([]lintSuppression, ErrorList) {
+	var suppressions []lintSuppression
+	var warnings ErrorList
+	for _, t := range p.lex.directives {
+		pos := p.lex.positionOf(t.pos)
+
+		words := strings.Fields(t.val[len(leftComment):])
+		var s lintSuppression
+		switch {
+		case len(words) < 2:
+			warnings = append(warnings, semanticWarning("lint directive without rules: "+t.val, pos))
+			continue
+		case words[0] == "lint:ignore":
+			s = lintSuppression{line: pos.Line}
+		case words[0] == "lint:file-ignore":
+			s = lintSuppression{file: true}
+		default:
+			warnings = append(warnings, semanticWarning("unknown lint directive "+words[0], pos))
+			continue
+		}
+
+		for _, rule := range strings.Split(words[1], ",") {
+			if lintRule(rule) == nil {
+				warnings = append(warnings, semanticWarning("unknown lint rule "+strconv.Quote(rule)+
+					" in "+words[0], pos))
+			}
+			s.rules = append(s.rules, rule)
+		}
+		suppressions = append(suppressions, s)
+	}
+	return suppressions, warnings
+}
+
+// a linter accumulates the issues found by the lint rules.
+type linter struct {
+	f            *File
+	maxFixedSize int
+
+	rule     *LintRule // the rule being checked
+	severity Severity  // the configured severity of the rule
+	issues   []LintIssue
+}
+
+// report adds an issue of the current rule, unless it is suppressed.
+func (l *linter) report(pos Pos, path, msg string) {
+	for _, s := range l.f.lintSuppressions {
+		if !s.file && (pos.Line < s.line || pos.Line > s.line+1) {
+			continue
+		}
+		for _, rule := range s.rules {
+			if rule == l.rule.Name {
+				return
+			}
+		}
+	}
+	l.issues = append(l.issues, LintIssue{l.rule.Name, l.severity, pos, path, msg})
+}
+
+// classFields calls fn for each field declared by a class, except molecular fields, whose
+// keywords and values are those of their components.
+func (l *linter) classFields(fn func(c *Class, f Field)) {
+	for _, typ := range l.f.Classes {
+		if c, ok := typ.(*Class); ok {
+			for _, f := range c.fields {
+				if _, ok := f.(*MolecularField); !ok {
+					fn(c, f)
+				}
+			}
+		}
+	}
+}
+
+func (l *linter) checkClsendUnbounded() {
+	l.classFields(func(c *Class, f Field) {
+		if !f.IsClsend() {
+			return
+		}
+		params := []Field{f}
+		if atomic, ok := f.(*AtomicField); ok {
+			params = atomic.args
+		}
+		for i, param := range params {
+			name := param.Name()
+			if name == "" {
+				name = "argument " + strconv.Itoa(i)
+			}
+			if kind, member := unbounded(param.(*Parameter), map[*Struct]bool{}); kind != "" {
+				if member != "" {
+					name += "." + member
+				}
+				l.report(f.Pos(), c.name+"."+f.Name(), "clsend field has "+kind+" "+name+" without a range")
+			}
+		}
+	})
+}
+
+// unbounded returns the kind of a string, blob, or variable-length array which has no range, in
+// a parameter or the members of its struct.  The name of the member is returned for structs.
+func unbounded(p *Parameter, visited map[*Struct]bool) (kind, member string) {
+	switch {
+	case p.isArray && p.arraySize == 0 && p.ArrayRange == nil:
+		return "variable-length array", ""
+	case (p.dataType == StringType || p.dataType == BlobType) && p.Range == nil:
+		return p.dataType.String(), ""
+	case p.structType != nil && !visited[p.structType]:
+		visited[p.structType] = true
+		for _, f := range p.structType.fields {
+			if kind, member := unbounded(f.(*Parameter), visited); kind != "" {
+				if member != "" {
+					return kind, f.Name() + "." + member
+				}
+				return kind, f.Name()
+			}
+		}
+	}
+	return "", ""
+}
+
+func (l *linter) checkSendWithoutAirecv() {
+	l.classFields(func(c *Class, f Field) {
+		if (f.IsClsend() || f.IsOwnsend()) && !f.IsAirecv() {
+			keyword := "clsend"
+			if !f.IsClsend() {
+				keyword = "ownsend"
+			}
+			l.report(f.Pos(), c.name+"."+f.Name(), keyword+" field is not airecv")
+		}
+	})
+}
+
+func (l *linter) checkDbWithoutRequired() {
+	l.classFields(func(c *Class, f Field) {
+		if f.IsDb() && !f.IsRequired() {
+			l.report(f.Pos(), c.name+"."+f.Name(), "db field is not required")
+		}
+	})
+}
+
+func (l *linter) checkBroadcastWithoutRam() {
+	l.classFields(func(c *Class, f Field) {
+		if f.IsBroadcast() && !f.IsRam() {
+			l.report(f.Pos(), c.name+"."+f.Name(), "broadcast field is not ram")
+		}
+	})
+}
+
+func (l *linter) checkUnusedStructs() {
+	used := make(map[*Struct]bool)
+	var use func(f Field)
+	use = func(f Field) {
+		if p, ok := f.(*Parameter); ok && p.structType != nil {
+			used[p.structType] = true
+		}
+		if _, ok := f.(*AtomicField); ok {
+			for _, arg := range f.NestedFields() {
+				use(arg)
+			}
+		}
+	}
+	for _, typ := range l.f.Classes {
+		for _, f := range typ.Fields() {
+			use(f)
+		}
+	}
+
+	for _, typ := range l.f.Classes {
+		if s, ok := typ.(*Struct); ok && !used[s] {
+			l.report(s.pos, s.name, "struct is not used")
+		}
+	}
+}
+
+func (l *linter) checkEmptyClasses() {
+	for _, typ := range l.f.Classes {
+		if c, ok := typ.(*Class); ok && len(c.InheritedFields()) == 0 {
+			l.report(c.pos, c.name, "dclass has no fields")
+		}
+	}
+}
+
+func (l *linter) checkLargeFixedSize() {
+	l.classFields(func(c *Class, f Field) {
+		if size, ok := fixedSize(f); ok && size > l.maxFixedSize {
+			l.report(f.Pos(), c.name+"."+f.Name(), fmt.Sprintf("field has a fixed size of %d bytes, "+
+				"larger than %d", size, l.maxFixedSize))
+		}
+	})
+}
+
+// fixedSize returns the number of bytes a field is packed in, or false if its size depends on
+// its value.
+func fixedSize(f Field) (int, bool) {
+	p, ok := f.(*Parameter)
+	if !ok {
+		size := 0
+		for _, nested := range f.NestedFields() {
+			n, ok := fixedSize(nested)
+			if !ok {
+				return 0, false
+			}
+			size += n
+		}
+		return size, true
+	}
+
+	count := 1
+	if p.isArray {
+		if p.arraySize == 0 {
+			return 0, false
+		}
+		count = p.arraySize
+	}
+	size := p.dataType.Size()
+	if p.structType != nil {
+		members := Field(&AtomicField{args: p.structType.fields})
+		var ok bool
+		if size, ok = fixedSize(members); !ok {
+			return 0, false
+		}
+	} else if size == 0 {
+		return 0, false
+	}
+	return count * size, true
+}
@@ -0,0 +1,197 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const caseLint = `keyword p2p;
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+struct Chat {
+	string text;
+};
+
+struct Unused {
+	uint8 x;
+};
+
+dclass Empty {
+};
+
+// lint:file-ignore large-fixed-size huge fields are fine here
+dclass Avatar {
+	setName(string(1-32) name) required broadcast ram db airecv;
+	setChat(Chat chat, uint8[] flags) clsend airecv;
+	setPath(Point[] path) ownsend;
+	uint64[200] history clsend airecv; // lint:ignore send-without-airecv not a send
+	setHp(int16 hp) broadcast db;
+	// lint:ignore clsend-unbounded,send-without-airecv bounded by the server
+	setEmote(string emote) clsend;
+	setPos : setPath;
+};
+
+dclass Hidden : Empty {
+};
+`
+
+func TestLint(t *testing.T) {
+	dcf, err := Parse(strings.NewReader(caseLint))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		opts     LintOptions
+		expected string
+	}{
+		{LintOptions{}, `12:8: warning: Unused: struct is not used (unused-struct)
+16:8: info: Empty: dclass has no fields (empty-class)
+22:2: warning: Avatar.setChat: clsend field has string chat.text without a range (clsend-unbounded)
+22:2: warning: Avatar.setChat: clsend field has variable-length array flags without a range (clsend-unbounded)
+23:2: warning: Avatar.setPath: ownsend field is not airecv (send-without-airecv)
+25:2: warning: Avatar.setHp: db field is not required (db-without-required)
+25:2: warning: Avatar.setHp: broadcast field is not ram (broadcast-without-ram)
+31:8: info: Hidden: dclass has no fields (empty-class)`},
+		{LintOptions{Rules: map[string]Severity{
+			"empty-class":         LintOff,
+			"unused-struct":       LintOff,
+			"clsend-unbounded":    LintError,
+			"send-without-airecv": LintInfo,
+		}}, `22:2: error: Avatar.setChat: clsend field has string chat.text without a range (clsend-unbounded)
+22:2: error: Avatar.setChat: clsend field has variable-length array flags without a range (clsend-unbounded)
+23:2: info: Avatar.setPath: ownsend field is not airecv (send-without-airecv)
+25:2: warning: Avatar.setHp: db field is not required (db-without-required)
+25:2: warning: Avatar.setHp: broadcast field is not ram (broadcast-without-ram)`},
+	}
+	for _, c := range cases {
+		issues, err := Lint(dcf, c.opts)
+		if err != nil {
+			t.Errorf("Lint(%v): %v", c.opts, err)
+			continue
+		}
+		var lines []string
+		for _, issue := range issues {
+			lines = append(lines, issue.String())
+		}
+		if out := strings.Join(lines, "\n"); out != c.expected {
+			t.Errorf("Lint(%v) reported:\n%s\nexpected:\n%s", c.opts, out, c.expected)
+		}
+	}
+
+	if _, err := Lint(dcf, LintOptions{Rules: map[string]Severity{"missing": LintError}}); err == nil ||
+		err.Error() != `unknown lint rule "missing"` {
+		t.Errorf("Lint with an unknown rule: error %v", err)
+	}
+}
+
+func TestLintFixedSize(t *testing.T) {
+	dcf, err := Parse(strings.NewReader(`struct Point {
+	int16 x;
+	int16 y;
+};
+
+dclass Map {
+	Point[300] points;
+	setTiles(uint8[16] tiles, Point[256] corners) ram;
+	setNames(string[4] names) ram;
+};
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		max      int
+		expected []string
+	}{
+		{0, []string{"Map.points: field has a fixed size of 1200 bytes, larger than 1024",
+			"Map.setTiles: field has a fixed size of 1040 bytes, larger than 1024"}},
+		{1100, []string{"Map.points: field has a fixed size of 1200 bytes, larger than 1100"}},
+		{2000, nil},
+	}
+	for _, c := range cases {
+		issues, err := Lint(dcf, LintOptions{MaxFixedSize: c.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for _, issue := range issues {
+			if issue.Rule == "large-fixed-size" {
+				found = append(found, issue.Path+": "+issue.Msg)
+			}
+		}
+		if strings.Join(found, "\n") != strings.Join(c.expected, "\n") {
+			t.Errorf("MaxFixedSize %d reported:\n%s\nexpected:\n%s", c.max, strings.Join(found, "\n"),
+				strings.Join(c.expected, "\n"))
+		}
+	}
+}
+
+func TestLintDirectives(t *testing.T) {
+	src := `// lint:ignore
+// lint:unused empty-class
+// lint:ignore missing-rule
+
+// The empty class.
+// lint:ignore empty-class
+dclass Empty {
+};
+`
+	dcf, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `semantic warning(line: 1): lint directive without rules: // lint:ignore
+semantic warning(line: 2): unknown lint directive lint:unused
+semantic warning(line: 3): unknown lint rule "missing-rule" in lint:ignore`
+	var warnings []string
+	for _, w := range dcf.Warnings {
+		warnings = append(warnings, w.Error())
+	}
+	if out := strings.Join(warnings, "\n"); out != expected {
+		t.Errorf("file has warnings:\n%s\nexpected:\n%s", out, expected)
+	}
+	if doc := dcf.ClassByName["Empty"].(*Class).Doc(); doc != "The empty class." {
+		t.Errorf("class has doc %q", doc)
+	}
+	if issues, _ := Lint(dcf, LintOptions{}); len(issues) != 0 {
+		t.Errorf("suppressed issues were reported: %v", issues)
+	}
+
+	// Suppressions are kept by the cache
+	var cache bytes.Buffer
+	if err := dcf.WriteCache(&cache); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok, err := LoadCache(&cache, []byte(src))
+	if err != nil || !ok {
+		t.Fatalf("expected cache to be used, found %v, %v", ok, err)
+	}
+	if issues, _ := Lint(cached, LintOptions{}); len(issues) != 0 {
+		t.Errorf("suppressed issues were reported for the cached file: %v", issues)
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	b, err := json.Marshal(LintOptions{Rules: map[string]Severity{"empty-class": LintError}})
+	if err != nil || string(b) != `{"rules":{"empty-class":"error"}}` {
+		t.Errorf("marshaled options as %s, %v", b, err)
+	}
+
+	var opts LintOptions
+	if err := json.Unmarshal([]byte(`{"rules":{"unused-struct":"off"},"maxFixedSize":64}`), &opts); err != nil ||
+		opts.Rules["unused-struct"] != LintOff || opts.MaxFixedSize != 64 {
+		t.Errorf("unmarshaled options as %+v, %v", opts, err)
+	}
+	if err := json.Unmarshal([]byte(`{"rules":{"unused-struct":"fatal"}}`), &opts); err == nil {
+		t.Errorf("unmarshaled an unknown severity")
+	}
+}
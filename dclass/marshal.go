@@ -0,0 +1,554 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Marshal and Unmarshal map Go values onto the packed values of dclass fields using reflection.
+//
+// A Parameter is mapped onto a Go value of a compatible kind: numbers onto integers, floats, or
+// bools; enums onto integers, or strings holding the name of the enum value; strings and blobs
+// onto strings or byte slices; arrays onto slices, or Go arrays of the same length for fixed-size
+// arrays; and structs onto Go structs.  Atomic fields, molecular fields, and dclass structs are
+// mapped onto Go structs, matching each argument, component, or member with the Go field tagged
+// `dc:"name"`, or else the exported Go field with the same name ignoring case.  Unnamed arguments
+// are matched with the exported Go field at the same position.  Go fields tagged `dc:"-"` are
+// ignored.  Pointers are followed when marshaling and allocated when unmarshaling.
+//
+// The mapping for each pair of field and Go type is checked once and cached by the field's File.
+
+// Marshal returns the packed value of the field for the Go value v.  Values which overflow the
+// parameter's type, or which are outside of its range, are reported as errors.
+func Marshal(field Field, v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, Error("cannot marshal nil into field '" + field.Name() + "'")
+	}
+
+	c, err := codecFor(field, rv.Type(), false)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := c.encode(rv, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal unpacks the packed value of the field in data and stores the result in the Go value
+// pointed to by v.  The data must contain exactly one value of the field.
+func Unmarshal(field Field, data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return Error("Unmarshal of field '" + field.Name() + "' requires a non-nil pointer")
+	}
+
+	c, err := codecFor(field, rv.Type().Elem(), false)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(data)
+	if err := c.decode(buf, rv.Elem()); err != nil {
+		return err
+	}
+	if buf.Len() > 0 {
+		return Error("unexpected data after value of field '" + field.Name() + "'")
+	}
+	return nil
+}
+
+// a codec packs and unpacks values of a field for a Go type.
+type codec struct {
+	encode func(v reflect.Value, buf *bytes.Buffer) error
+	decode func(data *bytes.Buffer, v reflect.Value) error
+}
+
+// a codecKey identifies the codec of a field for a Go type.
+type codecKey struct {
+	field Field
+	typ   reflect.Type
+	elem  bool // the codec packs a single element of an array parameter
+}
+
+// codecFor returns the cached codec of the field for the Go type, building and checking the
+// codec if the type has not been used with the field before.
+func codecFor(field Field, typ reflect.Type, elem bool) (*codec, error) {
+	cache := &field.File().codecs
+	key := codecKey{field, typ, elem}
+	if c, ok := cache.Load(key); ok {
+		return c.(*codec), nil
+	}
+
+	b := codecBuilder{codecs: make(map[codecKey]*codec)}
+	c, err := b.build(key)
+	if err != nil {
+		return nil, err
+	}
+	for key, c := range b.codecs {
+		cache.LoadOrStore(key, c)
+	}
+	return c, nil
+}
+
+// a codecBuilder builds the codecs of a field and its nested fields.  Codecs are registered before
+// they are built, so that recursive types refer to the codec which is being built.
+type codecBuilder struct {
+	codecs map[codecKey]*codec
+}
+
+func (b *codecBuilder) build(key codecKey) (*codec, error) {
+	if c, ok := b.codecs[key]; ok {
+		return c, nil
+	}
+	c := new(codec)
+	b.codecs[key] = c
+
+	var err error
+	switch {
+	case key.typ.Kind() == reflect.Ptr:
+		err = b.buildPointer(c, key)
+	case key.elem:
+		err = b.buildElement(c, key.field.(*Parameter), key.typ)
+	default:
+		if p, ok := key.field.(*Parameter); ok {
+			err = b.buildParameter(c, p, key.typ)
+		} else {
+			err = b.buildFields(c, key.field.Name(), key.field.NestedFields(), key.typ)
+		}
+	}
+	if err != nil {
+		delete(b.codecs, key)
+		return nil, err
+	}
+	return c, nil
+}
+
+// buildPointer builds a codec which follows a pointer to the codec of the pointed to type.
+func (b *codecBuilder) buildPointer(c *codec, key codecKey) error {
+	elem, err := b.build(codecKey{key.field, key.typ.Elem(), key.elem})
+	if err != nil {
+		return err
+	}
+
+	c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+		if v.IsNil() {
+			return Error("cannot marshal nil pointer into field '" + key.field.Name() + "'")
+		}
+		return elem.encode(v.Elem(), buf)
+	}
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.New(key.typ.Elem()))
+		}
+		return elem.decode(data, v.Elem())
+	}
+	return nil
+}
+
+// buildFields builds a codec which maps the nested fields of an atomic field, molecular field, or
+// dclass struct onto the fields of a Go struct.
+func (b *codecBuilder) buildFields(c *codec, name string, fields []Field, typ reflect.Type) error {
+	if typ.Kind() != reflect.Struct {
+		return incompatibleError(typ, name, "a Go struct")
+	}
+
+	var exported []int
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.PkgPath == "" && f.Tag.Get("dc") != "-" {
+			exported = append(exported, i)
+		}
+	}
+
+	type member struct {
+		index int
+		codec *codec
+	}
+	members := make([]member, len(fields))
+	for i, field := range fields {
+		index := -1
+		if field.Name() == "" {
+			if i < len(exported) {
+				index = exported[i]
+			}
+		} else {
+			index = structFieldIndex(typ, exported, field.Name())
+		}
+		if index < 0 {
+			return Error("Go type " + typ.String() + " has no field for '" + nestedName(name, field, i) + "'")
+		}
+
+		nested, err := b.build(codecKey{field, typ.Field(index).Type, false})
+		if err != nil {
+			return err
+		}
+		members[i] = member{index, nested}
+	}
+
+	c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+		for _, m := range members {
+			if err := m.codec.encode(v.Field(m.index), buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		for _, m := range members {
+			if err := m.codec.decode(data, v.Field(m.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// structFieldIndex returns the index of the exported Go field which maps onto the dclass field
+// with the given name, or -1 if there is none.  A matching tag takes precedence over a matching name.
+func structFieldIndex(typ reflect.Type, exported []int, name string) int {
+	for _, i := range exported {
+		if typ.Field(i).Tag.Get("dc") == name {
+			return i
+		}
+	}
+	for _, i := range exported {
+		if f := typ.Field(i); f.Tag.Get("dc") == "" && strings.EqualFold(f.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nestedName returns a name for the i-th nested field of the named field in error messages.
+func nestedName(name string, field Field, i int) string {
+	if field.Name() == "" {
+		return name + " argument " + strconv.Itoa(i+1)
+	}
+	return name + "." + field.Name()
+}
+
+// buildParameter builds a codec for a parameter, which may be an array of elements.
+func (b *codecBuilder) buildParameter(c *codec, p *Parameter, typ reflect.Type) error {
+	if !p.isArray {
+		return b.buildElement(c, p, typ)
+	}
+
+	switch {
+	case typ.Kind() == reflect.Array && p.arraySize > 0:
+		if typ.Len() != p.arraySize {
+			return incompatibleError(typ, p.name, "an array of "+strconv.Itoa(p.arraySize)+" elements")
+		}
+	case typ.Kind() != reflect.Slice:
+		return incompatibleError(typ, p.name, "a Go slice")
+	}
+	elem, err := b.build(codecKey{p, typ.Elem(), true})
+	if err != nil {
+		return err
+	}
+
+	c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+		count := v.Len()
+		if p.arraySize > 0 {
+			if count != p.arraySize {
+				return Error("array value of parameter '" + p.name + "' has " + strconv.Itoa(count) +
+					" elements, expected " + strconv.Itoa(p.arraySize))
+			}
+			for i := 0; i < count; i++ {
+				if err := elem.encode(v.Index(i), buf); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if !inRange(p.ArrayRange, big.NewRat(int64(count), 1)) {
+			return Error("array value has " + strconv.Itoa(count) + " elements, outside of the range of parameter '" +
+				p.name + "'")
+		}
+		var elems bytes.Buffer
+		for i := 0; i < count; i++ {
+			if err := elem.encode(v.Index(i), &elems); err != nil {
+				return err
+			}
+		}
+		if err := packLength(elems.Len(), buf); err != nil {
+			return err
+		}
+		buf.Write(elems.Bytes())
+		return nil
+	}
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		if p.arraySize > 0 {
+			if v.Kind() == reflect.Slice {
+				v.Set(reflect.MakeSlice(typ, p.arraySize, p.arraySize))
+			}
+			for i := 0; i < p.arraySize; i++ {
+				if err := elem.decode(data, v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		elems := bytes.NewBuffer(data.Next(n))
+		v.Set(reflect.MakeSlice(typ, 0, 0))
+		for elems.Len() > 0 {
+			e := reflect.New(typ.Elem()).Elem()
+			if err := elem.decode(elems, e); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, e))
+		}
+		return nil
+	}
+	return nil
+}
+
+// buildElement builds a codec for a single element of a parameter.
+func (b *codecBuilder) buildElement(c *codec, p *Parameter, typ reflect.Type) error {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return Error("struct " + p.typeName + " of parameter '" + p.name + "' is not defined")
+		}
+		return b.buildFields(c, p.name, p.structType.fields, typ)
+	case StringType, BlobType:
+		return buildString(c, p, typ)
+	}
+
+	switch {
+	case p.enum != nil && typ.Kind() == reflect.String:
+		return buildEnumName(c, p)
+	case isNumericKind(typ.Kind()):
+		return buildNumber(c, p, typ)
+	}
+	return incompatibleError(typ, p.name, "a Go number")
+}
+
+// buildString builds a codec for a string or blob parameter and a Go string or byte slice.
+func buildString(c *codec, p *Parameter, typ reflect.Type) error {
+	isBytes := typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+	if typ.Kind() != reflect.String && !isBytes {
+		return incompatibleError(typ, p.name, "a Go string or byte slice")
+	}
+
+	c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+		if isBytes {
+			return packString(p, string(v.Bytes()), buf)
+		}
+		return packString(p, v.String(), buf)
+	}
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		b := data.Next(n)
+		if isBytes {
+			v.SetBytes(append([]byte(nil), b...))
+		} else {
+			v.SetString(string(b))
+		}
+		return nil
+	}
+	return nil
+}
+
+// buildEnumName builds a codec for an enum parameter and a Go string holding the name of the value.
+func buildEnumName(c *codec, p *Parameter) error {
+	c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+		n, ok := p.enum.ValueByName(v.String())
+		if !ok {
+			return Error("'" + v.String() + "' of parameter '" + p.name + "' is not a member of enum " +
+				p.enum.name)
+		}
+		return packNumber(p, new(big.Rat).SetInt(n), buf)
+	}
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		n, err := unpackInt(p.dataType, data)
+		if err != nil {
+			return err
+		}
+		name, ok := p.enum.NameOf(n)
+		if !ok {
+			return Error("value " + n.String() + " of parameter '" + p.name + "' is not a member of enum " +
+				p.enum.name)
+		}
+		v.SetString(name)
+		return nil
+	}
+	return nil
+}
+
+// buildNumber builds a codec for a numeric parameter and a Go integer, float, or bool.
+func buildNumber(c *codec, p *Parameter, typ reflect.Type) error {
+	if !p.dataType.IsInteger() && p.dataType != CharType && p.dataType != FloatType {
+		return incompatibleError(typ, p.name, "a Go "+p.dataType.String())
+	}
+
+	if p.dataType != FloatType && len(p.Transform) == 0 && p.Range == nil && p.enum == nil {
+		// the value only needs to fit the parameter's type, so avoid packing through a big.Rat
+		c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+			return packGoInt(p, v, buf)
+		}
+	} else {
+		c.encode = func(v reflect.Value, buf *bytes.Buffer) error {
+			return packGoNumber(p, v, buf)
+		}
+	}
+
+	c.decode = func(data *bytes.Buffer, v reflect.Value) error {
+		if p.dataType == FloatType {
+			if data.Len() < 8 {
+				return Error("unexpected end of data reading float64")
+			}
+			f := math.Float64frombits(binary.LittleEndian.Uint64(data.Next(8)))
+			return setGoFloat(p, v, p.Transform.apply(f))
+		}
+
+		n, err := unpackInt(p.dataType, data)
+		if err != nil {
+			return err
+		}
+		if len(p.Transform) > 0 {
+			f, _ := new(big.Float).SetInt(n).Float64()
+			return setGoFloat(p, v, p.Transform.apply(f))
+		}
+		return setGoInt(p, v, n)
+	}
+	return nil
+}
+
+// packGoInt packs the Go integer, float, or bool v into buf as a value of the parameter's
+// integer DataType.
+func packGoInt(p *Parameter, v reflect.Value, buf *bytes.Buffer) error {
+	var u uint64
+	var ok bool
+	size := uint(p.dataType.Size() * 8)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if p.dataType.IsSigned() {
+			ok = size == 64 || (-1<<(size-1) <= n && n < 1<<(size-1))
+		} else {
+			ok = n >= 0 && (size == 64 || n < 1<<size)
+		}
+		u = uint64(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u = v.Uint()
+		if p.dataType.IsSigned() {
+			ok = u < 1<<(size-1)
+		} else {
+			ok = size == 64 || u < 1<<size
+		}
+	default:
+		return packGoNumber(p, v, buf)
+	}
+
+	if !ok {
+		return Error("value " + goNumber(v).RatString() + " of parameter '" + p.name + "' overflows " +
+			p.dataType.String())
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], u)
+	buf.Write(b[:p.dataType.Size()])
+	return nil
+}
+
+// packGoNumber packs the Go integer, float, or bool v into buf as a value of the parameter.
+// Infinities and NaN are packed by packNonFinite.
+func packGoNumber(p *Parameter, v reflect.Value, buf *bytes.Buffer) error {
+	if k := v.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+		if f := v.Float(); math.IsInf(f, 0) || math.IsNaN(f) {
+			return packNonFinite(p, f, buf)
+		}
+	}
+	return packNumber(p, goNumber(v), buf)
+}
+
+// goNumber returns the value of the Go integer, finite float, or bool v.
+func goNumber(v reflect.Value) *big.Rat {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint()))
+	case reflect.Bool:
+		if v.Bool() {
+			return big.NewRat(1, 1)
+		}
+		return new(big.Rat)
+	}
+
+	return new(big.Rat).SetFloat64(v.Float())
+}
+
+// setGoInt stores the unpacked integer n of the parameter in the Go integer, float, or bool v.
+func setGoInt(p *Parameter, v reflect.Value, n *big.Int) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.IsInt64() && !v.OverflowInt(n.Int64()) {
+			v.SetInt(n.Int64())
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n.Sign() >= 0 && n.IsUint64() && !v.OverflowUint(n.Uint64()) {
+			v.SetUint(n.Uint64())
+			return nil
+		}
+	case reflect.Bool:
+		v.SetBool(n.Sign() != 0)
+		return nil
+	default:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		v.SetFloat(f)
+		return nil
+	}
+	return Error("value " + n.String() + " of parameter '" + p.name + "' overflows Go type " + v.Type().String())
+}
+
+// setGoFloat stores the unpacked (transformed) value f of the parameter in the Go integer, float,
+// or bool v.  Integers and bools can only store whole numbers.
+func setGoFloat(p *Parameter, v reflect.Value, f float64) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if !v.OverflowFloat(f) {
+			v.SetFloat(f)
+			return nil
+		}
+	default:
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			num, _ := new(big.Float).SetFloat64(f).Int(nil)
+			return setGoInt(p, v, num)
+		}
+	}
+	return Error("value " + strconv.FormatFloat(f, 'g', -1, 64) + " of parameter '" + p.name + "' cannot be stored in Go type " +
+		v.Type().String())
+}
+
+// isNumericKind returns whether values of the Go kind can be packed as numbers.
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// incompatibleError creates an error for a Go type which cannot be mapped onto a field.
+func incompatibleError(typ reflect.Type, name, expected string) Error {
+	return Error("cannot map Go type " + typ.String() + " onto '" + name + "', expected " + expected)
+}
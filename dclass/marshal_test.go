@@ -0,0 +1,197 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const caseMarshal = `
+enum Mode : uint8 {
+	Idle = 1,
+	Walk,
+};
+struct Point {
+	int16 / 10 x;
+	int16(-100-100) y;
+};
+struct Node {
+	string name;
+	Node[] children;
+};
+dclass Avatar {
+	setPos(Point pos, Mode mode, uint8 level, char[2] tag);
+	setPath(Point[0-4] path, blob data, string);
+	setTree(Node root);
+	setLevel(uint8(1-99) level);
+	setState : setPos, setLevel;
+};
+`
+
+type marshalPoint struct {
+	X float64
+	Y int32
+}
+
+type marshalPos struct {
+	Pos   *marshalPoint
+	Mode  string
+	Level int `dc:"level"`
+	Tag   [2]byte
+	Extra bool `dc:"-"`
+}
+
+type marshalPath struct {
+	Path  []marshalPoint
+	Bytes []byte `dc:"data"`
+	Label string
+}
+
+type marshalNode struct {
+	Name     string
+	Children []marshalNode
+}
+
+type marshalState struct {
+	SetPos   marshalPos
+	SetLevel struct{ Level uint16 }
+}
+
+func TestMarshal(t *testing.T) {
+	dcf := mustParse(t, caseMarshal)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+
+	tests := []struct {
+		field    string
+		value    interface{}
+		expected string
+	}{
+		{"setPos", marshalPos{&marshalPoint{-4.5, 7}, "Walk", 3, [2]byte{'a', 'b'}, true},
+			`({-4.5, 7}, Walk, 3, {'a', 'b'})`},
+		{"setPath", &marshalPath{[]marshalPoint{{1, 2}, {3, 4}}, []byte("xy"), "label"},
+			`({{1, 2}, {3, 4}}, "xy", "label")`},
+		{"setTree", struct{ Root marshalNode }{marshalNode{"a", []marshalNode{{"b", nil}, {"c", []marshalNode{{"d", nil}}}}}},
+			`({"a", {{"b", {}}, {"c", {{"d", {}}}}}})`},
+		{"setState", marshalState{marshalPos{&marshalPoint{0.5, -1}, "Idle", 255, [2]byte{'x', 'y'}, false}, struct{ Level uint16 }{99}},
+			`(({0.5, -1}, Idle, 255, {'x', 'y'}), (99))`},
+	}
+
+	for _, test := range tests {
+		field := avatar.FieldByName(test.field)
+		data, err := Marshal(field, test.value)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %s: %s", test.field, err)
+			continue
+		}
+
+		expected, err := field.ParseString(test.expected)
+		if err != nil {
+			t.Fatalf("unexpected error parsing value of %s: %s", test.field, err)
+		}
+		if !bytes.Equal(data, expected.Bytes()) {
+			t.Errorf("marshaled %s as %s, expected %s", test.field, field.FormatData(*bytes.NewBuffer(data), false),
+				test.expected)
+		}
+
+		// unmarshal into a new value of the same type and compare
+		typ := reflect.TypeOf(test.value)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		out := reflect.New(typ)
+		if err := Unmarshal(field, data, out.Interface()); err != nil {
+			t.Errorf("unexpected error unmarshaling %s: %s", test.field, err)
+			continue
+		}
+		if roundTrip, err := Marshal(field, out.Interface()); err != nil || !bytes.Equal(roundTrip, data) {
+			t.Errorf("unmarshaled %s as %+v, which does not marshal to the same data", test.field, out.Elem())
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	dcf := mustParse(t, caseMarshal)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+
+	tests := []struct {
+		field    string
+		value    interface{}
+		expected string
+	}{
+		{"setLevel", struct{ Level int32 }{300}, "value 300 of parameter 'level' overflows uint8"},
+		{"setLevel", struct{ Level int32 }{0}, "value 0 is outside of the range of parameter 'level'"},
+		{"setLevel", struct{ Level string }{"1"}, "cannot map Go type string onto 'level', expected a Go number"},
+		{"setLevel", struct{ Other int }{1}, "has no field for 'setLevel.level'"},
+		{"setLevel", 5, "cannot map Go type int onto 'setLevel', expected a Go struct"},
+		{"setPos", marshalPos{nil, "Idle", 1, [2]byte{}, false}, "cannot marshal nil pointer into field 'pos'"},
+		{"setPos", marshalPos{&marshalPoint{}, "Run", 1, [2]byte{}, false}, "'Run' of parameter 'mode' is not a member of enum Mode"},
+		{"setPath", marshalPath{make([]marshalPoint, 5), nil, ""}, "array value has 5 elements, outside of the range of parameter 'path'"},
+	}
+
+	for _, test := range tests {
+		_, err := Marshal(avatar.FieldByName(test.field), test.value)
+		if err == nil {
+			t.Errorf("expected error marshaling %s: %+v", test.field, test.value)
+		} else if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("unexpected error marshaling %s: %s, expected %s", test.field, err, test.expected)
+		}
+	}
+
+	// values are checked when unmarshaling into smaller Go types
+	setPos := avatar.FieldByName("setPos")
+	data, err := Marshal(setPos, marshalPos{&marshalPoint{}, "Idle", 200, [2]byte{}, false})
+	if err != nil {
+		t.Fatalf("unexpected error marshaling setPos: %s", err)
+	}
+	var small struct {
+		Pos   marshalPoint
+		Mode  uint8
+		Level int8
+		Tag   []byte
+	}
+	if err := Unmarshal(setPos, data, &small); err == nil || !strings.Contains(err.Error(), "overflows Go type int8") {
+		t.Errorf("expected overflow error unmarshaling into int8, found %v", err)
+	}
+	if err := Unmarshal(setPos, append(data, 0), &marshalPos{}); err == nil {
+		t.Errorf("expected error unmarshaling data with trailing bytes")
+	}
+}
+
+func TestMarshalNonFinite(t *testing.T) {
+	dcf := mustParse(t, `dclass A {
+	float64 f;
+	float64(0-1) ranged;
+	int16 / 10 scaled;
+	int32 i;
+};
+`)
+	a := dcf.ClassByName["A"].(*Class)
+
+	// infinities and NaN are kept by float64 parameters
+	for _, f := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		data, err := Marshal(a.FieldByName("f"), f)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %v: %s", f, err)
+			continue
+		}
+		if bits := binary.LittleEndian.Uint64(data); bits != math.Float64bits(f) {
+			t.Errorf("marshaled %v as %#x", f, bits)
+		}
+		var out float32
+		if err := Unmarshal(a.FieldByName("f"), data, &out); err != nil ||
+			math.IsNaN(f) != math.IsNaN(float64(out)) || !math.IsNaN(f) && float64(out) != f {
+			t.Errorf("unmarshaled %v as %v, %v", f, out, err)
+		}
+	}
+
+	for _, name := range []string{"ranged", "scaled", "i"} {
+		for _, f := range []float64{math.Inf(1), math.NaN()} {
+			if data, err := Marshal(a.FieldByName(name), f); err == nil || !strings.Contains(err.Error(), "is not a finite number") {
+				t.Errorf("marshaled %v into %s as %v, %v", f, name, data, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// The dclass wire format packs all values in little-endian byte order.  Strings, blobs,
+// and variable-length arrays are prefixed with their length in bytes as a uint16.
+// Fixed-size arrays and structs are packed as the sequence of their elements.
+
+// packNumber packs the unpacked (transformed) value v into buf as a value of the parameter's
+// DataType.  The value is untransformed, then checked against the parameter's type, range,
+// and enum before being packed.
+func packNumber(p *Parameter, v *big.Rat, buf *bytes.Buffer) error {
+	if p.dataType == FloatType {
+		f, _ := v.Float64()
+		f = p.Transform.invert(f)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return Error("value " + v.RatString() + " of parameter '" + p.name + "' overflows float64")
+		}
+		if !inRange(p.Range, new(big.Rat).SetFloat64(f)) {
+			return Error(fmt.Sprintf("value %v is outside of the range of parameter '%s'", f, p.name))
+		}
+		binary.Write(buf, binary.LittleEndian, f)
+		return nil
+	}
+
+	if !p.dataType.IsInteger() && p.dataType != CharType {
+		return Error("cannot pack a number into parameter '" + p.name + "' of type " + p.TypeName())
+	}
+
+	var n *big.Int
+	if len(p.Transform) > 0 {
+		f, _ := v.Float64()
+		f = math.Floor(p.Transform.invert(f) + 0.5)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return Error("value " + v.RatString() + " of parameter '" + p.name +
+				"' cannot be transformed to an integer")
+		}
+		n, _ = big.NewFloat(f).Int(nil)
+	} else if v.IsInt() {
+		n = new(big.Int).Set(v.Num())
+	} else {
+		return Error("value " + v.RatString() + " of parameter '" + p.name + "' must be an integer")
+	}
+
+	if !fitsType(p.dataType, n) {
+		return Error("value " + n.String() + " of parameter '" + p.name + "' overflows " + p.dataType.String())
+	}
+	if !inRange(p.Range, new(big.Rat).SetInt(n)) {
+		return Error("value " + n.String() + " is outside of the range of parameter '" + p.name + "'")
+	}
+	if p.enum != nil {
+		if _, ok := p.enum.NameOf(n); !ok {
+			return Error("value " + n.String() + " of parameter '" + p.name +
+				"' is not a member of enum " + p.enum.name)
+		}
+	}
+
+	packInt(p.dataType, n, buf)
+	return nil
+}
+
+// packNonFinite packs an infinity or NaN into buf.  It is packed as it is into a float64
+// parameter without a transform or a range, and cannot be packed into other parameters.
+func packNonFinite(p *Parameter, f float64, buf *bytes.Buffer) error {
+	if p.dataType != FloatType || len(p.Transform) > 0 || p.Range != nil {
+		return Error("value " + strconv.FormatFloat(f, 'g', -1, 64) + " of parameter '" + p.name +
+			"' is not a finite number")
+	}
+	binary.Write(buf, binary.LittleEndian, f)
+	return nil
+}
+
+// packInt packs the integer n, which must fit in DataType typ, into buf.
+func packInt(typ DataType, n *big.Int, buf *bytes.Buffer) {
+	var u uint64
+	if n.Sign() < 0 {
+		u = uint64(n.Int64())
+	} else {
+		u = n.Uint64()
+	}
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], u)
+	buf.Write(b[:typ.Size()])
+}
+
+// packLength packs the length of a string, blob, or variable-length array into buf.
+func packLength(n int, buf *bytes.Buffer) error {
+	if n > math.MaxUint16 {
+		return Error(fmt.Sprintf("length %d exceeds the maximum length of %d bytes", n, math.MaxUint16))
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(n))
+	return nil
+}
+
+// packString packs a string or blob value of the parameter into buf.
+func packString(p *Parameter, s string, buf *bytes.Buffer) error {
+	if !inRange(p.Range, new(big.Rat).SetInt64(int64(len(s)))) {
+		return Error(fmt.Sprintf("length %d is outside of the range of parameter '%s'", len(s), p.name))
+	}
+	if err := packLength(len(s), buf); err != nil {
+		return err
+	}
+	buf.WriteString(s)
+	return nil
+}
+
+// packZero packs the null value of the parameter into buf.  For numeric types this is zero,
+// or the minimum of the parameter's range if zero is out of range.  Enums use their first value.
+func packZero(p *Parameter, buf *bytes.Buffer) {
+	if p.isArray {
+		if p.arraySize == 0 {
+			packLength(0, buf)
+			return
+		}
+		for i := 0; i < p.arraySize; i++ {
+			packZeroElement(p, buf)
+		}
+		return
+	}
+	packZeroElement(p, buf)
+}
+
+// packZeroElement packs the null value of a single element of the parameter into buf.
+func packZeroElement(p *Parameter, buf *bytes.Buffer) {
+	switch {
+	case p.dataType == StructType:
+		if p.structType != nil {
+			for _, f := range p.structType.fields {
+				val := f.DefaultValue()
+				buf.Write(val.Bytes())
+			}
+		}
+	case p.dataType == StringType, p.dataType == BlobType:
+		packLength(0, buf)
+	case p.dataType == FloatType:
+		binary.Write(buf, binary.LittleEndian, float64(0))
+	case p.enum != nil && len(p.enum.values) > 0:
+		packInt(p.dataType, p.enum.values[0].Value, buf)
+	default:
+		n := new(big.Int)
+		if min, _, ok := rangeBounds(p.Range); ok && min.Sign() > 0 {
+			n = min.Num()
+		}
+		packInt(p.dataType, n, buf)
+	}
+}
+
+// unpackInt reads an integer of DataType typ from data.
+func unpackInt(typ DataType, data *bytes.Buffer) (*big.Int, error) {
+	size := typ.Size()
+	if data.Len() < size {
+		return nil, Error("unexpected end of data reading " + typ.String())
+	}
+
+	var b [8]byte
+	copy(b[:], data.Next(size))
+	u := binary.LittleEndian.Uint64(b[:])
+	if typ.IsSigned() {
+		shift := uint(64 - 8*size)
+		return big.NewInt(int64(u<<shift) >> shift), nil
+	}
+	return new(big.Int).SetUint64(u), nil
+}
+
+// unpackLength reads the uint16 length prefix of a string, blob, or variable-length array from
+// data and checks that there is enough data remaining.
+func unpackLength(data *bytes.Buffer) (int, error) {
+	if data.Len() < 2 {
+		return 0, Error("unexpected end of data reading length")
+	}
+	n := int(binary.LittleEndian.Uint16(data.Next(2)))
+	if data.Len() < n {
+		return 0, Error(fmt.Sprintf("length %d exceeds remaining data", n))
+	}
+	return n, nil
+}
+
+// formatData formats the packed data of any field.  If the data is invalid, the formatted
+// string ends with a description of the error in angle brackets.
+func formatData(f Field, data bytes.Buffer, showFieldNames bool) string {
+	var out bytes.Buffer
+	if err := formatField(f, &data, &out, showFieldNames); err != nil {
+		out.WriteString("<" + err.Error() + ">")
+	}
+	return out.String()
+}
+
+// formatField reads a packed value of the field from data and writes it to out.
+// Atomic fields are written as a list of arguments in parens `(1, 2)`, and molecular fields
+// as a list of their components `((1, 2), 3)`.
+func formatField(f Field, data *bytes.Buffer, out *bytes.Buffer, showFieldNames bool) error {
+	if p, ok := f.(*Parameter); ok {
+		return formatParameter(p, data, out, showFieldNames)
+	}
+
+	out.WriteByte('(')
+	for i, nested := range f.NestedFields() {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		if _, isAtomic := f.(*AtomicField); isAtomic && showFieldNames && nested.Name() != "" {
+			out.WriteString(nested.Name() + " = ")
+		}
+		if err := formatField(nested, data, out, showFieldNames); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(')')
+	return nil
+}
+
+// formatParameter reads a packed value of the parameter from data and writes it to out
+// in a human readable format which can be read by ParseString.
+func formatParameter(p *Parameter, data *bytes.Buffer, out *bytes.Buffer, showFieldNames bool) error {
+	if !p.isArray {
+		return formatElement(p, data, out, showFieldNames)
+	}
+
+	elems := data
+	if p.arraySize == 0 {
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		elems = bytes.NewBuffer(data.Next(n))
+	}
+
+	out.WriteByte('{')
+	for i := 0; (p.arraySize == 0 && elems.Len() > 0) || i < p.arraySize; i++ {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		if err := formatElement(p, elems, out, showFieldNames); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// formatElement reads a single packed element of the parameter from data and writes it to out.
+func formatElement(p *Parameter, data *bytes.Buffer, out *bytes.Buffer, showFieldNames bool) error {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return Error("struct " + p.typeName + " is not defined")
+		}
+		out.WriteByte('{')
+		for i, f := range p.structType.fields {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			if showFieldNames {
+				out.WriteString(f.Name() + " = ")
+			}
+			if err := formatParameter(f.(*Parameter), data, out, showFieldNames); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case StringType, BlobType:
+		n, err := unpackLength(data)
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.Quote(string(data.Next(n))))
+	case CharType:
+		if data.Len() < 1 {
+			return Error("unexpected end of data reading char")
+		}
+		c, _ := data.ReadByte()
+		if c < 0x80 {
+			out.WriteString(strconv.QuoteRuneToASCII(rune(c)))
+		} else {
+			fmt.Fprintf(out, `'\x%02x'`, c)
+		}
+	case FloatType:
+		if data.Len() < 8 {
+			return Error("unexpected end of data reading float64")
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(data.Next(8)))
+		out.WriteString(formatFloat(p.Transform.apply(f)))
+	default:
+		if !p.dataType.IsInteger() {
+			return Error("cannot format parameter '" + p.name + "' of type " + p.TypeName())
+		}
+		n, err := unpackInt(p.dataType, data)
+		if err != nil {
+			return err
+		}
+		if p.enum != nil {
+			if name, ok := p.enum.NameOf(n); ok {
+				out.WriteString(name)
+				return nil
+			}
+		}
+		if len(p.Transform) > 0 {
+			f, _ := new(big.Float).SetInt(n).Float64()
+			out.WriteString(formatFloat(p.Transform.apply(f)))
+		} else {
+			out.WriteString(n.String())
+		}
+	}
+	return nil
+}
@@ -2,41 +2,159 @@ package dclass
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
-	"os"
-	/*	"runtime"
-		"strconv"
-		"strings"
-		"unicode"
-	*/)
+	"strings"
+)
+
+// A ShadowMode selects how a field which shadows an inherited field with a different
+// signature is reported.
+type ShadowMode int
+
+const (
+	ShadowWarn   ShadowMode = iota // report a warning in File.Warnings
+	ShadowIgnore                   // allow shadowing without a diagnostic
+	ShadowError                    // report an error
+)
+
+// ParseOptions configures how a dclass File is parsed and checked.
+// The zero value is the configuration used by Parse.
+type ParseOptions struct {
+	Shadowing ShadowMode // how fields shadowing an inherited field are reported
+
+	// Limits on the source, for parsing untrusted files.  A limit of 0 is no limit.  Parsing
+	// stops at the first limit which is exceeded, with a "limit error", and the File is not
+	// checked.
+	MaxInputSize        int // the size of the source in bytes
+	MaxTokens           int // the number of tokens, including comments
+	MaxDepth            int // the nesting of braces, parentheses, and brackets
+	MaxIdentifierLength int // the length of identifiers in bytes
+	MaxClasses          int // the number of classes and structs
+	MaxFields           int // the number of fields of classes and structs
+	MaxErrors           int // the number of errors, after which parsing stops
+}
 
 // Parse returns a pointer to a dclass File created by parsing the argument io.Reader.
 // If one or more errors are encountered, a nil value is returned.
 func Parse(r io.Reader) (dcf *File, err error) {
-	// Load data from file
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(r)
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseWithOptions parses a dclass File like Parse, configured by opts.
+func ParseWithOptions(r io.Reader, opts ParseOptions) (dcf *File, err error) {
+	return ParseContext(context.Background(), r, opts)
+}
+
+// ParseContext parses a dclass File like ParseWithOptions, but stops if ctx is done, returning
+// the context's error.
+func ParseContext(ctx context.Context, r io.Reader, opts ParseOptions) (dcf *File, err error) {
+	dcf, errs := ParsePartialContext(ctx, r, opts)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return dcf, nil
+}
+
+// ParsePartial parses a dclass File like ParseWithOptions, but returns the File even if errors
+// are encountered, for tools such as editors which work with incomplete files.  After an error
+// the parser skips to the end of the statement or block, so the File holds every declaration
+// parsed before the first lex error, except those which could not be recovered.  Such a File
+// may refer to undefined structs and classes, and should only be inspected.
+func ParsePartial(r io.Reader, opts ParseOptions) (dcf *File, errs ErrorList) {
+	return ParsePartialContext(context.Background(), r, opts)
+}
+
+// ParsePartialContext parses a dclass File like ParsePartial, but stops if ctx is done, adding
+// the context's error to the returned errors.
+//
+// The source is read from r as it is parsed, and is not kept in memory.  An error reading r,
+// other than io.EOF, is returned as a "read error" at the position where reading stopped.
+func ParsePartialContext(ctx context.Context, r io.Reader, opts ParseOptions) (dcf *File, errs ErrorList) {
+	// The digest of the source is computed as it is read, for caches of the File
+	hash := sha256.New()
+	r = io.TeeReader(r, hash)
+	if opts.MaxInputSize > 0 {
+		r = &sizeLimitReader{r: r, n: opts.MaxInputSize}
+	}
 
 	p := parser{
-		dcf: new(File),
-		lex: lex(buf.String()),
+		dcf:  NewFile(),
+		lex:  lexReader(r),
+		opts: opts,
+		ctx:  ctx,
+
+		expectedKeywords: make(map[string]Pos),
+		expectedStructs:  make(map[string]Pos),
+		expectedClasses:  make(map[string]Pos),
+		expectingKeyword: make(map[string][]Field),
+		expectingStruct:  make(map[string][]Field),
+		expectingClass:   make(map[string][]*Class),
+	}
+
+	p.lex.check = p.checkLimits
+	dcf = p.parse()
+	p.lex.close()
+	dcf.Warnings.Sort()
+	if len(p.errors) > 0 {
+		errs = ErrorList(p.errors)
+		errs.Sort()
+		return dcf, errs
+	}
+	var digest [sha256.Size]byte
+	hash.Sum(digest[:0])
+	dcf.source = &digest
+	return dcf, nil
+}
+
+// A sizeLimitReader reads from r, and fails with an inputSizeError once more than n bytes are
+// read.
+type sizeLimitReader struct {
+	r    io.Reader
+	n    int // the limit on the size of the input
+	read int // the number of bytes read
+}
+
+func (lr *sizeLimitReader) Read(b []byte) (int, error) {
+	if lr.read > lr.n {
+		return 0, inputSizeError(lr.n)
 	}
+	if len(b) > lr.n-lr.read+1 {
+		b = b[:lr.n-lr.read+1]
+	}
+	n, err := lr.r.Read(b)
+	lr.read += n
+	if lr.read > lr.n {
+		return n - (lr.read - lr.n), inputSizeError(lr.n)
+	}
+	return n, err
+}
 
-	return p.parse(r), nil
+// An inputSizeError is the error of a source larger than ParseOptions.MaxInputSize.
+type inputSizeError int
+
+func (err inputSizeError) Error() string {
+	return fmt.Sprintf("input is larger than %d bytes", int(err))
 }
 
 // parser is a constructor for a single parsed dclass File
 type parser struct {
-	dcf *File  // dclass File being produced by parser
-	lex *lexer // lexer to read tokens from
+	dcf  *File           // dclass File being produced by parser
+	lex  *lexer          // lexer to read tokens from
+	opts ParseOptions    // options the file is parsed with
+	ctx  context.Context // if set, parsing stops when the context is done, see checkLimits
 
 	// The expectedFoo fields are lists of identifiers that are expected for a declaration type, but
 	// not yet declared. Each identifer in the lists maps to the line number where it was first used.
-	expectedKeywords map[string]int
-	expectedStructs  map[string]int
-	expectedClasses  map[string]int
+	expectedKeywords map[string]Pos
+	expectedStructs  map[string]Pos
+	expectedClasses  map[string]Pos
 
 	// The expectingFoo fields are maps from an expected identifier to the list
 	// of objects that are expecting it.
@@ -44,159 +162,190 @@ type parser struct {
 	expectingStruct  map[string][]Field  // field's datatype is defined as the missing struct
 	expectingClass   map[string][]*Class // class inherits from the missing class or struct
 
-	errors   []Error // errors encountered while parsing (including lexer errors)
+	errors   []error // errors encountered while parsing (including lexer errors)
 	foundEOF bool    // whether next() has encountered an eof token
+
+	numTokens int // the number of tokens read, see checkLimits
+	depth     int // the nesting of braces, parentheses, and brackets of the last token read
 }
 
-func (p parser) parse(r io.Reader) *File {
+func (p *parser) parse() *File {
 	// Parse declarations until EOF or lexer error
 	for p.parseDeclaration() {
 	}
+	if p.lex.err != nil {
+		return p.dcf
+	}
 
 	// Create errors if there are any expected identifiers remaining that have not been defined
-	for keyword, firstLine := range p.expectedKeywords {
-		p.errors = append(p.errors, definitionError(keyword, tokenKeyword, firstLine))
+	for keyword, firstUsed := range p.expectedKeywords {
+		p.errors = append(p.errors, p.definitionError(keyword, tokenKeyword, firstUsed))
 	}
-	for structName, firstLine := range p.expectedStructs {
-		p.errors = append(p.errors, definitionError(structName, tokenStruct, firstLine))
+	for structName, firstUsed := range p.expectedStructs {
+		p.errors = append(p.errors, p.definitionError(structName, tokenStruct, firstUsed))
 	}
-	for className, firstLine := range p.expectedClasses {
-		p.errors = append(p.errors, definitionError(className, tokenDClass, firstLine))
+	for className, firstUsed := range p.expectedClasses {
+		p.errors = append(p.errors, p.definitionError(className, tokenDClass, firstUsed))
+	}
+	if p.tooManyErrors() {
+		return p.dcf
 	}
 
-	// If errors exist, print them and return nil
-	if len(p.errors) > 0 {
-		fmt.Fprintf(os.Stderr, "Encountered %d errors while parsing dclass file...\n", len(p.errors))
-
-		// Print max 10 errors...
-		for i := 0; i < 10 && i < len(p.errors); i++ {
-			fmt.Fprintf(os.Stderr, " - %s\n", p.errors[i].Error())
-		}
-
-		// ... and mention how many errors were not printed.
-		if len(p.errors) > 10 {
-			fmt.Fprintf(os.Stderr, "... an extra %d errors were not printed.\n", len(p.errors)-10)
+	// Check for errors which can only be detected once the file is complete
+	errs, warnings := p.dcf.Check(p.opts)
+	p.errors = append(p.errors, errs...)
+	if p.tooManyErrors() {
+		return p.dcf
+	}
+	p.dcf.Warnings = append(p.dcf.Warnings, warnings...)
+	p.dcf.lintSuppressions, warnings = p.lintSuppressions()
+	p.dcf.Warnings = append(p.dcf.Warnings, warnings...)
+
+	// Apply any application defined keyword semantics
+	for _, field := range p.dcf.Fields {
+		for _, err := range validateKeywords(field) {
+			p.errors = append(p.errors, keywordError(field, err))
 		}
 	}
+	p.tooManyErrors()
 
 	return p.dcf
 }
 
-// parseDeclaration parses a keyword, struct, or class declaration.
+// parseDeclaration parses a keyword, struct, class, enum, or const declaration.
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) parseDeclaration() bool {
+func (p *parser) parseDeclaration() bool {
 	t := p.peek()
 	switch t.typ {
 	case tokenEOF:
 		return false
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		return false
-	case tokenIdentifier:
-		if t.val == tokenName[tokenKeyword] {
-			return p.parseKeyword()
-		} else if t.val == tokenName[tokenStruct] {
-			return p.parseStruct()
-		} else if t.val == tokenName[tokenDClass] {
-			return p.parseClass()
-		}
-		fallthrough
-	case tokenLeftCurly:
-		p.next() // consome left curly brace
+	case tokenKeyword:
+		return p.parseKeyword()
+	case tokenStruct:
+		return p.parseStruct()
+	case tokenDClass:
+		return p.parseClass()
+	case tokenEnum:
+		return p.parseEnum()
+	case tokenConst:
+		return p.parseConst()
+	case tokenIdentifier, tokenLeftCurly:
+		p.next() // consume identifier or left curly brace
 
 		p.errors = append(p.errors, parseError("expected a declaration but got '"+t.String()+"'",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return p.expectRightCurly(p.lex.lineNumber())
 	default:
+		p.next() // consume unexpected token
+
 		p.errors = append(p.errors, parseError("expected a declaration but got '"+t.String()+"'",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return true
 	}
 }
 
 // parseKeyword parses a keyword declaration `keyword foo;`.
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) parseKeyword() bool {
-	p.next() // consume "keyword"
+func (p *parser) parseKeyword() bool {
+	doc := p.leadingDoc(p.next()) // consume "keyword"
 
 	t := p.next()
 	switch t.typ {
 	case tokenEOF:
 		p.errors = append(p.errors, parseError("incomplete 'keyword' declaration, found EOF",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return false
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		return false
 	case tokenIdentifier:
 		p.dcf.AddKeyword(t.val)
+		delete(p.expectedKeywords, t.val)
+		delete(p.expectingKeyword, t.val)
 
-		return p.expectEndline(p.lex.lineNumber())
+		decl := p.dcf.keywordDecl(t.val)
+		decl.pos = p.lex.position()
+		if !p.expectEndline(p.lex.lineNumber()) {
+			return false
+		}
+		decl.doc = joinDoc(doc, p.trailingDoc())
+		return true
 	default:
 		p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' in 'keyword' declaration",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return p.expectEndline(p.lex.lineNumber())
 	}
 }
 
 // parseStruct parses a struct declaration `struct foo {...};`.
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) parseStruct() bool {
-	p.next() // consume "struct"
+func (p *parser) parseStruct() bool {
+	doc := p.leadingDoc(p.next()) // consume "struct"
 
 	t := p.next()
 	switch t.typ {
 	case tokenEOF:
 		p.errors = append(p.errors, parseError("incomplete 'struct' declaration, found EOF",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return false
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		return false
 	case tokenLeftCurly:
 		errStr := "incomplete 'struct' declaration, missing identifier before definition start '{'"
-		p.errors = append(p.errors, parseError(errStr, p.lex.lineNumber()))
-		return p.expectRightCurly(p.lex.lineNumber())
+		p.errors = append(p.errors, parseError(errStr, p.lex.position()))
+		return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
 	case tokenIdentifier:
-		if p.dcf.ClassByName[t.val] != nil {
+		if p.isDeclared(t.val) {
 			p.errors = append(p.errors, parseError("cannot define struct "+t.val+
-				", "+t.val+"already defined above", p.lex.lineNumber()))
-			return p.expectRightCurly(p.lex.lineNumber())
+				", "+t.val+" already defined above", p.lex.position()))
+			return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
 		}
 
-		return p.parseStructInner(p.dcf.AddType(t.val, "struct").(*Struct))
+		s := p.dcf.AddType(t.val, "struct").(*Struct)
+		s.pos = p.lex.position()
+		p.resolveStruct(s)
+		if !p.parseTypeInner(s, "struct") {
+			return false
+		}
+		s.doc = joinDoc(doc, p.trailingDoc())
+		return true
 	default:
 		p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' in 'struct' declaration",
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return true
 	}
 }
 
-// parseStructInner parses the inner struct definition given within a block '{...}'.
+// parseTypeInner parses the inner struct or class definition given within a block '{...}'.
+// The decl argument is the kind of declaration, either "struct" or "dclass".
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) parseStructInner(s *Struct) bool {
+func (p *parser) parseTypeInner(obj fieldAdder, decl string) bool {
 	// expect a left curly to open the definition block
 	t := p.next()
 	switch t.typ {
 	case tokenEOF:
-		p.errors = append(p.errors, parseError("incomplete 'struct' declaration, found EOF",
-			p.lex.lineNumber()))
+		p.errors = append(p.errors, parseError("incomplete '"+decl+"' declaration, found EOF",
+			p.lex.position()))
 		return false
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		return false
 	case tokenLeftCurly:
 		break
 	default:
-		p.errors = append(p.errors, parseError("missing '{' after 'struct' declaration, found '"+t.String()+"'",
-			p.lex.lineNumber()))
+		p.errors = append(p.errors, parseError("missing '{' after '"+decl+"' declaration, found '"+t.String()+"'",
+			p.lex.position()))
 		return true
 	}
 
 	// parse for parameters till we find a RightCurly
 	t = p.peek()
 	for t.typ != tokenRightCurly && t.typ != tokenEOF && t.typ != tokenError {
-		if !p.parseField(s) {
+		if !p.parseField(obj) {
 			return false
 		}
 		t = p.peek()
@@ -207,11 +356,11 @@ func (p parser) parseStructInner(s *Struct) bool {
 	// finished struct definition, handle any errors then expect endline
 	switch t.typ {
 	case tokenEOF:
-		p.errors = append(p.errors, parseError("incomplete 'struct' definition, found EOF",
-			p.lex.lineNumber()))
+		p.errors = append(p.errors, parseError("incomplete '"+decl+"' definition, found EOF",
+			p.lex.position()))
 		return false
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		return false
 	}
 
@@ -220,11 +369,86 @@ func (p parser) parseStructInner(s *Struct) bool {
 
 // parseClass parses a dclass declaration `dclass foo {...};`.
 // Returns false upon reaching tokenEOF or tokenError.
-// TODO: Implement
-func (p parser) parseClass() bool {
-	p.next() // consume "dclass"
+func (p *parser) parseClass() bool {
+	doc := p.leadingDoc(p.next()) // consume "dclass"
 
-	return true
+	t := p.next()
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("incomplete 'dclass' declaration, found EOF",
+			p.lex.position()))
+		return false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return false
+	case tokenLeftCurly:
+		errStr := "incomplete 'dclass' declaration, missing identifier before definition start '{'"
+		p.errors = append(p.errors, parseError(errStr, p.lex.position()))
+		return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+	case tokenIdentifier:
+		if p.isDeclared(t.val) {
+			p.errors = append(p.errors, parseError("cannot define dclass "+t.val+
+				", "+t.val+" already defined above", p.lex.position()))
+			return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+		}
+
+		c := p.dcf.AddType(t.val, "class").(*Class)
+		c.pos = p.lex.position()
+		p.resolveClass(c)
+
+		// Read optional parents
+		if p.peek().typ == tokenComposition {
+			p.next() // consume ":"
+			if !p.parseParents(c) {
+				return false
+			}
+		}
+
+		if !p.parseTypeInner(c, "dclass") {
+			return false
+		}
+		c.doc = joinDoc(doc, p.trailingDoc())
+		return true
+	default:
+		p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' in 'dclass' declaration",
+			p.lex.position()))
+		return true
+	}
+}
+
+// parseParents parses the list of classes a class inherits from `dclass foo : bar, baz {...};`,
+// assumes the colon has been consumed. Parents which have not yet been defined are expected to
+// be defined later in the file.  Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseParents(c *Class) bool {
+	for {
+		t := p.next()
+		if t.typ != tokenIdentifier {
+			if !p.valueError(t, "expected the name of a parent of dclass "+c.name) {
+				return false
+			}
+			return p.skipUntil(tokenLeftCurly)
+		}
+
+		switch parent := p.dcf.ClassByName[t.val].(type) {
+		case *Class:
+			c.AddParent(parent)
+		case *Struct:
+			p.errors = append(p.errors, parseError("dclass "+c.name+" cannot inherit from struct "+
+				t.val, p.lex.position()))
+		default:
+			// add a placeholder parent, which is replaced when the parent is defined
+			c.AddParent(&Class{typeBase: typeBase{name: t.val}})
+			if _, ok := p.expectedClasses[t.val]; !ok {
+				p.expectedClasses[t.val] = p.lex.position()
+			}
+			p.expectingClass[t.val] = append(p.expectingClass[t.val], c)
+		}
+
+		if p.peek().typ != tokenSeperator {
+			return true
+		}
+		p.next() // consume ","
+	}
 }
 
 // the fieldAdder interface is used by parseField() to accept any object that
@@ -236,9 +460,25 @@ type fieldAdder interface {
 	AddField(name, typ string) Field
 }
 
-// parseField parses a field. Returns false upon reaching tokenEOF or tokenError.
-func (p parser) parseField(obj fieldAdder) bool {
+// parseField parses a field and attaches its comments. Returns false upon reaching tokenEOF or
+// tokenError.
+func (p *parser) parseField(obj fieldAdder) bool {
 	t := p.next()
+	doc := p.leadingDoc(t)
+
+	numFields := len(p.dcf.Fields)
+	if !p.parseFieldInner(t, obj) {
+		return false
+	}
+	if len(p.dcf.Fields) > numFields {
+		p.dcf.Fields[numFields].(interface{ setDoc(string) }).setDoc(joinDoc(doc, p.trailingDoc()))
+	}
+	return true
+}
+
+// parseFieldInner parses a field starting with the token t.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseFieldInner(t token, obj fieldAdder) bool {
 	switch {
 	case t.typ == tokenIdentifier:
 		switch p.peek().typ {
@@ -246,55 +486,209 @@ func (p parser) parseField(obj fieldAdder) bool {
 			return p.parseAtomic(t.val, obj)
 		case tokenComposition:
 			return p.parseMolecular(t.val, obj)
-		default:
-			return p.parseParameter(t, obj, false)
 		}
+		fallthrough
 	case isDataTypeToken(t):
-		return p.parseParameter(t, obj, false)
+		param, ok := p.parseParameter(t, obj, false)
+		if !ok {
+			return false
+		}
+
+		// class members may be followed by keywords
+		if _, isClass := obj.(*Class); isClass && param != nil {
+			p.parseKeywords(param)
+		}
+		return p.expectEndline(p.lex.lineNumber())
 	default:
 		p.errors = append(p.errors, parseError("expecting a field, found "+t.String(),
-			p.lex.lineNumber()))
+			p.lex.position()))
 		return p.expectEndline(p.lex.lineNumber())
 	}
 }
 
 // parseAtomic parses an atomic field `foo(...) ...;`, assumes the identifier has been consumed.
 // Returns false upon reaching tokenEOF or tokenError.
-// TODO: Implement
-func (p parser) parseAtomic(ident string, obj fieldAdder) bool {
-	return true
+func (p *parser) parseAtomic(ident string, obj fieldAdder) bool {
+	line := p.lex.lineNumber()
+
+	field := obj.AddField(ident, "atomic")
+	if field == nil {
+		p.errors = append(p.errors, parseError("cannot add atomic field '"+ident+"' here", p.lex.position()))
+		return p.expectEndline(line)
+	}
+	atomic := field.(*AtomicField)
+	atomic.pos = p.lex.position()
+
+	p.next() // consume "("
+	if p.peek().typ == tokenRightParen {
+		p.next() // consume ")"
+	} else {
+		for {
+			typTok := p.next()
+			doc := p.leadingDoc(typTok)
+			param, ok := p.parseParameter(typTok, atomic, true)
+			if !ok {
+				return false
+			}
+			trailing := p.trailingDoc() // comments before the ',' or ')'
+
+			t := p.next()
+			if t.typ == tokenSeperator {
+				trailing = joinDoc(trailing, p.trailingDoc())
+			}
+			if param != nil {
+				param.doc = joinDoc(doc, trailing)
+			}
+
+			if t.typ == tokenRightParen {
+				break
+			} else if t.typ != tokenSeperator {
+				if !p.valueError(t, "expected ',' or ')' after argument of '"+ident+"'") {
+					return false
+				}
+				return p.expectEndline(line)
+			}
+		}
+	}
+
+	p.parseKeywords(atomic)
+	return p.expectEndline(line)
 }
 
 // parseMolecular parses a molecular field `foo: baz, bar;`, assumes the identifier has been consumed.
 // Returns false upon reaching tokenEOF or tokenError.
-// TODO: Implement
-func (p parser) parseMolecular(ident string, obj fieldAdder) bool {
-	return true
+func (p *parser) parseMolecular(ident string, obj fieldAdder) bool {
+	line := p.lex.lineNumber()
+	pos := p.lex.position()
+	p.next() // consume ":"
+
+	field := obj.AddField(ident, "molecular")
+	if field == nil {
+		p.errors = append(p.errors, parseError("cannot add molecular field '"+ident+"' here", p.lex.position()))
+		return p.expectEndline(line)
+	}
+	molecular := field.(*MolecularField)
+	molecular.pos = pos
+	class := obj.(*Class)
+
+	for {
+		t := p.next()
+		if t.typ != tokenIdentifier {
+			if !p.valueError(t, "expected a component of molecular field '"+ident+"'") {
+				return false
+			}
+			return p.expectEndline(line)
+		}
+
+		if component := class.FieldByName(t.val); component == nil {
+			var fieldNames []string
+			for _, f := range class.InheritedFields() {
+				if f != field {
+					fieldNames = append(fieldNames, f.Name())
+				}
+			}
+			p.errors = append(p.errors, withSuggestions(parseError("molecular field '"+ident+
+				"' has unknown component '"+t.val+"'", p.lex.position()), suggest(t.val, fieldNames)))
+		} else if !molecular.AddComponent(component) {
+			p.errors = append(p.errors, parseError("'"+t.val+"' cannot be a component of molecular field '"+
+				ident+"'", p.lex.position()))
+		}
+
+		if p.peek().typ != tokenSeperator {
+			break
+		}
+		p.next() // consume ","
+	}
+
+	return p.expectEndline(line)
+}
+
+// parseKeywords parses the keywords following a field. Keywords which have not been declared
+// are expected to be declared later in the file.
+func (p *parser) parseKeywords(field Field) {
+	for t := p.peek(); t.typ == tokenIdentifier; t = p.peek() {
+		p.next() // consume keyword
+
+		if field.HasKeyword(t.val) {
+			p.errors = append(p.errors, parseError("keyword '"+t.val+"' repeated on field '"+
+				field.Name()+"'", p.lex.position()))
+			continue
+		}
+		field.AddKeyword(t.val)
+
+		if !p.dcf.HasKeyword(t.val) && !isDefinedKeyword(t.val) {
+			if _, ok := p.expectedKeywords[t.val]; !ok {
+				p.expectedKeywords[t.val] = p.lex.position()
+			}
+			p.expectingKeyword[t.val] = append(p.expectingKeyword[t.val], field)
+		}
+	}
 }
 
 // parseParameter parses a parameter as either  a struct/class member variable `type foo ...;` or
 // or as an atomic field argument `type foo ...,`, assumes the type has been consumed.
-// Returns false upon reaching tokenEOF or tokenError.
+// The parameter has the form `type [transform] [(range)] [[array]] [name] [= default]`.
+// The terminating endline or argument delimiter is not consumed.
+// Returns a nil parameter if the parameter could not be created, and false upon reaching
+// tokenEOF or tokenError.
 //
 // isArgument should be true if the parameter is an argument of an atomic field.
-// TODO: Implement
-func (p parser) parseParameter(typTok token, obj fieldAdder, isArgument bool) bool {
+func (p *parser) parseParameter(typTok token, obj fieldAdder, isArgument bool) (*Parameter, bool) {
 	var t token
 
+	// skipParam skips the rest of the parameter after an error
+	skipParam := func() (*Parameter, bool) {
+		if isArgument {
+			return nil, p.skipUntil(tokenSeperator, tokenRightParen)
+		}
+		return nil, p.skipUntil(tokenEndline)
+	}
+
 	// Get data type
 	dataType := typeFromToken(typTok)
+	if typTok.typ == tokenError {
+		p.errors = append(p.errors, p.lexError(typTok))
+		return nil, false
+	}
 	if dataType == InvalidType {
-		p.errors = append(p.errors, parseError("expecting a type, found "+t.String(),
-			p.lex.lineNumber()))
-		return p.expectArgDelim(p.lex.lineNumber(), false)
+		p.errors = append(p.errors, parseError("expecting a type, found "+typTok.String(),
+			p.lex.position()))
+		return skipParam()
+	}
+
+	// Resolve named types
+	var typeName string
+	var structType *Struct
+	var enum *Enum
+	if typTok.typ == tokenIdentifier {
+		typeName = typTok.val
+		if e, ok := p.dcf.EnumByName[typeName]; ok {
+			enum = e
+			dataType = e.dataType
+		} else if typ, ok := p.dcf.ClassByName[typeName]; ok {
+			if structType, ok = typ.(*Struct); !ok {
+				p.errors = append(p.errors, parseError("cannot use dclass "+typeName+
+					" as a parameter type", p.lex.position()))
+				return skipParam()
+			}
+		}
 	}
 
 	// Read optional parameter transform
-	var trans *Transform = nil
+	var trans Transform
 	t = p.peek()
 	if t.typ == tokenOperator {
-		if !p.parseTransform(trans) {
-			return false
+		if !dataType.IsInteger() && dataType != FloatType {
+			p.errors = append(p.errors, parseError("cannot apply a transform to a parameter of type "+
+				dataType.String(), p.lex.position()))
+			return skipParam()
+		}
+
+		var ok bool
+		if trans, ok = p.parseTransform(); !ok {
+			return nil, false
+		} else if trans == nil {
+			return skipParam()
 		}
 	}
 
@@ -302,8 +696,69 @@ func (p parser) parseParameter(typTok token, obj fieldAdder, isArgument bool) bo
 	var rng Range = nil
 	t = p.peek()
 	if t.typ == tokenLeftParen {
-		if !p.parseRange(rng) {
-			return false
+		min, max, ok := p.parseRange()
+		if !ok {
+			return nil, false
+		} else if min == nil {
+			return skipParam()
+		}
+
+		var err error
+		if rng, err = newRange(dataType, min, max); err != nil {
+			p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+			return skipParam()
+		}
+	}
+
+	// Read optional array size
+	var isArray bool
+	var arraySize int
+	var arrayRange Range
+	t = p.peek()
+	switch t.typ {
+	case tokenVarArray:
+		p.next() // consume "[]"
+		isArray = true
+	case tokenLeftSquare:
+		p.next() // consume "["
+		isArray = true
+
+		min, ok := p.parseNumber(p.next(), nil)
+		if !ok {
+			return nil, false
+		} else if min == nil {
+			return skipParam()
+		}
+		max := min
+		if t = p.peek(); t.typ == tokenOperator && t.val == "-" {
+			p.next() // consume "-"
+			if max, ok = p.parseNumber(p.next(), nil); !ok {
+				return nil, false
+			} else if max == nil {
+				return skipParam()
+			}
+		}
+		if t = p.next(); t.typ != tokenRightSquare {
+			p.errors = append(p.errors, parseError("missing closing ']' in array size, found "+
+				t.String(), p.lex.position()))
+			return skipParam()
+		}
+
+		if min == max {
+			if !min.IsInt() || min.Sign() <= 0 || min.Cmp(big.NewRat(math.MaxInt16, 1)) > 0 {
+				p.errors = append(p.errors, parseError("invalid array size "+min.RatString(),
+					p.lex.position()))
+				return skipParam()
+			}
+			arraySize = int(min.Num().Int64())
+		} else {
+			rng, err := newRange(Int16Type, min, max)
+			if err != nil || min.Sign() < 0 {
+				p.errors = append(p.errors, parseError("invalid array size range "+min.RatString()+
+					"-"+max.RatString(), p.lex.position()))
+				return skipParam()
+			}
+			arrayRange = RangeArray{rng.(RangeInt16)}
 		}
 	}
 
@@ -317,38 +772,623 @@ func (p parser) parseParameter(typTok token, obj fieldAdder, isArgument bool) bo
 
 	// Member variables require a name
 	if !isArgument && len(paramName) == 0 {
-		p.errors = append(p.errors, parseError("expecting a type, found "+t.String(),
-			p.lex.lineNumber()))
-		return p.expectArgDelim(p.lex.lineNumber(), false)
+		p.errors = append(p.errors, parseError("expecting a parameter name, found "+t.String(),
+			p.lex.position()))
+		return skipParam()
+	}
+
+	// Add the parameter to the object
+	field := obj.AddField(paramName, "parameter")
+	if field == nil {
+		p.errors = append(p.errors, parseError("cannot add parameter '"+paramName+"' here",
+			p.lex.position()))
+		return skipParam()
+	}
+	param := field.(*Parameter)
+	param.pos = p.lex.position()
+	param.dataType = dataType
+	param.typeName = typeName
+	param.structType = structType
+	param.enum = enum
+	param.isArray = isArray
+	param.arraySize = arraySize
+	param.ArrayRange = arrayRange
+	param.Range = rng
+	param.Transform = trans
+
+	// Forward declared structs are resolved when the struct is defined
+	if dataType == StructType && structType == nil {
+		if _, ok := p.expectedStructs[typeName]; !ok {
+			p.expectedStructs[typeName] = p.lex.position()
+		}
+		p.expectingStruct[typeName] = append(p.expectingStruct[typeName], param)
 	}
 
 	// Read optional default value
-	var value *big.Rat = nil // arbitrary precision rational number
 	t = p.peek()
 	if t.typ == tokenAssignment {
-		if !p.parseNumber(value) {
+		p.next() // consume "="
+
+		numErrors := len(p.errors)
+		if !p.parseValue(p.next(), param, &param.defVal) {
+			return param, false
+		}
+		if len(p.errors) > numErrors {
+			param.defVal.Reset()
+			skipParam()
+			return param, !p.foundEOF
+		}
+		param.hasDefVal = true
+	}
+
+	return param, true
+}
+
+// parseTransform parses a sequence of arithmetic operations `/ 10 % 360` following a parameter type.
+// Returns a nil transform if an error occurred, and false upon reaching tokenEOF or tokenError.
+func (p *parser) parseTransform() (Transform, bool) {
+	var trans Transform
+	for t := p.peek(); t.typ == tokenOperator; t = p.peek() {
+		p.next() // consume operator
+
+		operand, ok := p.parseNumber(p.next(), nil)
+		if !ok {
+			return nil, false
+		} else if operand == nil {
+			return nil, true
+		}
+
+		op := TransformOp{Operator: t.val[0]}
+		op.Operand, _ = operand.Float64()
+		if op.Operand == 0 && (op.Operator == '/' || op.Operator == '%') {
+			p.errors = append(p.errors, parseError("transform cannot divide by zero", p.lex.position()))
+			return nil, true
+		} else if op.Operand == 0 && op.Operator == '*' {
+			p.errors = append(p.errors, parseError("transform cannot multiply by zero", p.lex.position()))
+			return nil, true
+		}
+		trans = append(trans, op)
+	}
+	return trans, true
+}
+
+// parseRange parses a range `(min-max)` or `(value)`, including the surrounding parens.
+// Returns nil bounds if an error occurred, and false upon reaching tokenEOF or tokenError.
+func (p *parser) parseRange() (min, max *big.Rat, ok bool) {
+	p.next() // consume "("
+
+	if min, ok = p.parseNumber(p.next(), nil); !ok || min == nil {
+		return nil, nil, ok
+	}
+	max = min
+
+	t := p.next()
+	if t.typ == tokenOperator && t.val == "-" {
+		if max, ok = p.parseNumber(p.next(), nil); !ok || max == nil {
+			return nil, nil, ok
+		}
+		t = p.next()
+	}
+
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("incomplete range, found EOF", p.lex.position()))
+		return nil, nil, false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return nil, nil, false
+	case tokenRightParen:
+		return min, max, true
+	default:
+		p.errors = append(p.errors, parseError("expected ')' at end of range, found "+t.String(),
+			p.lex.position()))
+		return nil, nil, true
+	}
+}
+
+// parseNumber parses a number, starting with the already consumed token t. A number is a numeric
+// literal or the name of a constant, optionally preceded by a sign.  If enum is not nil, the names
+// of the enum's values are accepted as well.
+// Returns nil if an error occurred, and false upon reaching tokenEOF or tokenError.
+func (p *parser) parseNumber(t token, enum *Enum) (*big.Rat, bool) {
+	neg := false
+	if t.typ == tokenOperator && (t.val == "-" || t.val == "+") {
+		neg = t.val == "-"
+		t = p.next()
+	}
+
+	var num *big.Rat
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("expected a number, found EOF", p.lex.position()))
+		return nil, false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return nil, false
+	case tokenNumber:
+		num = parseNumberLiteral(t.val)
+		if num == nil {
+			p.errors = append(p.errors, parseError("invalid number "+t.String(), p.lex.position()))
+			return nil, true
+		}
+	case tokenIdentifier:
+		if enum != nil {
+			if v, ok := enum.ValueByName(t.val); ok {
+				num = new(big.Rat).SetInt(v)
+				break
+			}
+		}
+		if c, ok := p.dcf.ConstByName[t.val]; ok {
+			num = new(big.Rat).Set(c.Value)
+			break
+		}
+
+		errStr := "'" + t.val + "' is not a constant"
+		if enum != nil {
+			errStr = "'" + t.val + "' is not a value of enum " + enum.name + " or a constant"
+		}
+		p.errors = append(p.errors, withSuggestions(parseError(errStr, p.lex.position()),
+			suggest(t.val, p.dcf.valueNames(enum))))
+		return nil, true
+	default:
+		p.errors = append(p.errors, parseError("expected a number, found "+t.String(), p.lex.position()))
+		return nil, true
+	}
+
+	if neg {
+		num.Neg(num)
+	}
+	return num, true
+}
+
+// parseNumberLiteral converts a number token into a rational number. Integers may be written
+// in decimal, hexadecimal, octal, or binary.  Returns nil if s is not a valid number.
+func parseNumberLiteral(s string) *big.Rat {
+	if strings.Contains(s, ".") {
+		num, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil
+		}
+		return num
+	}
+
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil
+	}
+	return new(big.Rat).SetInt(n)
+}
+
+// parseString parses a human readable value of the field (the output of FormatData) and
+// returns the packed data.
+func parseString(f Field, s string) (data bytes.Buffer, err error) {
+	p := parser{dcf: f.File(), lex: lex(s)}
+	defer p.lex.close()
+	if p.parseFieldValue(p.next(), f, &data) {
+		if t := p.next(); t.typ != tokenEOF {
+			p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' after value",
+				p.lex.position()))
+		}
+	}
+	if len(p.errors) > 0 {
+		return bytes.Buffer{}, p.errors[0]
+	}
+	return data, nil
+}
+
+// parseFieldValue parses a value of any field, starting with the already consumed token t, and packs
+// it into buf. Atomic fields are written as a list of arguments in parens `(1, 2)`, and molecular
+// fields as a list of their components `((1, 2), 3)`.  Arguments may be prefixed by their name.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseFieldValue(t token, f Field, buf *bytes.Buffer) bool {
+	if param, ok := f.(*Parameter); ok {
+		return p.parseValue(t, param, buf)
+	}
+
+	if t.typ != tokenLeftParen {
+		return p.valueError(t, "expected '(' to begin value of '"+f.Name()+"'")
+	}
+	for i, nested := range f.NestedFields() {
+		t = p.next()
+		if i > 0 {
+			if t.typ != tokenSeperator {
+				return p.valueError(t, "expected ',' in value of '"+f.Name()+"'")
+			}
+			t = p.next()
+		}
+
+		// skip an optional argument name
+		if t.typ == tokenIdentifier && p.peek().typ == tokenAssignment {
+			if t.val != nested.Name() {
+				return p.valueError(t, "expected value of argument '"+nested.Name()+"'")
+			}
+			p.next() // consume "="
+			t = p.next()
+		}
+
+		numErrors := len(p.errors)
+		if !p.parseFieldValue(t, nested, buf) {
+			return false
+		} else if len(p.errors) > numErrors {
+			return true
+		}
+	}
+	if t = p.next(); t.typ != tokenRightParen {
+		return p.valueError(t, "expected ')' to end value of '"+f.Name()+"'")
+	}
+	return true
+}
+
+// parseValue parses a value of the parameter, starting with the already consumed token t, and packs
+// it into buf. Arrays and structs are written as a list of values in curly braces `{1, 2, 3}`;
+// the values of a struct may be prefixed by the member name `{x = 1, y = 2}`.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseValue(t token, param *Parameter, buf *bytes.Buffer) bool {
+	if !param.isArray {
+		return p.parseElementValue(t, param, buf)
+	}
+
+	if t.typ != tokenLeftCurly {
+		return p.valueError(t, "expected '{' to begin array value")
+	}
+
+	var elems bytes.Buffer
+	count := 0
+	for t = p.next(); t.typ != tokenRightCurly; t = p.next() {
+		if count > 0 {
+			if t.typ != tokenSeperator {
+				return p.valueError(t, "expected ',' or '}' in array value")
+			}
+			t = p.next()
+		}
+
+		numErrors := len(p.errors)
+		if !p.parseElementValue(t, param, &elems) {
 			return false
+		} else if len(p.errors) > numErrors {
+			return true
 		}
+		count++
 	}
 
-	// TODO handle read-in values
+	if param.arraySize > 0 {
+		if count != param.arraySize {
+			p.errors = append(p.errors, parseError(fmt.Sprintf("array value has %d elements, expected %d",
+				count, param.arraySize), p.lex.position()))
+			return true
+		}
+		buf.Write(elems.Bytes())
+		return true
+	}
 
+	if !inRange(param.ArrayRange, big.NewRat(int64(count), 1)) {
+		p.errors = append(p.errors, parseError(fmt.Sprintf("array value has %d elements, "+
+			"outside of the range of parameter '%s'", count, param.name), p.lex.position()))
+		return true
+	}
+	if err := packLength(elems.Len(), buf); err != nil {
+		p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+		return true
+	}
+	buf.Write(elems.Bytes())
 	return true
 }
 
-// TODO: Doc and implement
-func (p parser) parseTransform(trans *Transform) bool {
-	return false
+// parseElementValue parses a value of a single element of the parameter, starting with the already
+// consumed token t, and packs it into buf. Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseElementValue(t token, param *Parameter, buf *bytes.Buffer) bool {
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("expected a value, found EOF", p.lex.position()))
+		return false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return false
+	}
+
+	switch param.dataType {
+	case StructType:
+		if param.structType == nil {
+			return p.valueError(t, "struct "+param.typeName+" must be defined before its value")
+		}
+		if t.typ != tokenLeftCurly {
+			return p.valueError(t, "expected '{' to begin struct value")
+		}
+
+		for i, field := range param.structType.fields {
+			t = p.next()
+			if i > 0 {
+				if t.typ != tokenSeperator {
+					return p.valueError(t, "expected ',' in struct value")
+				}
+				t = p.next()
+			}
+
+			// skip an optional member name
+			if t.typ == tokenIdentifier && p.peek().typ == tokenAssignment {
+				if t.val != field.Name() {
+					return p.valueError(t, "expected value of member '"+field.Name()+"'")
+				}
+				p.next() // consume "="
+				t = p.next()
+			}
+
+			numErrors := len(p.errors)
+			if !p.parseValue(t, field.(*Parameter), buf) {
+				return false
+			} else if len(p.errors) > numErrors {
+				return true
+			}
+		}
+
+		if t = p.next(); t.typ != tokenRightCurly {
+			return p.valueError(t, "expected '}' to end struct value")
+		}
+		return true
+	case StringType, BlobType:
+		if t.typ != tokenQuote {
+			return p.valueError(t, "expected a quoted string")
+		}
+		s, err := unquote(t.val)
+		if err == nil {
+			err = packString(param, s, buf)
+		}
+		if err != nil {
+			p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+		}
+		return true
+	}
+
+	var num *big.Rat
+	switch t.typ {
+	case tokenBool:
+		num = new(big.Rat)
+		if t.val == "true" {
+			num.SetInt64(1)
+		}
+	case tokenRawchar:
+		if param.dataType != CharType {
+			return p.valueError(t, "expected a number")
+		}
+		c, err := unquoteChar(t.val)
+		if err != nil {
+			p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+			return true
+		}
+		num = new(big.Rat).SetInt64(int64(c))
+	default:
+		var ok bool
+		if num, ok = p.parseNumber(t, param.enum); !ok || num == nil {
+			return ok
+		}
+	}
+
+	if err := packNumber(param, num, buf); err != nil {
+		p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+	}
+	return true
 }
 
-// TODO: Doc and implement
-func (p parser) parseRange(rng Range) bool {
-	return false
+// valueError creates an error for the unexpected token t in a value.
+// Returns false if t is tokenEOF or tokenError.
+func (p *parser) valueError(t token, msg string) bool {
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError(msg+", found EOF", p.lex.position()))
+		return false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return false
+	}
+	p.errors = append(p.errors, parseError(msg+", found "+t.String(), p.lex.position()))
+	return true
 }
 
-// TODO: Doc and implement
-func (p parser) parseNumber(rat *big.Rat) bool {
-	return false
+// parseEnum parses an enum declaration `enum foo : uint8 {A = 1, B, C};`.
+// The underlying type defaults to int32 if not specified.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseEnum() bool {
+	p.next() // consume "enum"
+
+	t := p.next()
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("incomplete 'enum' declaration, found EOF",
+			p.lex.position()))
+		return false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return false
+	case tokenIdentifier:
+		break
+	default:
+		p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' in 'enum' declaration",
+			p.lex.position()))
+		return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+	}
+
+	name, pos := t.val, p.lex.position()
+	if p.isDeclared(name) {
+		p.errors = append(p.errors, parseError("cannot define enum "+name+
+			", "+name+" already defined above", p.lex.position()))
+		return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+	}
+
+	// Read optional underlying type
+	dataType := Int32Type
+	if p.peek().typ == tokenComposition {
+		p.next() // consume ":"
+
+		t = p.next()
+		if dataType = typeFromToken(t); !dataType.IsInteger() {
+			p.errors = append(p.errors, parseError("enum "+name+" must have an integer type, found "+
+				t.String(), p.lex.position()))
+			return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+		}
+	}
+	e := p.dcf.AddEnum(name, dataType)
+	e.pos = pos
+
+	if t = p.next(); t.typ != tokenLeftCurly {
+		return p.valueError(t, "missing '{' after 'enum' declaration") &&
+			p.expectEndline(p.lex.lineNumber())
+	}
+
+	// parse values till we find a RightCurly
+	value := new(big.Int)
+	for t = p.next(); t.typ != tokenRightCurly; t = p.next() {
+		if t.typ != tokenIdentifier {
+			if !p.valueError(t, "expected an enum value name") {
+				return false
+			}
+			return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+		}
+		valueName := t.val
+
+		if p.peek().typ == tokenAssignment {
+			p.next() // consume "="
+
+			num, ok := p.parseNumber(p.next(), e)
+			if !ok {
+				return false
+			} else if num == nil {
+				return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+			} else if !num.IsInt() {
+				p.errors = append(p.errors, parseError("value of "+name+"."+valueName+
+					" must be an integer", p.lex.position()))
+				return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+			}
+			value.Set(num.Num())
+		}
+
+		if err := e.AddValue(valueName, value); err != nil {
+			p.errors = append(p.errors, parseError(err.Error(), p.lex.position()))
+		}
+		value.Add(value, big.NewInt(1))
+
+		if t = p.peek(); t.typ == tokenSeperator {
+			p.next() // consume ","
+		} else if t.typ != tokenRightCurly {
+			if !p.valueError(p.next(), "expected ',' or '}' after enum value") {
+				return false
+			}
+			return p.expectRightCurly(p.lex.lineNumber()) && p.expectEndline(p.lex.lineNumber())
+		}
+	}
+
+	return p.expectEndline(p.lex.lineNumber())
+}
+
+// parseConst parses a constant declaration `const foo = 10;` or `const uint8 foo = 10;`.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) parseConst() bool {
+	p.next() // consume "const"
+
+	// Read optional type
+	dataType := InvalidType
+	t := p.next()
+	if isDataTypeToken(t) {
+		dataType = typeFromToken(t)
+		if !dataType.IsInteger() && dataType != FloatType {
+			p.errors = append(p.errors, parseError("constants must have a numeric type, found "+
+				t.String(), p.lex.position()))
+			return p.expectEndline(p.lex.lineNumber())
+		}
+		t = p.next()
+	}
+
+	switch t.typ {
+	case tokenEOF:
+		p.errors = append(p.errors, parseError("incomplete 'const' declaration, found EOF",
+			p.lex.position()))
+		return false
+	case tokenError:
+		p.errors = append(p.errors, p.lexError(t))
+		return false
+	case tokenIdentifier:
+		break
+	default:
+		p.errors = append(p.errors, parseError("unexpected '"+t.String()+"' in 'const' declaration",
+			p.lex.position()))
+		return p.expectEndline(p.lex.lineNumber())
+	}
+
+	name, pos := t.val, p.lex.position()
+	if p.isDeclared(name) {
+		p.errors = append(p.errors, parseError("cannot define const "+name+
+			", "+name+" already defined above", p.lex.position()))
+		return p.expectEndline(p.lex.lineNumber())
+	}
+
+	if t = p.next(); t.typ != tokenAssignment {
+		return p.valueError(t, "missing '=' in 'const' declaration") && p.expectEndline(p.lex.lineNumber())
+	}
+
+	value, ok := p.parseNumber(p.next(), nil)
+	if !ok {
+		return false
+	} else if value == nil {
+		return p.expectEndline(p.lex.lineNumber())
+	}
+	if dataType.IsInteger() && (!value.IsInt() || !fitsType(dataType, value.Num())) {
+		p.errors = append(p.errors, parseError("value "+value.RatString()+" of const "+name+
+			" does not fit in type "+dataType.String(), p.lex.position()))
+		return p.expectEndline(p.lex.lineNumber())
+	}
+
+	p.dcf.AddConst(name, dataType, value).pos = pos
+	return p.expectEndline(p.lex.lineNumber())
+}
+
+// isDeclared returns whether the identifier is already declared as a class, struct, enum, or const.
+func (p *parser) isDeclared(ident string) bool {
+	_, isType := p.dcf.ClassByName[ident]
+	_, isEnum := p.dcf.EnumByName[ident]
+	_, isConst := p.dcf.ConstByName[ident]
+	return isType || isEnum || isConst
+}
+
+// resolveStruct sets the type of any parameters which used the struct before it was defined.
+func (p *parser) resolveStruct(s *Struct) {
+	for _, field := range p.expectingStruct[s.name] {
+		field.(*Parameter).structType = s
+	}
+	delete(p.expectingStruct, s.name)
+	delete(p.expectedStructs, s.name)
+}
+
+// resolveClass replaces the placeholder parents of any classes which inherited from the class
+// before it was defined.
+func (p *parser) resolveClass(c *Class) {
+	for _, child := range p.expectingClass[c.name] {
+		for i, parent := range child.parents {
+			if parent.dcf == nil && parent.name == c.name {
+				child.parents[i] = c
+			}
+		}
+	}
+	delete(p.expectingClass, c.name)
+	delete(p.expectedClasses, c.name)
+}
+
+// skipUntil consumes all tokens upto but not including the next token of one of the argument types.
+// Returns false upon reaching tokenEOF or tokenError.
+func (p *parser) skipUntil(types ...tokenType) bool {
+	for t := p.peek(); ; t = p.peek() {
+		switch t.typ {
+		case tokenEOF:
+			return false
+		case tokenError:
+			p.errors = append(p.errors, p.lexError(p.next()))
+			return false
+		}
+		for _, typ := range types {
+			if t.typ == typ {
+				return true
+			}
+		}
+		p.next() // consume token
+	}
 }
 
 // expectArgDelim checks if the next token is either a seperator ',', closing paren ')',
@@ -357,8 +1397,7 @@ func (p parser) parseNumber(rat *big.Rat) bool {
 // Returns false upon reaching tokenEOF or tokenError.
 //
 // If isNext is false, no error will be produced if a valid token is not next
-// TODO: Implement
-func (p parser) expectArgDelim(startline int, isNext bool) bool {
+func (p *parser) expectArgDelim(startline int, isNext bool) bool {
 	var fail, next bool
 	next = true
 
@@ -366,6 +1405,7 @@ func (p parser) expectArgDelim(startline int, isNext bool) bool {
 	for t.typ != tokenSeperator && t.typ != tokenRightParen &&
 		t.typ != tokenError && t.typ != tokenEOF {
 		p.next() // consume token
+		next = false
 
 		t = p.peek()
 	}
@@ -376,13 +1416,13 @@ func (p parser) expectArgDelim(startline int, isNext bool) bool {
 		fail = true
 	case tokenError:
 		t = p.next() // get error token
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		fail = true
 	}
 
-	if (isNext && next) || fail {
+	if (isNext && !next) || fail {
 		p.errors = append(p.errors,
-			parseError("missing seperator ','' or closing paren ')' after field argument", startline))
+			parseError("missing seperator ','' or closing paren ')' after field argument", p.lex.position()))
 	}
 
 	return !fail
@@ -391,7 +1431,7 @@ func (p parser) expectArgDelim(startline int, isNext bool) bool {
 // expectEndline checks if the next token is an endline ';' and then consumes it.
 // If not, it creates a parse error and consumes all the tokens until the next endline.
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) expectEndline(startline int) bool {
+func (p *parser) expectEndline(startline int) bool {
 	var fail, next bool
 
 	next = true
@@ -405,12 +1445,12 @@ func (p parser) expectEndline(startline int) bool {
 	case tokenEOF:
 		fail = true
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		fail = true
 	}
 
-	if next || fail {
-		p.errors = append(p.errors, parseError("missing semicolon (;) at end of statement", startline))
+	if !next || fail {
+		p.errors = append(p.errors, parseError("missing semicolon (;) at end of statement", p.lex.position()))
 	}
 
 	return !fail
@@ -419,7 +1459,7 @@ func (p parser) expectEndline(startline int) bool {
 // expectRightCurly checks if the next token is a rightCurly '}' and then consumes it.
 // If not, it creates a parse error and consumes all the tokens until the next right curly.
 // Returns false upon reaching tokenEOF or tokenError.
-func (p parser) expectRightCurly(leftline int) bool {
+func (p *parser) expectRightCurly(leftline int) bool {
 	t := p.next()
 	for t.typ != tokenRightCurly && t.typ != tokenEOF && t.typ != tokenError {
 		t = p.next()
@@ -430,19 +1470,19 @@ func (p parser) expectRightCurly(leftline int) bool {
 	case tokenEOF:
 		fail = true
 	case tokenError:
-		p.errors = append(p.errors, lexError(t, p.lex.lineNumber()))
+		p.errors = append(p.errors, p.lexError(t))
 		fail = true
 	}
 
 	if fail {
 		errStr := fmt.Sprintf("missing closing curly brace (}) at end of block starting on line %d", leftline)
-		p.errors = append(p.errors, parseError(errStr, p.lex.lineNumber()))
+		p.errors = append(p.errors, parseError(errStr, p.lex.position()))
 	}
 
 	return !fail
 }
 
-func (p parser) next() token {
+func (p *parser) next() token {
 	// The dcparser is not performance critical, so we can spend some extra time while parsing
 	// each token to make sure we're not trying to read past an EOF.
 	if p.foundEOF {
@@ -457,10 +1497,59 @@ func (p parser) next() token {
 	}
 }
 
-func (p parser) peek() token {
+func (p *parser) peek() token {
 	return p.lex.peekToken()
 }
 
+// checkLimits is called by the lexer for each token received, and returns an error to stop the
+// parser if the context is done or the token exceeds a limit of the ParseOptions.
+func (p *parser) checkLimits(t token) error {
+	if p.ctx != nil && p.ctx.Err() != nil {
+		return p.ctx.Err()
+	}
+
+	p.numTokens++
+	switch t.typ {
+	case tokenLeftCurly, tokenLeftParen, tokenLeftSquare:
+		p.depth++
+	case tokenRightCurly, tokenRightParen, tokenRightSquare:
+		p.depth--
+	}
+
+	opts := p.opts
+	var msg string
+	switch {
+	case opts.MaxTokens > 0 && p.numTokens > opts.MaxTokens:
+		msg = fmt.Sprintf("more than %d tokens", opts.MaxTokens)
+	case opts.MaxDepth > 0 && p.depth > opts.MaxDepth:
+		msg = fmt.Sprintf("nested deeper than %d levels", opts.MaxDepth)
+	case opts.MaxIdentifierLength > 0 && t.typ == tokenIdentifier && len(t.val) > opts.MaxIdentifierLength:
+		msg = fmt.Sprintf("identifier longer than %d bytes", opts.MaxIdentifierLength)
+	case opts.MaxClasses > 0 && len(p.dcf.Classes) > opts.MaxClasses:
+		msg = fmt.Sprintf("more than %d classes and structs", opts.MaxClasses)
+	case opts.MaxFields > 0 && len(p.dcf.Fields) > opts.MaxFields:
+		msg = fmt.Sprintf("more than %d fields", opts.MaxFields)
+	case opts.MaxErrors > 0 && len(p.errors) >= opts.MaxErrors:
+		msg = fmt.Sprintf("too many errors (%d)", opts.MaxErrors)
+	default:
+		return nil
+	}
+	return limitError(msg, p.lex.positionOf(t.pos))
+}
+
+// tooManyErrors is called for the errors found once the file is parsed.  If there are more than
+// ParseOptions.MaxErrors errors, the first errors in the source are kept, followed by a limit
+// error, and tooManyErrors returns true to stop the parser.
+func (p *parser) tooManyErrors() bool {
+	max := p.opts.MaxErrors
+	if max <= 0 || len(p.errors) <= max {
+		return false
+	}
+	ErrorList(p.errors).Sort()
+	p.errors = append(p.errors[:max], limitError(fmt.Sprintf("too many errors (%d)", max), p.lex.position()))
+	return true
+}
+
 func typeFromToken(t token) DataType {
 	switch t.typ {
 	case tokenInt8:
@@ -494,16 +1583,65 @@ func typeFromToken(t token) DataType {
 	}
 }
 
-func lexError(t token, line int) Error {
-	return Error(fmt.Sprintf("lex error(line: %d): %s", line, t.String()))
+// lexError returns the error of a tokenError read by the parser: a lex error, an error reading
+// the input, or the error which stopped the parser.
+func (p *parser) lexError(t token) error {
+	switch err := p.lex.readErr.(type) {
+	case nil:
+	case inputSizeError:
+		return limitError(err.Error(), p.lex.positionOf(t.pos))
+	default:
+		return &PosError{Pos: p.lex.positionOf(t.pos), Kind: "read error", Msg: err.Error()}
+	}
+	if p.lex.err != nil {
+		return p.lex.err
+	}
+	return lexError(t, p.lex.position())
 }
 
-func parseError(msg string, line int) Error {
-	return Error(fmt.Sprintf("parse error(line: %d): %s", line, msg))
+func lexError(t token, pos Pos) *PosError {
+	return &PosError{Pos: pos, Kind: "lex error", Msg: t.String()}
 }
 
-func definitionError(identifier string, typ tokenType, firstUsed int) Error {
-	errStr := fmt.Sprintf("used %s '%s', but '%s' was never defined", tokenName[typ], identifier, identifier)
-	errStr += fmt.Sprintf("\n\t first used on line: %d", firstUsed)
-	return Error("definition error: " + errStr)
+func parseError(msg string, pos Pos) *PosError {
+	return &PosError{Pos: pos, Kind: "parse error", Msg: msg}
+}
+
+func keywordError(field Field, err error) *PosError {
+	return &PosError{Pos: field.Pos(), Kind: "keyword error",
+		Msg: "field '" + field.Name() + "': " + err.Error()}
+}
+
+func semanticError(msg string, pos Pos) *PosError {
+	return &PosError{Pos: pos, Kind: "semantic error", Msg: msg}
+}
+
+func semanticWarning(msg string, pos Pos) *PosError {
+	return &PosError{Pos: pos, Kind: "semantic warning", Msg: msg}
+}
+
+func limitError(msg string, pos Pos) *PosError {
+	return &PosError{Pos: pos, Kind: "limit error", Msg: msg}
+}
+
+// definitionError returns an error for an identifier which was never defined, suggesting the
+// similar names of the declarations it may refer to.
+func (p *parser) definitionError(identifier string, typ tokenType, firstUsed Pos) *PosError {
+	msg := fmt.Sprintf("used %s '%s', but '%s' was never defined", tokenName[typ], identifier, identifier)
+	var candidates []string
+	switch typ {
+	case tokenKeyword:
+		var fieldNames []string
+		for _, field := range p.expectingKeyword[identifier] {
+			fieldNames = append(fieldNames, "'"+field.Name()+"'")
+		}
+		msg += "; used by fields: " + strings.Join(fieldNames, ", ")
+		candidates = p.dcf.keywordNames()
+	case tokenStruct:
+		candidates = p.dcf.typeNames()
+	case tokenDClass:
+		candidates = p.dcf.classNames()
+	}
+	return withSuggestions(&PosError{Pos: firstUsed, Kind: "definition error", Msg: msg},
+		suggest(identifier, candidates))
 }
@@ -0,0 +1,407 @@
+package dclass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// mustParse parses the input as a dclass File, failing the test on any error.
+func mustParse(t *testing.T, input string) *File {
+	dcf, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error parsing %q:\n%v", input, err)
+	}
+	return dcf
+}
+
+// structParam returns the named parameter of the named struct.
+func structParam(t *testing.T, dcf *File, structName, paramName string) *Parameter {
+	typ, ok := dcf.ClassByName[structName]
+	if !ok {
+		t.Fatalf("struct %s was not defined", structName)
+	}
+	for _, f := range typ.Fields() {
+		if f.Name() == paramName {
+			return f.(*Parameter)
+		}
+	}
+	t.Fatalf("struct %s has no parameter %s", structName, paramName)
+	return nil
+}
+
+const caseEnums = `
+const uint8 MaxLevel = 99;
+const Base = 0x10;
+enum Mode : uint8 {
+	Idle = 1,
+	Walk,
+	Run = Base,
+};
+struct Avatar {
+	Mode mode = Walk;
+	uint8(1-MaxLevel) level = MaxLevel;
+	Mode[] history;
+	int16 / 10 heading = -4.5;
+};
+`
+
+func TestParseEnums(t *testing.T) {
+	dcf := mustParse(t, caseEnums)
+
+	if c := dcf.ConstByName["MaxLevel"]; c == nil || c.DataType != Uint8Type || c.Value.RatString() != "99" {
+		t.Errorf("const MaxLevel: got %+v", c)
+	}
+
+	e := dcf.EnumByName["Mode"]
+	if e == nil {
+		t.Fatalf("enum Mode was not defined")
+	}
+	expected := []string{"Idle=1", "Walk=2", "Run=16"}
+	if len(e.Values()) != len(expected) {
+		t.Fatalf("enum Mode: got %d values, expected %d", len(e.Values()), len(expected))
+	}
+	for i, v := range e.Values() {
+		if got := v.Name + "=" + v.Value.String(); got != expected[i] {
+			t.Errorf("enum Mode value %d: got %s, expected %s", i, got, expected[i])
+		}
+	}
+
+	mode := structParam(t, dcf, "Avatar", "mode")
+	if mode.Enum() != e || mode.DataType() != Uint8Type {
+		t.Errorf("parameter mode: got enum %v and type %v", mode.Enum(), mode.DataType())
+	}
+	if got := mode.FormatData(mode.DefaultValue(), false); got != "Walk" {
+		t.Errorf("mode default: got %q, expected %q", got, "Walk")
+	}
+	if got := mode.FormatData(*bytes.NewBuffer([]byte{7}), false); got != "7" {
+		t.Errorf("mode with unnamed value: got %q, expected %q", got, "7")
+	}
+	if _, err := mode.ParseString("3"); err == nil {
+		t.Errorf("mode: expected an error packing a value which is not in the enum")
+	}
+
+	history := structParam(t, dcf, "Avatar", "history")
+	data, err := history.ParseString("{Idle, Run, 2}")
+	if err != nil {
+		t.Fatalf("history: unexpected error %v", err)
+	}
+	if got := history.FormatData(data, false); got != "{Idle, Run, Walk}" {
+		t.Errorf("history: got %q, expected %q", got, "{Idle, Run, Walk}")
+	}
+
+	level := structParam(t, dcf, "Avatar", "level")
+	if got := level.FormatData(level.DefaultValue(), false); got != "99" {
+		t.Errorf("level default: got %q, expected %q", got, "99")
+	}
+	if _, err := level.ParseString("100"); err == nil {
+		t.Errorf("level: expected an error packing a value outside of the range")
+	}
+
+	heading := structParam(t, dcf, "Avatar", "heading")
+	if val := heading.DefaultValue(); !bytes.Equal(val.Bytes(), []byte{0xd3, 0xff}) {
+		t.Errorf("heading default: got %v, expected packed value -45", val.Bytes())
+	}
+	if got := heading.FormatData(heading.DefaultValue(), false); got != "-4.5" {
+		t.Errorf("heading default: got %q, expected %q", got, "-4.5")
+	}
+}
+
+func TestFormatStruct(t *testing.T) {
+	dcf := mustParse(t, `
+		struct Pos { int32 x; int32 y; };
+		struct Path { Pos[2] ends; string name = "home"; };
+	`)
+
+	ends := structParam(t, dcf, "Path", "ends")
+	data, err := ends.ParseString("{{x = 1, y = -2}, {3, 4}}")
+	if err != nil {
+		t.Fatalf("ends: unexpected error %v", err)
+	}
+	if got := ends.FormatData(data, true); got != "{{x = 1, y = -2}, {x = 3, y = 4}}" {
+		t.Errorf("ends: got %q", got)
+	}
+
+	name := structParam(t, dcf, "Path", "name")
+	if got := name.FormatData(name.DefaultValue(), false); got != `"home"` {
+		t.Errorf("name default: got %q", got)
+	}
+}
+
+var parseErrorTests = []struct {
+	name  string
+	input string
+}{
+	{"duplicate enum", "enum A { X }; enum A { Y };"},
+	{"duplicate enum value", "enum A { X, X };"},
+	{"enum overflow", "enum A : uint8 { X = 255, Y };"},
+	{"enum float type", "enum A : float64 { X };"},
+	{"unknown constant", "struct A { uint8(0-Max) x; };"},
+	{"const overflow", "const int8 Big = 200;"},
+	{"default not in enum", "enum A { X = 1 }; struct B { A a = 2; };"},
+	{"default out of range", "struct B { uint8(0-10) a = 11; };"},
+	{"undefined struct", "struct B { A a; };"},
+	{"transform divide by zero", "struct S { int16 / 0 x; };"},
+	{"transform multiply by zero", "struct S { int16 * 0 x = 0; };"},
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range parseErrorTests {
+		if _, err := Parse(strings.NewReader(test.input)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestTransformOverflow(t *testing.T) {
+	dcf, err := Parse(strings.NewReader("struct S { int16 / 10 x; float64 / 10 y; float64 / 10 (0-1) z; };"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := dcf.ClassByName["S"].(*Struct)
+
+	huge := strings.Repeat("9", 400) + ".0"
+	if _, err := s.FieldByName("x").ParseString(huge); err == nil ||
+		!strings.Contains(err.Error(), "cannot be transformed to an integer") {
+		t.Errorf("packed an infinite value into an integer, error %v", err)
+	}
+	if _, err := s.FieldByName("y").ParseString(huge); err == nil ||
+		!strings.Contains(err.Error(), "overflows float64") {
+		t.Errorf("packed an infinite value into a float, error %v", err)
+	}
+	if _, err := s.FieldByName("z").ParseString(huge); err == nil ||
+		!strings.Contains(err.Error(), "overflows float64") {
+		t.Errorf("packed an infinite value into a ranged float, error %v", err)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	_, err := Parse(strings.NewReader("dclass B : Missing {};\nstruct A {\n\tuint8 x = foo;\n};\n"))
+	list, ok := err.(ErrorList)
+	if !ok || len(list) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+
+	expected := []string{"1:12 definition error", "3:12 parse error"}
+	for i, err := range list {
+		posErr, ok := err.(*PosError)
+		if !ok {
+			t.Fatalf("expected a *PosError, got %T", err)
+		}
+		if got := posErr.Pos.String() + " " + posErr.Kind; got != expected[i] {
+			t.Errorf("error %d: expected %q, got %q (%v)", i, expected[i], got, err)
+		}
+	}
+}
+
+func TestDeclarationRecovery(t *testing.T) {
+	// the body and ';' of each invalid declaration are skipped, leaving a single error
+	cases := []struct {
+		input string
+		line  int
+	}{
+		{"struct A {};\nstruct A { uint8 x; };\ndclass B {};\n", 2},
+		{"dclass A {};\ndclass A { setX(uint8 x); };\ndclass B {};\n", 2},
+		{"struct { uint8 x; };\ndclass B {};\n", 1},
+		{"dclass { setX(uint8 x); };\ndclass B {};\n", 1},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.input))
+		list, ok := err.(ErrorList)
+		if !ok || len(list) != 1 || list[0].(*PosError).Pos.Line != c.line {
+			t.Errorf("%q: expected a single error on line %d, got %v", c.input, c.line, err)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	src := "struct Point { int16 x; int16 y; };\ndclass Avatar { setPos(Point p = {1, 2}); };\n"
+	cases := []struct {
+		opts ParseOptions
+		err  string
+	}{
+		{ParseOptions{MaxInputSize: 40}, "limit error(line: 2): input is larger than 40 bytes"},
+		{ParseOptions{MaxTokens: 20}, "limit error(line: 2): more than 20 tokens"},
+		{ParseOptions{MaxDepth: 2}, "limit error(line: 2): nested deeper than 2 levels"},
+		{ParseOptions{MaxIdentifierLength: 5}, "limit error(line: 2): identifier longer than 5 bytes"},
+		{ParseOptions{MaxClasses: 1}, "limit error(line: 2): more than 1 classes and structs"},
+		{ParseOptions{MaxFields: 2}, "limit error(line: 2): more than 2 fields"},
+	}
+	for _, c := range cases {
+		_, errs := ParsePartial(strings.NewReader(src), c.opts)
+		if len(errs) != 1 || errs[0].Error() != c.err {
+			t.Errorf("ParsePartial with %+v: errors %v, expected %q", c.opts, errs, c.err)
+		}
+	}
+
+	limits := ParseOptions{MaxInputSize: len(src), MaxTokens: 40, MaxDepth: 3, MaxIdentifierLength: 6,
+		MaxClasses: 2, MaxFields: 3}
+	if _, err := ParseWithOptions(strings.NewReader(src), limits); err != nil {
+		t.Errorf("ParseWithOptions within limits: %v", err)
+	}
+
+	// Parsing stops after MaxErrors errors, without checking the file
+	bad := "dclass A { setX(Missing x); };\n1\n2\n3\n"
+	_, errs := ParsePartial(strings.NewReader(bad), ParseOptions{MaxErrors: 2})
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	expected := `parse error(line: 2): expected a declaration but got '"1"'
+parse error(line: 3): expected a declaration but got '"2"'
+limit error(line: 4): too many errors (2)`
+	if strings.Join(out, "\n") != expected {
+		t.Errorf("ParsePartial with MaxErrors 2: errors:\n%s\nexpected:\n%s", strings.Join(out, "\n"), expected)
+	}
+
+	// The errors found once the file is parsed are limited as well
+	undefined := "struct S { A a; B b; C c; D d; E e; };\n"
+	_, errs = ParsePartial(strings.NewReader(undefined), ParseOptions{MaxErrors: 2})
+	out = nil
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	expected = `definition error(line: 1): used struct 'A', but 'A' was never defined
+definition error(line: 1): used struct 'B', but 'B' was never defined
+limit error(line: 1): too many errors (2)`
+	if strings.Join(out, "\n") != expected {
+		t.Errorf("ParsePartial with MaxErrors 2: errors:\n%s\nexpected:\n%s", strings.Join(out, "\n"), expected)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	// The source is read once, in any number of pieces
+	expected := mustParse(t, caseKeywords)
+	dcf, err := Parse(iotest.OneByteReader(strings.NewReader(caseKeywords)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var printed, expectedPrinted bytes.Buffer
+	Print(&printed, dcf)
+	Print(&expectedPrinted, expected)
+	if printed.String() != expectedPrinted.String() {
+		t.Errorf("parsed one byte at a time as:\n%s\nexpected:\n%s", printed.String(), expectedPrinted.String())
+	}
+
+	// The digest of the source is recorded for caches
+	var cache bytes.Buffer
+	if err := dcf.WriteCache(&cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := LoadCache(&cache, []byte(caseKeywords)); !ok || err != nil {
+		t.Errorf("cache of a streamed file was not used: %v", err)
+	}
+
+	// Errors reading the source are parse errors
+	src := io.MultiReader(strings.NewReader("keyword p2p;\nstruct A {"), iotest.ErrReader(errors.New("disk failure")))
+	_, errs := ParsePartial(src, ParseOptions{})
+	if len(errs) != 1 || errs[0].Error() != "read error(line: 2): disk failure" {
+		t.Errorf("ParsePartial with a failing reader: errors %v", errs)
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dcf, err := ParseContext(ctx, strings.NewReader(caseKeywords), ParseOptions{})
+	if dcf != nil || err != context.Canceled {
+		t.Errorf("ParseContext with a canceled context: %v, %v", dcf, err)
+	}
+	if _, errs := ParsePartialContext(ctx, strings.NewReader(caseKeywords), ParseOptions{}); len(errs) != 1 ||
+		errs[0] != context.Canceled {
+		t.Errorf("ParsePartialContext with a canceled context: errors %v", errs)
+	}
+
+	if _, err := ParseContext(context.Background(), strings.NewReader(caseKeywords), ParseOptions{}); err != nil {
+		t.Errorf("ParseContext: %v", err)
+	}
+}
+
+const caseKeywords = `
+keyword p2p;
+struct Pos { int32 x; int32 y; };
+dclass DistributedAvatar {
+	string name required db;
+	setPos(Pos pos, int8 visible = true) broadcast ram p2p;
+	setHp(uint16 hp) clsend airecv;
+	setState : setPos, setHp;
+};
+`
+
+func TestParseClass(t *testing.T) {
+	dcf := mustParse(t, caseKeywords)
+
+	typ, ok := dcf.ClassByName["DistributedAvatar"].(*Class)
+	if !ok {
+		t.Fatalf("dclass DistributedAvatar was not defined")
+	}
+	if n := len(typ.Fields()); n != 4 {
+		t.Fatalf("DistributedAvatar: got %d fields, expected 4", n)
+	}
+
+	name := typ.FieldByName("name")
+	if !name.IsRequired() || !name.IsDb() || name.IsRam() {
+		t.Errorf("name: got keywords %v", name.Keywords())
+	}
+
+	setPos := typ.FieldByName("setPos").(*AtomicField)
+	if !setPos.HasKeyword("p2p") || !setPos.IsBroadcast() {
+		t.Errorf("setPos: got keywords %v", setPos.Keywords())
+	}
+	if got := setPos.FormatData(setPos.DefaultValue(), true); got != "(pos = {x = 0, y = 0}, visible = 1)" {
+		t.Errorf("setPos default: got %q", got)
+	}
+
+	setState := typ.FieldByName("setState").(*MolecularField)
+	data, err := setState.ParseString("(({1, 2}, 0), (hp = 30))")
+	if err != nil {
+		t.Fatalf("setState: unexpected error %v", err)
+	}
+	if got := setState.FormatData(data, false); got != "(({1, 2}, 0), (30))" {
+		t.Errorf("setState: got %q", got)
+	}
+	if !setState.IsClsend() || !setState.IsBroadcast() {
+		t.Errorf("setState: got keywords %v", setState.Keywords())
+	}
+}
+
+func TestUndeclaredKeyword(t *testing.T) {
+	_, err := Parse(strings.NewReader("dclass A { setX(uint8) brodcast; };"))
+	if err == nil || !strings.Contains(err.Error(), "'brodcast'") ||
+		!strings.Contains(err.Error(), "used by fields: 'setX'") {
+		t.Errorf("expected an undefined keyword error for setX, got %v", err)
+	}
+
+	// keywords may be declared after they are used
+	mustParse(t, "dclass A { setX(uint8) p2p; }; keyword p2p;")
+}
+
+func TestKeywordSemantics(t *testing.T) {
+	RegisterKeyword("clsend", KeywordSemantics{
+		Validate: func(field Field) error {
+			if !field.IsAirecv() {
+				return Error("clsend requires airecv")
+			}
+			return nil
+		},
+		Flags: 1,
+	})
+	RegisterKeyword("p2p", KeywordSemantics{Flags: 2})
+	defer UnregisterKeyword("clsend")
+	defer UnregisterKeyword("p2p")
+
+	dcf := mustParse(t, caseKeywords)
+	setPos := dcf.ClassByName["DistributedAvatar"].(*Class).FieldByName("setPos")
+	if flags := KeywordFlags(setPos); flags != 2 {
+		t.Errorf("setPos: got flags %d, expected 2", flags)
+	}
+
+	_, err := Parse(strings.NewReader("dclass A { setX(uint8) clsend; };"))
+	if err == nil || !strings.Contains(err.Error(), "clsend requires airecv") {
+		t.Errorf("expected a keyword validation error, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package dclass
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Print writes the canonical dclass source of the File to w.  The printed file declares the
+// file's keywords, constants, enums, structs, and classes in that order, with structs and classes
+// in their original order so that parsing the printed file produces a File with the same Hash.
+//
+// Constants used in ranges, transforms, and default values are printed as their values.
+func Print(w io.Writer, f *File) error {
+	var out bytes.Buffer
+	printFile(&out, f)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// printFile writes each section of the file to out, separated by blank lines.
+func printFile(out *bytes.Buffer, f *File) {
+	section := func() {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+	}
+
+	if keywords := f.Keywords(); len(keywords) > 0 {
+		section()
+		for _, keyword := range keywords {
+			out.WriteString("keyword " + keyword + ";\n")
+		}
+	}
+
+	if len(f.Consts) > 0 {
+		section()
+		for _, c := range f.Consts {
+			out.WriteString("const ")
+			if c.DataType != InvalidType {
+				out.WriteString(c.DataType.String() + " ")
+			}
+			out.WriteString(c.Name + " = " + formatNumber(c.Value) + ";\n")
+		}
+	}
+
+	for _, e := range f.Enums {
+		section()
+		out.WriteString("enum " + e.name + " : " + e.dataType.String() + " {\n")
+		for _, v := range e.values {
+			out.WriteString("\t" + v.Name + " = " + v.Value.String() + ",\n")
+		}
+		out.WriteString("};\n")
+	}
+
+	for _, typ := range f.Classes {
+		section()
+		printType(out, typ)
+	}
+}
+
+// printType writes the declaration of a struct or class to out.
+func printType(out *bytes.Buffer, typ Type) {
+	if c, ok := typ.(*Class); ok {
+		out.WriteString("dclass " + c.name)
+		for i, parent := range c.parents {
+			if i == 0 {
+				out.WriteString(" : ")
+			} else {
+				out.WriteString(", ")
+			}
+			out.WriteString(parent.name)
+		}
+	} else {
+		out.WriteString("struct " + typ.Name())
+	}
+
+	if len(typ.Fields()) == 0 {
+		out.WriteString(" {};\n")
+		return
+	}
+
+	out.WriteString(" {\n")
+	for _, field := range typ.Fields() {
+		out.WriteByte('\t')
+		printField(out, field)
+		out.WriteString(";\n")
+	}
+	out.WriteString("};\n")
+}
+
+// fieldString returns the declaration of a field as it would be written in a dclass File.
+func fieldString(f Field) string {
+	var out bytes.Buffer
+	printField(&out, f)
+	return out.String()
+}
+
+// printField writes a field declaration, without the terminating semicolon, to out.
+func printField(out *bytes.Buffer, f Field) {
+	switch field := f.(type) {
+	case *Parameter:
+		printParameter(out, field)
+	case *AtomicField:
+		out.WriteString(field.name + "(")
+		for i, arg := range field.args {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			printParameter(out, arg.(*Parameter))
+		}
+		out.WriteByte(')')
+	case *MolecularField:
+		out.WriteString(field.name + " : ")
+		for i, c := range field.components {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(c.Name())
+		}
+		return // the keywords of a molecular field are those of its components
+	}
+
+	for _, keyword := range f.Keywords() {
+		out.WriteString(" " + keyword)
+	}
+}
+
+// printParameter writes a parameter `type [name] [= default]` to out.
+func printParameter(out *bytes.Buffer, p *Parameter) {
+	out.WriteString(parameterType(p))
+	if p.name != "" {
+		out.WriteString(" " + p.name)
+	}
+	if p.hasDefVal {
+		out.WriteString(" = " + p.FormatData(p.DefaultValue(), true))
+	}
+}
+
+// parameterType returns the type of the parameter as it would be written in a dclass File,
+// including any transform, range and array size.
+func parameterType(p *Parameter) string {
+	typ := p.TypeName()
+	for _, op := range p.Transform {
+		typ += " " + string(op.Operator) + " " + strconv.FormatFloat(op.Operand, 'f', -1, 64)
+	}
+	if min, max, ok := rangeBounds(p.Range); ok {
+		typ += "(" + formatBounds(min, max) + ")"
+	}
+	if p.isArray {
+		if min, max, ok := rangeBounds(p.ArrayRange); ok {
+			typ += "[" + formatBounds(min, max) + "]"
+		} else if p.arraySize > 0 {
+			typ += "[" + strconv.Itoa(p.arraySize) + "]"
+		} else {
+			typ += "[]"
+		}
+	}
+	return typ
+}
+
+// formatBounds formats the bounds of a range `min-max`, or `value` if min and max are equal.
+func formatBounds(min, max *big.Rat) string {
+	if min.Cmp(max) == 0 {
+		return formatNumber(min)
+	}
+	return formatNumber(min) + "-" + formatNumber(max)
+}
+
+// formatFloat formats a float in decimal, without an exponent, which is the only form of float
+// that dclass numeric literals can be written in.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatNumber formats a number as a dclass numeric literal.
+func formatNumber(num *big.Rat) string {
+	if num.IsInt() {
+		return num.Num().String()
+	}
+	f, _ := num.Float64()
+	s := formatFloat(f)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package dclass
+
+import (
+	"bytes"
+	"testing"
+)
+
+const casePrint = `
+keyword p2p;
+const uint8 MaxLevel = 99;
+const Scale = 0.5;
+enum Mode : uint8 {
+	Idle = 1,
+	Walk,
+};
+struct Point {
+	int16 / 10 x = -4.5;
+	int16 * Scale (-100--10) y;
+	float64(0-1.5) z;
+};
+dclass Base {
+	setName(string(1-32) name = "base") required broadcast db;
+	setPath(Point[0-8] path, Mode[2]) p2p ram;
+};
+dclass Child : Base {
+	blob data = "ab";
+	char initial = 'a';
+	float64 small = 0.00001;
+	float64 large = 100000000000000000000000;
+	int32 / 100000 scaled = 0.00002;
+	setAll : setName, setPath;
+};
+`
+
+const expectedPrint = `keyword p2p;
+
+const uint8 MaxLevel = 99;
+const Scale = 0.5;
+
+enum Mode : uint8 {
+	Idle = 1,
+	Walk = 2,
+};
+
+struct Point {
+	int16 / 10 x = -4.5;
+	int16 * 0.5(-100--10) y;
+	float64(0-1.5) z;
+};
+
+dclass Base {
+	setName(string(1-32) name = "base") required broadcast db;
+	setPath(Point[0-8] path, Mode[2]) ram p2p;
+};
+
+dclass Child : Base {
+	blob data = "ab";
+	char initial = 'a';
+	float64 small = 0.00001;
+	float64 large = 100000000000000000000000;
+	int32 / 100000 scaled = 0.00002;
+	setAll : setName, setPath;
+};
+`
+
+func TestPrint(t *testing.T) {
+	dcf := mustParse(t, casePrint)
+
+	var out bytes.Buffer
+	if err := Print(&out, dcf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.String() != expectedPrint {
+		t.Errorf("expected\n%s\ngot\n%s", expectedPrint, out.String())
+	}
+
+	printed := mustParse(t, out.String())
+	if dcf.Hash() != printed.Hash() {
+		t.Errorf("expected hash %d, got %d after printing", dcf.Hash(), printed.Hash())
+	}
+
+	formatted, err := Format(out.Bytes())
+	if err != nil || !bytes.Equal(formatted, out.Bytes()) {
+		t.Errorf("expected canonical source to be unchanged by Format, got\n%s", formatted)
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := mustParse(t, "dclass A {\n\tsetX(uint8 x) ram;\n};")
+	if a.Hash() == 0 || a.Hash() > 0xffffffff {
+		t.Errorf("expected a non-zero 32-bit hash, got %d", a.Hash())
+	}
+
+	same := mustParse(t, "dclass  A{ setX(uint8 x) ram; };")
+	if a.Hash() != same.Hash() {
+		t.Errorf("expected formatting not to affect the hash")
+	}
+
+	for _, input := range []string{
+		"dclass A {\n\tsetX(int8 x) ram;\n};",
+		"dclass A {\n\tsetX(uint8 x) db;\n};",
+		"dclass A {\n\tsetY(uint8 x) ram;\n};",
+		"dclass A {\n\tsetX(uint8(0-10) x) ram;\n};",
+	} {
+		if b := mustParse(t, input); a.Hash() == b.Hash() {
+			t.Errorf("expected %q to change the hash", input)
+		}
+	}
+}
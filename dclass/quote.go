@@ -0,0 +1,93 @@
+package dclass
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// String literals and character constants are quoted like Go literals, and may contain the
+// escape sequences \a \b \f \n \r \t \v \\ \' \", an octal byte \377, a hexadecimal byte \xFF,
+// and the Unicode code points \u00FF and \U000000FF.  In strings, bytes are kept as they are
+// written and code points are encoded as UTF-8, which is how FormatData quotes values.  A char
+// is a single byte, so a character constant must be a single character of U+0000 to U+00FF.
+
+// unquote returns the value of a quoted string literal, decoding its escape sequences.
+func unquote(lit string) (string, error) {
+	var buf []byte
+	s := lit[1 : len(lit)-1]
+	for len(s) > 0 {
+		c, multibyte, tail, err := unquoteNext(s)
+		if err != nil {
+			return "", Error(err.Error() + " in string " + lit)
+		}
+		if multibyte {
+			buf = utf8.AppendRune(buf, c)
+		} else {
+			buf = append(buf, byte(c))
+		}
+		s = tail
+	}
+	return string(buf), nil
+}
+
+// unquoteChar returns the value of a quoted character constant, decoding its escape sequence.
+func unquoteChar(lit string) (byte, error) {
+	s := lit[1 : len(lit)-1]
+	if s == "" {
+		return 0, Error("empty character constant " + lit)
+	}
+	c, multibyte, tail, err := unquoteNext(s)
+	switch {
+	case err != nil:
+		return 0, Error(err.Error() + " in character constant " + lit)
+	case tail != "":
+		return 0, Error("character constant " + lit + " must be a single character")
+	case multibyte && c > 0xFF:
+		return 0, Error(fmt.Sprintf("character constant %s is out of range: %U is not a char in "+
+			"U+0000 to U+00FF", lit, c))
+	}
+	return byte(c), nil
+}
+
+// unquoteNext decodes the first character or escape sequence of s, and returns its value and
+// the remainder of s.  A value which is a code point, rather than a byte, is multibyte.
+func unquoteNext(s string) (c rune, multibyte bool, tail string, err error) {
+	if s[0] != '\\' {
+		r, n := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && n == 1 {
+			return rune(s[0]), false, s[1:], nil
+		}
+		return r, true, s[n:], nil
+	}
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		return rune(s[1]), false, s[2:], nil
+	}
+
+	c, multibyte, tail, err = strconv.UnquoteChar(s, 0)
+	if err != nil {
+		return 0, false, s, Error("invalid escape sequence " + escapeText(s))
+	}
+	return c, multibyte, tail, nil
+}
+
+// escapeText returns the escape sequence at the start of s, for errors.
+func escapeText(s string) string {
+	n := 2
+	if len(s) > 1 {
+		switch s[1] {
+		case 'x':
+			n = 4
+		case 'u':
+			n = 6
+		case 'U':
+			n = 10
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n = 4
+		}
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+	return s[:n]
+}
@@ -0,0 +1,131 @@
+package dclass
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnquote(t *testing.T) {
+	cases := []struct {
+		lit, value, err string
+	}{
+		{`""`, "", ""},
+		{`"abc"`, "abc", ""},
+		{`"a\tb\n\\ \" \'"`, "a\tb\n\\ \" '", ""},
+		{`"\a\b\f\r\v"`, "\a\b\f\r\v", ""},
+		{`"\x41\101\u00e9\U0001F600"`, "AAé😀", ""},
+		{`"\xFF\377"`, "\xff\xff", ""},
+		{`"héllo, 世界"`, "héllo, 世界", ""},
+		{`"a\qb"`, "", `invalid escape sequence \q in string "a\qb"`},
+		{`"\xZZ"`, "", `invalid escape sequence \xZZ in string "\xZZ"`},
+		{`"\uD800"`, "", `invalid escape sequence \uD800 in string "\uD800"`},
+		{`"\U00110000"`, "", `invalid escape sequence \U00110000 in string "\U00110000"`},
+		{`"\8"`, "", `invalid escape sequence \8 in string "\8"`},
+	}
+	for _, c := range cases {
+		value, err := unquote(c.lit)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("unquote(%s): error %v, expected %s", c.lit, err, c.err)
+			}
+		} else if err != nil || value != c.value {
+			t.Errorf("unquote(%s) = %q, %v, expected %q", c.lit, value, err, c.value)
+		}
+	}
+}
+
+func TestUnquoteChar(t *testing.T) {
+	cases := []struct {
+		lit   string
+		value byte
+		err   string
+	}{
+		{`'a'`, 'a', ""},
+		{`'\n'`, '\n', ""},
+		{`'\''`, '\'', ""},
+		{`'"'`, '"', ""},
+		{`'\"'`, '"', ""},
+		{`'\\'`, '\\', ""},
+		{`'\0'`, 0, `invalid escape sequence \0 in character constant '\0'`},
+		{`'\000'`, 0, ""},
+		{`'\xFF'`, 0xFF, ""},
+		{`'\u00FF'`, 0xFF, ""},
+		{`'é'`, 0xE9, ""},
+		{`''`, 0, `empty character constant ''`},
+		{`'ab'`, 0, `character constant 'ab' must be a single character`},
+		{`'\n\n'`, 0, `character constant '\n\n' must be a single character`},
+		{`'\q'`, 0, `invalid escape sequence \q in character constant '\q'`},
+		{`'本'`, 0, `character constant '本' is out of range: U+672C is not a char in U+0000 to U+00FF`},
+		{`'\u0100'`, 0, `character constant '\u0100' is out of range: U+0100 is not a char in U+0000 to U+00FF`},
+	}
+	for _, c := range cases {
+		value, err := unquoteChar(c.lit)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("unquoteChar(%s): error %v, expected %s", c.lit, err, c.err)
+			}
+		} else if err != nil || value != c.value {
+			t.Errorf("unquoteChar(%s) = %#x, %v, expected %#x", c.lit, value, err, c.value)
+		}
+	}
+}
+
+func TestQuotedDefaults(t *testing.T) {
+	dcf, err := Parse(strings.NewReader(`dclass A {
+	string greeting = "hello\n\"world\"";
+	blob data = "\x00\xFF\u00e9";
+	char tab = '\t';
+	char high = '\xFF';
+	char[2] pair = {'\'', '\u00e9'};
+};
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := dcf.ClassByName["A"].(*Class)
+
+	cases := []struct {
+		field  string
+		packed []byte
+		text   string
+	}{
+		{"greeting", []byte("\x0d\x00hello\n\"world\""), `"hello\n\"world\""`},
+		{"data", []byte("\x04\x00\x00\xff\xc3\xa9"), `"\x00\xffé"`},
+		{"tab", []byte{'\t'}, `'\t'`},
+		{"high", []byte{0xff}, `'\xff'`},
+		{"pair", []byte{'\'', 0xe9}, `{'\'', '\xe9'}`},
+	}
+	for _, c := range cases {
+		field := a.FieldByName(c.field)
+		value := field.DefaultValue()
+		if !bytes.Equal(value.Bytes(), c.packed) {
+			t.Errorf("%s has default value %q, expected %q", c.field, value.Bytes(), c.packed)
+		}
+		text := field.FormatData(value, false)
+		if text != c.text {
+			t.Errorf("%s has default value %s, expected %s", c.field, text, c.text)
+		}
+
+		// Formatted values are parsed back to the same data
+		data, err := field.ParseString(text)
+		if err != nil {
+			t.Errorf("ParseString(%s): %v", text, err)
+		} else if !bytes.Equal(data.Bytes(), c.packed) {
+			t.Errorf("ParseString(%s) = %q, expected %q", text, data.Bytes(), c.packed)
+		}
+	}
+
+	_, err = Parse(strings.NewReader("dclass A {\n\tstring s = \"\\q\";\n\tchar c = 'ab';\n};\n"))
+	expected := `parse error(line: 2): invalid escape sequence \q in string "\q"
+parse error(line: 3): character constant 'ab' must be a single character`
+	var errs []string
+	if list, ok := err.(ErrorList); ok {
+		for _, e := range list {
+			errs = append(errs, e.Error())
+		}
+	}
+	if out := strings.Join(errs, "\n"); out != expected {
+		t.Errorf("parsed invalid quotes with errors:\n%s\nexpected:\n%s", out, expected)
+	}
+}
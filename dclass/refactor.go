@@ -0,0 +1,378 @@
+package dclass
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Refactorings edit the syntax trees of dclass files which are loaded in order as a single File,
+// changing only the source of the declarations they affect.  The edited files are parsed to
+// check the result of a refactoring; if they do not parse without errors, the trees are left
+// unchanged and the errors are returned.
+
+// Rename renames a class, struct, or keyword, or a field named as "Type.field", in the syntax
+// trees of the files.  Every use of the name is renamed: the parents of classes and the types of
+// parameters for a class or struct, the keywords of fields for a keyword, and the components of
+// molecular fields which refer to a field.
+//
+// Member names in struct default values, such as `{x = 1}`, are not renamed; renaming a struct
+// field used this way fails.
+func Rename(trees []*SyntaxNode, old, new string) error {
+	dcf, err := parseSyntaxTrees(trees)
+	if err != nil {
+		return err
+	}
+	if !isIdentifier(new) {
+		return Error("cannot rename " + old + " to invalid name '" + new + "'")
+	}
+
+	var tokens []*SyntaxToken
+	name := old
+	if i := strings.IndexByte(old, '.'); i >= 0 {
+		name = old[i+1:]
+		tokens, err = fieldReferences(trees, dcf, old[:i], name)
+	} else if _, ok := dcf.ClassByName[old]; ok {
+		tokens = typeReferences(trees, old)
+	} else if dcf.HasKeyword(old) && dcf.KeywordPos(old).IsValid() && !isDefinedKeyword(old) {
+		tokens = keywordReferences(trees, old)
+	} else if isDefinedKeyword(old) {
+		return Error("cannot rename built-in keyword " + old)
+	} else {
+		return Error("no dclass, struct, or keyword named " + old)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		t.Text = new
+	}
+	if _, err := parseSyntaxTrees(trees); err != nil {
+		for _, t := range tokens {
+			t.Text = name
+		}
+		return err
+	}
+	return nil
+}
+
+// MoveField moves a field named as "Class.field" to a parent of the class, or to its only parent
+// if parent is empty.  The field is added to the end of the parent's declaration, with its
+// comments.  Returns a FieldRenumbered change for each field whose number is changed by the move.
+func MoveField(trees []*SyntaxNode, path, parent string) (ChangeList, error) {
+	dcf, err := parseSyntaxTrees(trees)
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.IndexByte(path, '.')
+	if i < 0 {
+		return nil, Error("field " + path + " must be named as Class.field")
+	}
+	className, fieldName := path[:i], path[i+1:]
+	class, ok := dcf.ClassByName[className].(*Class)
+	if !ok {
+		return nil, Error("no dclass named " + className)
+	}
+	field := class.typeBase.FieldByName(fieldName)
+	if field == nil {
+		return nil, Error("dclass " + className + " does not declare a field " + fieldName)
+	}
+
+	var to *Class
+	for _, p := range class.parents {
+		if p.name == parent || parent == "" && len(class.parents) == 1 {
+			to = p
+		}
+	}
+	switch {
+	case to == nil && parent == "":
+		return nil, Error("dclass " + className + " has " + strconv.Itoa(len(class.parents)) +
+			" parents, the parent to move " + fieldName + " to must be named")
+	case to == nil:
+		return nil, Error(parent + " is not a parent of dclass " + className)
+	case to.FieldByName(fieldName) != nil:
+		return nil, Error("dclass " + to.name + " already has a field " + fieldName)
+	}
+
+	from, dest := findDeclSyntax(trees, className), findDeclSyntax(trees, to.name)
+	node := findFieldSyntax(from, fieldName)
+
+	// Remove the field, and add it before the closing '}' of the parent
+	fromChildren, destChildren := from.Children, dest.Children
+	from.Children = nil
+	for _, child := range fromChildren {
+		if child != node {
+			from.Children = append(from.Children, child)
+		}
+	}
+	end := len(dest.Children) - 1
+	for end > 0 && !isPunctElement(dest.Children[end], "}") {
+		end--
+	}
+	dest.Children = append(append(append([]SyntaxElement(nil), destChildren[:end]...), node), destChildren[end:]...)
+
+	// The field starts on a new line
+	prev := dest.Children[end-1]
+	if n, ok := prev.(*SyntaxNode); ok {
+		tokens := n.Tokens()
+		prev = tokens[len(tokens)-1]
+	}
+	prevTok := prev.(*SyntaxToken)
+	prevTrailing := prevTok.Trailing
+	if !strings.HasSuffix(prevTok.String(), "\n") {
+		prevTok.Trailing = append(prevTok.Trailing[:len(prevTok.Trailing):len(prevTok.Trailing)],
+			Trivia{SpaceTrivia, "\n"})
+	}
+
+	moved, err := parseSyntaxTrees(trees)
+	if err != nil {
+		from.Children, dest.Children, prevTok.Trailing = fromChildren, destChildren, prevTrailing
+		return nil, err
+	}
+
+	var changes ChangeList
+	for _, typ := range dcf.Classes {
+		c, ok := typ.(*Class)
+		if !ok {
+			continue
+		}
+		for _, f := range c.fields {
+			movedField := moved.ClassByName[c.name].(*Class).typeBase.FieldByName(f.Name())
+			if f == field {
+				movedField = moved.ClassByName[to.name].(*Class).typeBase.FieldByName(f.Name())
+			}
+			if f.Number() != movedField.Number() {
+				changes = append(changes, Change{FieldRenumbered, ClientBreaking, c.name + "." + f.Name(),
+					strconv.Itoa(f.Number()), strconv.Itoa(movedField.Number())})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// parseSyntaxTrees parses the source of the trees as a single File.
+func parseSyntaxTrees(trees []*SyntaxNode) (*File, error) {
+	var buf bytes.Buffer
+	for _, tree := range trees {
+		tree.print(&buf)
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return Parse(&buf)
+}
+
+// isIdentifier reports whether s may be used as the name of a declaration.
+func isIdentifier(s string) bool {
+	if s == "" || unicode.IsDigit([]rune(s)[0]) || key[s] != 0 || s == "true" || s == "false" {
+		return false
+	}
+	for _, r := range s {
+		if !isAlphaNumeric(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// findDeclSyntax returns the struct or class declaration named name in the trees.
+func findDeclSyntax(trees []*SyntaxNode, name string) *SyntaxNode {
+	for _, tree := range trees {
+		for _, decl := range tree.Nodes() {
+			if (decl.Kind == StructSyntax || decl.Kind == ClassSyntax) && decl.Name() != nil &&
+				decl.Name().Text == name {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// findFieldSyntax returns the field named name of a struct or class declaration.
+func findFieldSyntax(decl *SyntaxNode, name string) *SyntaxNode {
+	for _, field := range decl.Nodes() {
+		if field.Name() != nil && field.Name().Text == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func isPunctElement(e SyntaxElement, text string) bool {
+	t, ok := e.(*SyntaxToken)
+	return ok && t.isPunct(text)
+}
+
+// childTokens returns the tokens which are children of a node, excluding those of nested nodes.
+func childTokens(n *SyntaxNode) []*SyntaxToken {
+	var tokens []*SyntaxToken
+	for _, child := range n.Children {
+		if t, ok := child.(*SyntaxToken); ok {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// fieldKind returns "atomic", "molecular", or "parameter" for a field declaration.
+func fieldKind(field *SyntaxNode) string {
+	if len(field.Children) > 1 {
+		switch {
+		case isPunctElement(field.Children[1], "("):
+			return "atomic"
+		case isPunctElement(field.Children[1], ":"):
+			return "molecular"
+		}
+	}
+	return "parameter"
+}
+
+// typeReferences returns the name of the struct or class declaration named name, and the uses
+// of the name as a parent or parameter type.
+func typeReferences(trees []*SyntaxNode, name string) []*SyntaxToken {
+	var tokens []*SyntaxToken
+	for _, tree := range trees {
+		tree.Inspect(func(e SyntaxElement) bool {
+			n, ok := e.(*SyntaxNode)
+			if !ok {
+				return false
+			}
+			switch n.Kind {
+			case StructSyntax, ClassSyntax:
+				if n.Name() != nil && n.Name().Text == name {
+					tokens = append(tokens, n.Name())
+				}
+
+				// The parents of a class are listed between ':' and '{'
+				inParents := false
+				for _, t := range childTokens(n) {
+					switch {
+					case t.isPunct(":"):
+						inParents = true
+					case t.isPunct("{"):
+						inParents = false
+					case inParents && t.Kind == IdentToken && t.Text == name:
+						tokens = append(tokens, t)
+					}
+				}
+			case FieldSyntax, ParameterSyntax:
+				if n.Kind == FieldSyntax && fieldKind(n) != "parameter" {
+					break
+				}
+				if t := n.Tokens()[0]; t.Kind == IdentToken && t.Text == name {
+					tokens = append(tokens, t)
+				}
+			}
+			return true
+		})
+	}
+	return tokens
+}
+
+// keywordReferences returns the name of the declaration of a keyword, and its uses by fields.
+func keywordReferences(trees []*SyntaxNode, keyword string) []*SyntaxToken {
+	var tokens []*SyntaxToken
+	for _, tree := range trees {
+		for _, decl := range tree.Nodes() {
+			switch decl.Kind {
+			case KeywordSyntax:
+				if decl.Name() != nil && decl.Name().Text == keyword {
+					tokens = append(tokens, decl.Name())
+				}
+			case ClassSyntax:
+				for _, field := range decl.Nodes() {
+					for _, t := range fieldKeywords(field) {
+						if t.Text == keyword {
+							tokens = append(tokens, t)
+						}
+					}
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+// fieldKeywords returns the keywords of the declaration of a class field.
+func fieldKeywords(field *SyntaxNode) []*SyntaxToken {
+	tokens := childTokens(field)
+	i := 0
+	switch fieldKind(field) {
+	case "molecular":
+		return nil
+	case "atomic":
+		for i < len(tokens) && !tokens[i].isPunct(")") {
+			i++
+		}
+	default:
+		name := field.Name()
+		for i < len(tokens) && tokens[i] != name {
+			i++
+		}
+		if i+1 < len(tokens) && tokens[i+1].isPunct("=") {
+			// Skip the default value: an optionally signed value, or a value in braces
+			i += 2
+			if i < len(tokens) && tokens[i].isOperator() {
+				i++
+			}
+			for depth := 0; i < len(tokens); i++ {
+				if tokens[i].isPunct("{") {
+					depth++
+				} else if tokens[i].isPunct("}") {
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	var keywords []*SyntaxToken
+	for i++; i < len(tokens); i++ {
+		if tokens[i].Kind == IdentToken {
+			keywords = append(keywords, tokens[i])
+		}
+	}
+	return keywords
+}
+
+// fieldReferences returns the name of the declaration of a field of a class or struct, and its
+// uses as a component of molecular fields.
+func fieldReferences(trees []*SyntaxNode, dcf *File, typeName, name string) ([]*SyntaxToken, error) {
+	typ, ok := dcf.ClassByName[typeName]
+	if !ok {
+		return nil, Error("no dclass or struct named " + typeName)
+	}
+	var field Field
+	switch t := typ.(type) {
+	case *Class:
+		field = t.typeBase.FieldByName(name)
+	case *Struct:
+		field = t.typeBase.FieldByName(name)
+	}
+	if field == nil {
+		return nil, Error(typeKind(typ) + " " + typeName + " does not declare a field " + name)
+	}
+
+	tokens := []*SyntaxToken{findFieldSyntax(findDeclSyntax(trees, typeName), name).Name()}
+	for _, typ := range dcf.Classes {
+		if c, ok := typ.(*Class); !ok || c.FieldByName(name) != field {
+			continue
+		}
+		for _, f := range findDeclSyntax(trees, typ.Name()).Nodes() {
+			if fieldKind(f) != "molecular" {
+				continue
+			}
+			for _, t := range childTokens(f)[2:] {
+				if t.Kind == IdentToken && t.Text == name {
+					tokens = append(tokens, t)
+				}
+			}
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,202 @@
+package dclass
+
+import (
+	"strings"
+	"testing"
+)
+
+var caseRefactor = []string{`keyword p2p;
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+dclass Base {
+	setName(string name) broadcast p2p;
+};
+`, `// Children of Base.
+dclass Npc : Base {
+	Point home p2p;
+};
+
+dclass Avatar : Base {
+	// The avatar's location.
+	setPos(Point pos) p2p; // sent often
+	setLoc(Point[] path = {{1, 2}}, uint8 mode) ram p2p;
+	setAll : setName, setPos;
+};`}
+
+// parseRefactorCase returns the syntax trees of caseRefactor.
+func parseRefactorCase(t *testing.T) []*SyntaxNode {
+	var trees []*SyntaxNode
+	for _, src := range caseRefactor {
+		tree, err := ParseSyntax(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree)
+	}
+	return trees
+}
+
+func TestRename(t *testing.T) {
+	cases := []struct {
+		old, new string
+		expected []string // the lines of the files which differ from caseRefactor
+	}{
+		{"Point", "Vec", []string{
+			"struct Vec {",
+			"\tVec home p2p;",
+			"\tsetPos(Vec pos) p2p; // sent often",
+			"\tsetLoc(Vec[] path = {{1, 2}}, uint8 mode) ram p2p;",
+		}},
+		{"Base", "Entity", []string{
+			"dclass Entity {",
+			"dclass Npc : Entity {",
+			"dclass Avatar : Entity {",
+		}},
+		{"p2p", "peer", []string{
+			"keyword peer;",
+			"\tsetName(string name) broadcast peer;",
+			"\tPoint home peer;",
+			"\tsetPos(Point pos) peer; // sent often",
+			"\tsetLoc(Point[] path = {{1, 2}}, uint8 mode) ram peer;",
+		}},
+		{"Base.setName", "setLabel", []string{
+			"\tsetLabel(string name) broadcast p2p;",
+			"\tsetAll : setLabel, setPos;",
+		}},
+		{"Point.x", "px", []string{
+			"\tint16 px;",
+		}},
+	}
+	for _, c := range cases {
+		trees := parseRefactorCase(t)
+		if err := Rename(trees, c.old, c.new); err != nil {
+			t.Errorf("Rename(%s, %s): %v", c.old, c.new, err)
+			continue
+		}
+
+		var changed []string
+		for i, tree := range trees {
+			old, new := strings.Split(caseRefactor[i], "\n"), strings.Split(tree.String(), "\n")
+			if len(old) != len(new) {
+				t.Fatalf("Rename(%s, %s) changed the lines of file %d:\n%s", c.old, c.new, i, tree)
+			}
+			for j := range old {
+				if old[j] != new[j] {
+					changed = append(changed, new[j])
+				}
+			}
+		}
+		if strings.Join(changed, "\n") != strings.Join(c.expected, "\n") {
+			t.Errorf("Rename(%s, %s) changed:\n%s\nexpected:\n%s", c.old, c.new,
+				strings.Join(changed, "\n"), strings.Join(c.expected, "\n"))
+		}
+	}
+}
+
+func TestRenameErrors(t *testing.T) {
+	cases := []struct {
+		old, new string
+		err      string
+	}{
+		{"Point", "uint8", "cannot rename Point to invalid name 'uint8'"},
+		{"Point", "9a", "cannot rename Point to invalid name '9a'"},
+		{"Missing", "Other", "no dclass, struct, or keyword named Missing"},
+		{"ram", "memory", "cannot rename built-in keyword ram"},
+		{"Avatar.setName", "setLabel", "dclass Avatar does not declare a field setName"},
+		{"Point", "Base", "parse error(line: 8): cannot define dclass Base, Base already defined above"},
+		{"Avatar.setPos", "setLoc", "semantic error(line: 19): field 'setLoc' (19:2) is already declared by dclass Avatar at 18:2"},
+	}
+	for _, c := range cases {
+		trees := parseRefactorCase(t)
+		err := Rename(trees, c.old, c.new)
+		if list, ok := err.(ErrorList); ok {
+			err = list[0]
+		}
+		if err == nil || err.Error() != c.err {
+			t.Errorf("Rename(%s, %s): error %v, expected %q", c.old, c.new, err, c.err)
+		}
+		for i, tree := range trees {
+			if tree.String() != caseRefactor[i] {
+				t.Errorf("failed Rename(%s, %s) changed file %d:\n%s", c.old, c.new, i, tree)
+			}
+		}
+	}
+}
+
+func TestMoveField(t *testing.T) {
+	trees := parseRefactorCase(t)
+	changes, err := MoveField(trees, "Avatar.setPos", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`keyword p2p;
+
+struct Point {
+	int16 x;
+	int16 y;
+};
+
+dclass Base {
+	setName(string name) broadcast p2p;
+	// The avatar's location.
+	setPos(Point pos) p2p; // sent often
+};
+`, `// Children of Base.
+dclass Npc : Base {
+	Point home p2p;
+};
+
+dclass Avatar : Base {
+	setLoc(Point[] path = {{1, 2}}, uint8 mode) ram p2p;
+	setAll : setName, setPos;
+};`}
+	for i, tree := range trees {
+		if out := tree.String(); out != expected[i] {
+			t.Errorf("file %d after move:\n%s\nexpected:\n%s", i, out, expected[i])
+		}
+	}
+
+	var report []string
+	for _, c := range changes {
+		report = append(report, c.String())
+	}
+	expectedReport := `client-breaking: Npc.home: field-renumbered: 3 -> 4
+client-breaking: Avatar.setPos: field-renumbered: 4 -> 3`
+	if out := strings.Join(report, "\n"); out != expectedReport {
+		t.Errorf("move reported:\n%s\nexpected:\n%s", out, expectedReport)
+	}
+}
+
+func TestMoveFieldErrors(t *testing.T) {
+	cases := []struct {
+		path, parent string
+		err          string
+	}{
+		{"setPos", "", "field setPos must be named as Class.field"},
+		{"Point.x", "", "no dclass named Point"},
+		{"Avatar.setName", "", "dclass Avatar does not declare a field setName"},
+		{"Avatar.setPos", "Npc", "Npc is not a parent of dclass Avatar"},
+		{"Base.setName", "", "dclass Base has 0 parents, the parent to move setName to must be named"},
+		{"Avatar.setAll", "", "parse error(line: 10): molecular field 'setAll' has unknown component 'setPos'"},
+	}
+	for _, c := range cases {
+		trees := parseRefactorCase(t)
+		_, err := MoveField(trees, c.path, c.parent)
+		if list, ok := err.(ErrorList); ok {
+			err = list[0]
+		}
+		if err == nil || err.Error() != c.err {
+			t.Errorf("MoveField(%s, %s): error %v, expected %q", c.path, c.parent, err, c.err)
+		}
+		for i, tree := range trees {
+			if tree.String() != caseRefactor[i] {
+				t.Errorf("failed MoveField(%s, %s) changed file %d:\n%s", c.path, c.parent, i, tree)
+			}
+		}
+	}
+}
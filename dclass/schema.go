@@ -0,0 +1,554 @@
+package dclass
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// The schema of a dclass File can be exported as a JSON document for tools which are not written
+// in Go, and imported again to produce an equivalent File with the same Hash.  The document is the
+// Schema type encoded with encoding/json:
+//
+//	{
+//		"format": "dclass-schema",
+//		"version": 1,
+//		"hash": 1234567,
+//		"keywords": ["p2p"],
+//		"consts": [{"name": "MaxLevel", "type": "uint8", "value": 99}],
+//		"enums": [{"name": "Mode", "type": "uint8", "values": [{"name": "Idle", "value": 1}]}],
+//		"types": [{"kind": "dclass", "name": "Avatar", "id": 0, "parents": [], "fields": [...]}]
+//	}
+//
+// Fields hold their field number as "id", and their declaration in dclass syntax.  Parameters
+// describe their type, transform, range, array size, and default value, where the default value is
+// written in the format of FieldToJSON.  Large numbers are written as JSON numbers with all of
+// their digits, which some JSON decoders can only read exactly as strings or big numbers.
+//
+// The document's version is incremented whenever a change would prevent older tools from
+// reading it correctly; adding new members does not change the version.
+
+// SchemaFormat and SchemaVersion identify the format and version of schema documents.
+const (
+	SchemaFormat  = "dclass-schema"
+	SchemaVersion = 1
+)
+
+// A Schema is the JSON document describing a dclass File.
+type Schema struct {
+	Format   string        `json:"format"`   // always SchemaFormat
+	Version  int           `json:"version"`  // the version of the document, SchemaVersion
+	Hash     uint64        `json:"hash"`     // the hash of the File
+	Keywords []string      `json:"keywords"` // the keywords declared by the File
+	Consts   []SchemaConst `json:"consts"`
+	Enums    []SchemaEnum  `json:"enums"`
+	Types    []SchemaType  `json:"types"` // the structs and classes of the File, in order of their ID
+
+	// the comments and declarations of keywords, by keyword
+	KeywordDocs      map[string]string    `json:"keywordDocs,omitempty"`
+	KeywordPositions map[string]SchemaPos `json:"keywordPositions,omitempty"`
+}
+
+// A SchemaConst describes a constant declared by a dclass File.
+type SchemaConst struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type,omitempty"` // the declared type, or empty if untyped
+	Value json.Number `json:"value"`
+	Pos   SchemaPos   `json:"pos"`
+}
+
+// A SchemaEnum describes an enum declared by a dclass File.
+type SchemaEnum struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"` // the underlying integer type
+	Values []SchemaEnumValue `json:"values"`
+	Pos    SchemaPos         `json:"pos"`
+}
+
+// A SchemaEnumValue describes a single value of an enum.
+type SchemaEnumValue struct {
+	Name  string      `json:"name"`
+	Value json.Number `json:"value"`
+}
+
+// A SchemaType describes a struct or class of a dclass File.
+type SchemaType struct {
+	Kind    string        `json:"kind"` // either "struct" or "dclass"
+	Name    string        `json:"name"`
+	ID      int           `json:"id"`                // the index of the type within the File
+	Parents []string      `json:"parents,omitempty"` // the parents of a dclass, in order
+	Fields  []SchemaField `json:"fields"`            // the fields declared by the type, in order
+	Pos     SchemaPos     `json:"pos"`
+	Doc     string        `json:"doc,omitempty"` // the comments attached to the declaration
+}
+
+// A SchemaField describes a field of a class or struct, or an argument of an atomic field.
+type SchemaField struct {
+	Kind        string    `json:"kind"` // one of "parameter", "atomic", or "molecular"
+	Name        string    `json:"name"` // the name of the field, which may be empty for arguments
+	ID          int       `json:"id"`   // the field number, or -1 for arguments
+	Declaration string    `json:"declaration"`
+	Keywords    []string  `json:"keywords,omitempty"`
+	Pos         SchemaPos `json:"pos"`
+	Doc         string    `json:"doc,omitempty"` // the comments attached to the declaration
+
+	*SchemaParameter               // the type of a parameter
+	Args             []SchemaField `json:"args,omitempty"`       // the arguments of an atomic field
+	Components       []string      `json:"components,omitempty"` // the components of a molecular field
+}
+
+// A SchemaParameter describes the type and default value of a parameter.
+type SchemaParameter struct {
+	Type      string              `json:"type"`     // the name of the DataType, struct, or enum
+	DataType  string              `json:"dataType"` // the DataType, or the underlying type of an enum
+	Transform []SchemaTransformOp `json:"transform,omitempty"`
+	Range     *SchemaRange        `json:"range,omitempty"`
+	Array     *SchemaArray        `json:"array,omitempty"`   // present if the parameter is an array
+	Default   json.RawMessage     `json:"default,omitempty"` // the default value, if specified
+}
+
+// A SchemaTransformOp describes a single operation of a parameter's transform.
+type SchemaTransformOp struct {
+	Operator string  `json:"operator"` // one of "+", "-", "*", "/", or "%"
+	Operand  float64 `json:"operand"`
+}
+
+// A SchemaRange describes the inclusive bounds of a range.
+type SchemaRange struct {
+	Min json.Number `json:"min"`
+	Max json.Number `json:"max"`
+}
+
+// A SchemaArray describes the size of an array parameter: Size for fixed-size arrays, or an
+// optional Range for variable-length arrays.
+type SchemaArray struct {
+	Size  int          `json:"size,omitempty"`
+	Range *SchemaRange `json:"range,omitempty"`
+}
+
+// A SchemaPos describes the position of a declaration in the source of the File.  The line is 0
+// if the position is not known.
+type SchemaPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// WriteSchema writes the schema of the File to w as an indented JSON document.
+func WriteSchema(w io.Writer, f *File) error {
+	b, err := json.MarshalIndent(ExportSchema(f), "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// ReadSchema reads a JSON schema document from r and returns the File it describes.
+func ReadSchema(r io.Reader) (*File, error) {
+	var s Schema
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, Error("invalid schema document: " + err.Error())
+	}
+	return ImportSchema(&s)
+}
+
+// ExportSchema returns the schema of the File.
+func ExportSchema(f *File) *Schema {
+	s := &Schema{
+		Format:   SchemaFormat,
+		Version:  SchemaVersion,
+		Hash:     f.Hash(),
+		Keywords: append([]string{}, f.Keywords()...),
+		Consts:   []SchemaConst{},
+		Enums:    []SchemaEnum{},
+		Types:    []SchemaType{},
+	}
+	for keyword, decl := range f.keywordDecls {
+		if decl.pos.IsValid() {
+			if s.KeywordPositions == nil {
+				s.KeywordPositions = make(map[string]SchemaPos)
+			}
+			s.KeywordPositions[keyword] = schemaPos(decl.pos)
+		}
+		if decl.doc != "" {
+			if s.KeywordDocs == nil {
+				s.KeywordDocs = make(map[string]string)
+			}
+			s.KeywordDocs[keyword] = decl.doc
+		}
+	}
+
+	for _, c := range f.Consts {
+		sc := SchemaConst{Name: c.Name, Value: json.Number(formatNumber(c.Value)), Pos: schemaPos(c.pos)}
+		if c.DataType != InvalidType {
+			sc.Type = c.DataType.String()
+		}
+		s.Consts = append(s.Consts, sc)
+	}
+
+	for _, e := range f.Enums {
+		se := SchemaEnum{Name: e.name, Type: e.dataType.String(), Values: []SchemaEnumValue{},
+			Pos: schemaPos(e.pos)}
+		for _, v := range e.values {
+			se.Values = append(se.Values, SchemaEnumValue{v.Name, json.Number(v.Value.String())})
+		}
+		s.Enums = append(s.Enums, se)
+	}
+
+	for _, typ := range f.Classes {
+		st := SchemaType{Kind: "struct", Name: typ.Name(), Fields: []SchemaField{}}
+		switch t := typ.(type) {
+		case *Class:
+			st.Kind, st.ID, st.Pos, st.Doc = "dclass", t.index, schemaPos(t.pos), t.doc
+			st.Parents = []string{}
+			for _, parent := range t.parents {
+				st.Parents = append(st.Parents, parent.name)
+			}
+		case *Struct:
+			st.ID, st.Pos, st.Doc = t.index, schemaPos(t.pos), t.doc
+		}
+		for _, field := range typ.Fields() {
+			st.Fields = append(st.Fields, exportField(field))
+		}
+		s.Types = append(s.Types, st)
+	}
+	return s
+}
+
+// exportField returns the schema of a field or argument.
+func exportField(f Field) SchemaField {
+	sf := SchemaField{
+		Name:        f.Name(),
+		ID:          f.Number(),
+		Declaration: fieldString(f),
+		Keywords:    f.Keywords(),
+		Pos:         schemaPos(f.Pos()),
+		Doc:         f.Doc(),
+	}
+
+	switch field := f.(type) {
+	case *Parameter:
+		sf.Kind = "parameter"
+		sf.SchemaParameter = exportParameter(field)
+	case *AtomicField:
+		sf.Kind = "atomic"
+		sf.Args = []SchemaField{}
+		for _, arg := range field.args {
+			sf.Args = append(sf.Args, exportField(arg))
+		}
+	case *MolecularField:
+		sf.Kind = "molecular"
+		sf.Keywords = nil // the keywords of a molecular field are those of its components
+		for _, c := range field.components {
+			sf.Components = append(sf.Components, c.Name())
+		}
+	}
+	return sf
+}
+
+// exportParameter returns the schema of the type and default value of a parameter.
+func exportParameter(p *Parameter) *SchemaParameter {
+	sp := &SchemaParameter{
+		Type:     p.TypeName(),
+		DataType: p.dataType.String(),
+		Range:    schemaRange(p.Range),
+	}
+	for _, op := range p.Transform {
+		sp.Transform = append(sp.Transform, SchemaTransformOp{string(op.Operator), op.Operand})
+	}
+	if p.isArray {
+		sp.Array = &SchemaArray{Size: p.arraySize, Range: schemaRange(p.ArrayRange)}
+	}
+	if p.hasDefVal {
+		if js, err := FieldToJSON(p, p.defVal.Bytes()); err == nil {
+			sp.Default = js
+		}
+	}
+	return sp
+}
+
+func schemaPos(pos Pos) SchemaPos {
+	return SchemaPos{pos.Line, pos.Column}
+}
+
+func schemaRange(rng Range) *SchemaRange {
+	min, max, ok := rangeBounds(rng)
+	if !ok {
+		return nil
+	}
+	return &SchemaRange{json.Number(formatNumber(min)), json.Number(formatNumber(max))}
+}
+
+// ImportSchema returns the File described by a schema.  The File is checked as if it had been
+// parsed with the default ParseOptions, and its Hash must match the hash recorded by the schema.
+func ImportSchema(s *Schema) (*File, error) {
+	if s.Format != SchemaFormat {
+		return nil, Error("unknown schema format '" + s.Format + "', expected " + SchemaFormat)
+	}
+	if s.Version < 1 || s.Version > SchemaVersion {
+		return nil, Error("unsupported schema version " + strconv.Itoa(s.Version) + ", expected " +
+			strconv.Itoa(SchemaVersion) + " or older")
+	}
+
+	im := importer{dcf: NewFile()}
+	if err := im.importFile(s); err != nil {
+		return nil, err
+	}
+	errs, warnings := im.dcf.Check(ParseOptions{})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	im.dcf.Warnings = warnings
+	im.dcf.Warnings.Sort()
+	if hash := im.dcf.Hash(); s.Hash != 0 && hash != s.Hash {
+		return nil, Error("imported schema has hash " + strconv.FormatUint(hash, 10) + ", expected " +
+			strconv.FormatUint(s.Hash, 10))
+	}
+	return im.dcf, nil
+}
+
+// an importer builds a File from a schema.
+type importer struct {
+	dcf      *File
+	defaults []func() error // packs the default values, once all types are complete
+}
+
+func (im *importer) importFile(s *Schema) error {
+	dcf := im.dcf
+	for _, keyword := range s.Keywords {
+		dcf.AddKeyword(keyword)
+		if doc := s.KeywordDocs[keyword]; doc != "" {
+			dcf.keywordDecl(keyword).doc = doc
+		}
+		if pos, ok := s.KeywordPositions[keyword]; ok {
+			dcf.keywordDecl(keyword).pos = Pos{pos.Line, pos.Column}
+		}
+	}
+
+	for _, c := range s.Consts {
+		typ := InvalidType
+		if c.Type != "" {
+			if typ = dataTypeByName(c.Type); typ == InvalidType {
+				return Error("const " + c.Name + " has unknown type '" + c.Type + "'")
+			}
+		}
+		value, ok := new(big.Rat).SetString(string(c.Value))
+		if !ok {
+			return Error("const " + c.Name + " has invalid value '" + string(c.Value) + "'")
+		}
+		dcf.AddConst(c.Name, typ, value).pos = Pos{c.Pos.Line, c.Pos.Column}
+	}
+
+	for _, se := range s.Enums {
+		e := dcf.AddEnum(se.Name, dataTypeByName(se.Type))
+		if e == nil {
+			return Error("enum " + se.Name + " has invalid type '" + se.Type + "'")
+		}
+		e.pos = Pos{se.Pos.Line, se.Pos.Column}
+		for _, v := range se.Values {
+			n, ok := new(big.Int).SetString(string(v.Value), 10)
+			if !ok {
+				return Error("value " + v.Name + " of enum " + se.Name + " is not an integer")
+			}
+			if err := e.AddValue(v.Name, n); err != nil {
+				return err
+			}
+		}
+	}
+
+	// declare every type first, so that parameters may use structs declared later in the file
+	for i, st := range s.Types {
+		if st.ID != i {
+			return Error(st.Kind + " " + st.Name + " has id " + strconv.Itoa(st.ID) + ", expected " + strconv.Itoa(i))
+		}
+		if _, ok := dcf.ClassByName[st.Name]; ok {
+			return Error("type " + st.Name + " is declared more than once")
+		}
+		switch st.Kind {
+		case "struct":
+			t := dcf.AddType(st.Name, "struct").(*Struct)
+			t.pos, t.doc = Pos{st.Pos.Line, st.Pos.Column}, st.Doc
+		case "dclass":
+			t := dcf.AddType(st.Name, "class").(*Class)
+			t.pos, t.doc = Pos{st.Pos.Line, st.Pos.Column}, st.Doc
+		default:
+			return Error("type " + st.Name + " has unknown kind '" + st.Kind + "'")
+		}
+	}
+
+	for _, st := range s.Types {
+		typ := dcf.ClassByName[st.Name]
+		if c, ok := typ.(*Class); ok {
+			for _, name := range st.Parents {
+				parent, ok := dcf.ClassByName[name].(*Class)
+				if !ok {
+					return Error("dclass " + st.Name + " has undeclared parent '" + name + "'")
+				}
+				c.AddParent(parent)
+			}
+		} else if len(st.Parents) > 0 {
+			return Error("struct " + st.Name + " cannot have parents")
+		}
+
+		for _, sf := range st.Fields {
+			if err := im.importField(typ, sf); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, pack := range im.defaults {
+		if err := pack(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importField adds the field described by the schema to the struct, class, or atomic field obj.
+func (im *importer) importField(obj fieldAdder, sf SchemaField) error {
+	field := obj.AddField(sf.Name, sf.Kind)
+	if field == nil {
+		return Error("cannot add " + sf.Kind + " field '" + sf.Name + "' here")
+	}
+	if sf.ID != field.Number() {
+		return Error("field '" + sf.Name + "' has id " + strconv.Itoa(sf.ID) + ", expected " +
+			strconv.Itoa(field.Number()))
+	}
+	pos := Pos{sf.Pos.Line, sf.Pos.Column}
+
+	switch f := field.(type) {
+	case *Parameter:
+		f.pos, f.doc = pos, sf.Doc
+		if sf.SchemaParameter == nil {
+			return Error("parameter '" + sf.Name + "' has no type")
+		}
+		if err := im.importParameter(f, sf.SchemaParameter); err != nil {
+			return err
+		}
+	case *AtomicField:
+		f.pos, f.doc = pos, sf.Doc
+		for _, arg := range sf.Args {
+			if err := im.importField(f, arg); err != nil {
+				return err
+			}
+		}
+	case *MolecularField:
+		f.pos, f.doc = pos, sf.Doc
+		c, ok := obj.(*Class)
+		if !ok {
+			return Error("molecular field '" + sf.Name + "' must belong to a dclass")
+		}
+		for _, name := range sf.Components {
+			component := c.FieldByName(name)
+			if component == nil || !f.AddComponent(component) {
+				return Error("molecular field '" + sf.Name + "' has invalid component '" + name + "'")
+			}
+		}
+		return nil
+	}
+
+	for _, keyword := range sf.Keywords {
+		if !im.dcf.HasKeyword(keyword) && !isDefinedKeyword(keyword) {
+			return Error("field '" + sf.Name + "' has undeclared keyword '" + keyword + "'")
+		}
+		field.AddKeyword(keyword)
+	}
+	if errs := validateKeywords(field); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// importParameter sets the type of the parameter from its schema.
+func (im *importer) importParameter(p *Parameter, sp *SchemaParameter) error {
+	if s, ok := im.dcf.ClassByName[sp.Type].(*Struct); ok {
+		p.dataType, p.typeName, p.structType = StructType, sp.Type, s
+	} else if e, ok := im.dcf.EnumByName[sp.Type]; ok {
+		p.dataType, p.typeName, p.enum = e.dataType, sp.Type, e
+	} else if p.dataType = dataTypeByName(sp.Type); p.dataType == InvalidType || p.dataType == StructType {
+		return Error("parameter '" + p.name + "' has unknown type '" + sp.Type + "'")
+	}
+
+	for _, op := range sp.Transform {
+		if len(op.Operator) != 1 || !isTransformOperator(op.Operator[0]) {
+			return Error("parameter '" + p.name + "' has unknown transform operator '" + op.Operator + "'")
+		}
+		if op.Operand == 0 && (op.Operator == "/" || op.Operator == "%") {
+			return Error("transform of parameter '" + p.name + "' cannot divide by zero")
+		} else if op.Operand == 0 && op.Operator == "*" {
+			return Error("transform of parameter '" + p.name + "' cannot multiply by zero")
+		}
+		p.Transform = append(p.Transform, TransformOp{op.Operator[0], op.Operand})
+	}
+
+	if sp.Range != nil {
+		min, max, err := sp.Range.bounds()
+		if err == nil {
+			p.Range, err = newRange(p.dataType, min, max)
+		}
+		if err != nil {
+			return Error("parameter '" + p.name + "' has invalid range: " + err.Error())
+		}
+	}
+
+	if sp.Array != nil {
+		p.isArray = true
+		switch {
+		case sp.Array.Size > 0:
+			p.arraySize = sp.Array.Size
+		case sp.Array.Range != nil:
+			min, max, err := sp.Array.Range.bounds()
+			var rng Range
+			if err == nil {
+				rng, err = newRange(Int16Type, min, max)
+			}
+			if err != nil || min.Sign() < 0 {
+				return Error("parameter '" + p.name + "' has invalid array size range")
+			}
+			p.ArrayRange = RangeArray{rng.(RangeInt16)}
+		}
+	}
+
+	if len(sp.Default) > 0 {
+		im.defaults = append(im.defaults, func() error {
+			data, err := JSONToField(p, sp.Default)
+			if err != nil {
+				return Error("invalid default value of parameter '" + p.name + "': " + err.Error())
+			}
+			p.defVal.Write(data)
+			p.hasDefVal = true
+			return nil
+		})
+	}
+	return nil
+}
+
+func (r *SchemaRange) bounds() (min, max *big.Rat, err error) {
+	min, okMin := new(big.Rat).SetString(string(r.Min))
+	max, okMax := new(big.Rat).SetString(string(r.Max))
+	if !okMin || !okMax {
+		return nil, nil, Error("bounds must be numbers")
+	}
+	return min, max, nil
+}
+
+// dataTypeByName returns the DataType with the given name, or InvalidType if there is none.
+func dataTypeByName(name string) DataType {
+	for typ, typName := range dataTypeName {
+		if typName == name {
+			return typ
+		}
+	}
+	return InvalidType
+}
+
+// isTransformOperator returns whether c is the operator of a TransformOp.
+func isTransformOperator(c byte) bool {
+	switch c {
+	case '+', '-', '*', '/', '%':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const caseSchema = `
+keyword p2p;
+keyword audit;
+const uint8 MaxLevel = 99;
+const Scale = 0.5;
+enum Mode : uint64 {
+	Idle = 1,
+	Huge = 18446744073709551615,
+};
+struct Point {
+	int16 / 10 x = -4.5;
+	int16 * Scale (-100--10) y;
+	float64(0-1.5) z;
+	Node[0-2] nodes;
+};
+struct Node {
+	string(0-8) name = "node";
+	blob data = "ab";
+	Node[] children;
+};
+dclass Base {
+	setName(string(1-32) name = "base") required broadcast db;
+	setPath(Point[0-8] path, Mode[2] = {Idle, Huge}) p2p ram;
+};
+dclass Other {
+	uint32 % 360 angle audit;
+};
+dclass Child : Base, Other {
+	char initial = 'a';
+	setAll : setName, setPath, angle;
+};
+`
+
+func TestSchema(t *testing.T) {
+	dcf := mustParse(t, caseSchema)
+
+	var buf bytes.Buffer
+	if err := WriteSchema(&buf, dcf); err != nil {
+		t.Fatalf("unexpected error writing schema: %s", err)
+	}
+	js := buf.String()
+
+	imported, err := ReadSchema(strings.NewReader(js))
+	if err != nil {
+		t.Fatalf("unexpected error reading schema: %s\n%s", err, js)
+	}
+	if imported.Hash() != dcf.Hash() {
+		t.Errorf("imported file has hash %d, expected %d", imported.Hash(), dcf.Hash())
+	}
+
+	var printed, expected bytes.Buffer
+	Print(&printed, imported)
+	Print(&expected, dcf)
+	if printed.String() != expected.String() {
+		t.Errorf("imported file printed as:\n%s\nexpected:\n%s", printed.String(), expected.String())
+	}
+
+	checkPositions(t, "imported", imported, dcf)
+
+	// check a few members of the document which tools rely on
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unexpected error decoding schema: %s", err)
+	}
+	if doc["format"] != SchemaFormat || doc["version"] != float64(SchemaVersion) {
+		t.Errorf("schema has format %v version %v", doc["format"], doc["version"])
+	}
+	if !strings.Contains(strings.Join(strings.Fields(js), ""), `"default":["Idle","Huge"]`) {
+		t.Errorf("schema does not contain the default value of setPath")
+	}
+	for _, s := range []string{
+		`"value": 18446744073709551615`,
+		`"parents": [
+				"Base",
+				"Other"
+			]`,
+		`"default": "YWI="`,
+		`"declaration": "setAll : setName, setPath, angle"`,
+	} {
+		if !strings.Contains(js, s) {
+			t.Errorf("schema does not contain %s:\n%s", s, js)
+		}
+	}
+}
+
+// checkPositions checks that the declarations of a file which was imported or loaded from a
+// cache are at the same positions as those of the parsed file.
+func checkPositions(t *testing.T, name string, dcf, expected *File) {
+	t.Helper()
+	for _, keyword := range expected.Keywords() {
+		if pos := dcf.KeywordPos(keyword); pos != expected.KeywordPos(keyword) {
+			t.Errorf("%s keyword %s is at %s, expected %s", name, keyword, pos, expected.KeywordPos(keyword))
+		}
+	}
+	for i, c := range expected.Consts {
+		if dcf.Consts[i].Name != c.Name || dcf.Consts[i].Pos() != c.Pos() {
+			t.Errorf("%s const %d is %s at %s, expected %s at %s", name, i, dcf.Consts[i].Name,
+				dcf.Consts[i].Pos(), c.Name, c.Pos())
+		}
+	}
+	for i, e := range expected.Enums {
+		if dcf.Enums[i].Name() != e.Name() || dcf.Enums[i].Pos() != e.Pos() {
+			t.Errorf("%s enum %d is %s at %s, expected %s at %s", name, i, dcf.Enums[i].Name(),
+				dcf.Enums[i].Pos(), e.Name(), e.Pos())
+		}
+	}
+	for i, field := range expected.Fields {
+		if dcf.Fields[i].Name() != field.Name() || dcf.Fields[i].Pos() != field.Pos() {
+			t.Errorf("%s field %d is %s at %s, expected %s at %s", name, i, dcf.Fields[i].Name(),
+				dcf.Fields[i].Pos(), field.Name(), field.Pos())
+		}
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	dcf := mustParse(t, caseSchema)
+
+	tests := []struct {
+		modify   func(s *Schema)
+		expected string
+	}{
+		{func(s *Schema) { s.Version = 2 }, "unsupported schema version 2"},
+		{func(s *Schema) { s.Format = "other" }, "unknown schema format 'other'"},
+		{func(s *Schema) { s.Hash++ }, "imported schema has hash"},
+		{func(s *Schema) { s.Types[0].Fields[0].Type = "Missing" }, "unknown type 'Missing'"},
+		{func(s *Schema) { s.Types[0].Fields[0].ID = 7 }, "has id 7, expected 0"},
+		{func(s *Schema) { s.Types[3].Parents = []string{"Point"} }, "undeclared parent 'Point'"},
+		{func(s *Schema) { s.Types[2].Fields[1].Keywords = []string{"missing"} }, "undeclared keyword 'missing'"},
+		{func(s *Schema) { s.Types[1].Fields[0].Default = json.RawMessage(`"much too long"`) }, "invalid default value"},
+		{func(s *Schema) { s.Types[4].Fields[1].Components = []string{"setName", "nothing"} }, "invalid component 'nothing'"},
+		{func(s *Schema) { s.Types[0].Fields[1].Transform[0].Operand = 0 }, "cannot multiply by zero"},
+		{func(s *Schema) { s.Types[0].Fields[3].Array.Range = nil; s.Types[1].Fields[2].Array = nil },
+			"contains itself by value"},
+	}
+
+	for _, test := range tests {
+		s := ExportSchema(dcf)
+		test.modify(s)
+		if _, err := ImportSchema(s); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("unexpected error importing schema: %v, expected %s", err, test.expected)
+		}
+	}
+}
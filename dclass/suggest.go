@@ -0,0 +1,158 @@
+package dclass
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of similar names suggested for an unknown name.
+const maxSuggestions = 3
+
+// suggest returns the candidates which are similar enough to name to be a likely misspelling
+// of it, ordered by similarity.  Names are compared ignoring case, so that differences of case
+// are always suggested, and other names may differ by an edit for every three characters, or by
+// one edit for names of two to five characters.  A one-character name is only similar to names
+// which differ by case, since any other name of one character is a single edit away.
+func suggest(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	maxDistance := len(name) / 3
+	if maxDistance < 1 && len(name) > 1 {
+		maxDistance = 1
+	}
+
+	var found []suggestion
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if c == name || seen[c] {
+			continue
+		}
+		seen[c] = true
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d <= maxDistance {
+			found = append(found, suggestion{c, d})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		return a.distance < b.distance || a.distance == b.distance && a.name < b.name
+	})
+
+	var names []string
+	for i := 0; i < len(found) && i < maxSuggestions; i++ {
+		names = append(names, found[i].name)
+	}
+	return names
+}
+
+// editDistance returns the optimal string alignment distance between a and b, the number of
+// runes which must be inserted, deleted, or substituted, or pairs of adjacent runes which must
+// be transposed, to change a into b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows i-2, i-1, and i of the distances between prefixes of s and t
+	prev2, prev, row := make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev2, prev, row = prev, row, prev2
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			row[j] = min3(prev[j-1]+cost, prev[j]+1, row[j-1]+1)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && prev2[j-2]+1 < row[j] {
+				row[j] = prev2[j-2] + 1
+			}
+		}
+	}
+	return row[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// withSuggestions records the names suggested for an unknown name in the error, and adds them
+// to its message as `did you mean 'a' or 'b'?`.
+func withSuggestions(err *PosError, suggestions []string) *PosError {
+	if len(suggestions) == 0 {
+		return err
+	}
+	err.Suggestions = suggestions
+
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "'" + s + "'"
+	}
+	alternatives := quoted[0]
+	switch n := len(quoted); {
+	case n == 2:
+		alternatives = quoted[0] + " or " + quoted[1]
+	case n > 2:
+		alternatives = strings.Join(quoted[:n-1], ", ") + ", or " + quoted[n-1]
+	}
+	err.Msg += ", did you mean " + alternatives + "?"
+	return err
+}
+
+// typeNames returns the names a parameter's type may be: the built-in data types, and the
+// structs and enums of the File.
+func (f *File) typeNames() []string {
+	var names []string
+	for name, typ := range key {
+		if typ > tokenTypeDelim {
+			names = append(names, name)
+		}
+	}
+	for _, typ := range f.Classes {
+		if _, ok := typ.(*Struct); ok {
+			names = append(names, typ.Name())
+		}
+	}
+	for _, e := range f.Enums {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// classNames returns the names of the classes of the File.
+func (f *File) classNames() []string {
+	var names []string
+	for _, typ := range f.Classes {
+		if _, ok := typ.(*Class); ok {
+			names = append(names, typ.Name())
+		}
+	}
+	return names
+}
+
+// keywordNames returns the keywords declared by the File, and the built-in keywords.
+func (f *File) keywordNames() []string {
+	return append(f.Keywords(), definedKeywords...)
+}
+
+// valueNames returns the names of the constants of the File, and the values of an enum if it is
+// not nil.
+func (f *File) valueNames(enum *Enum) []string {
+	var names []string
+	for _, c := range f.Consts {
+		names = append(names, c.Name)
+	}
+	if enum != nil {
+		for _, v := range enum.Values() {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
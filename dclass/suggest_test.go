@@ -0,0 +1,77 @@
+package dclass
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"PosHpr", "PosHPR", 2},
+		{"uint8", "unit8", 1},
+		{"ca", "abc", 3},
+		{"brodcast", "broadcast", 1},
+		{"héllo", "hello", 1},
+	}
+	for _, c := range cases {
+		if d := editDistance(c.a, c.b); d != c.distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, d, c.distance)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	cases := []struct {
+		src         string
+		err         string
+		suggestions []string
+	}{
+		{"struct PosHPR { int16 x; };\ndclass A { setPos(PosHpr p); };\n",
+			"definition error(line: 2): used struct 'PosHpr', but 'PosHpr' was never defined, did you mean 'PosHPR'?",
+			[]string{"PosHPR"}},
+		{"dclass A { setX(unit8 x); };\n",
+			"definition error(line: 1): used struct 'unit8', but 'unit8' was never defined, did you mean 'uint8'?",
+			[]string{"uint8"}},
+		{"dclass Avatar {};\ndclass B : Avatr {};\n",
+			"definition error(line: 2): used dclass 'Avatr', but 'Avatr' was never defined, did you mean 'Avatar'?",
+			[]string{"Avatar"}},
+		{"keyword p2p;\ndclass A { setX(uint8 x) brodcast p2; };\n",
+			"definition error(line: 2): used keyword 'brodcast', but 'brodcast' was never defined; used by fields: 'setX', did you mean 'broadcast'?",
+			[]string{"broadcast"}},
+		{"dclass A { setAB(uint8 a); setAC(uint8 a); setAD(uint8 a); setAE(uint8 a); setAll : setAF; };\n",
+			"parse error(line: 1): molecular field 'setAll' has unknown component 'setAF', did you mean 'setAB', 'setAC', or 'setAD'?",
+			[]string{"setAB", "setAC", "setAD"}},
+		{"enum Mode : uint8 { Idle, Walk };\nconst uint8 Max = 4;\ndclass A { Mode m = Wlak; uint8 n = Mx; };\n",
+			"parse error(line: 3): 'Wlak' is not a value of enum Mode or a constant, did you mean 'Walk'?",
+			[]string{"Walk"}},
+		{"struct S { A a; };\n",
+			"definition error(line: 1): used struct 'A', but 'A' was never defined",
+			nil},
+		{"struct s { uint8 x; };\nstruct T { S a; };\n",
+			"definition error(line: 2): used struct 'S', but 'S' was never defined, did you mean 's'?",
+			[]string{"s"}},
+		{"dclass A { setX(Unrelated x); };\n",
+			"definition error(line: 1): used struct 'Unrelated', but 'Unrelated' was never defined",
+			nil},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.src))
+		if err == nil {
+			t.Errorf("Parse(%q) did not fail", c.src)
+			continue
+		}
+		posErr := err.(ErrorList)[0].(*PosError)
+		if posErr.Error() != c.err {
+			t.Errorf("Parse(%q): error %q, expected %q", c.src, posErr, c.err)
+		}
+		if strings.Join(posErr.Suggestions, " ") != strings.Join(c.suggestions, " ") {
+			t.Errorf("Parse(%q): suggestions %q, expected %q", c.src, posErr.Suggestions, c.suggestions)
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package dclass
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// A syntax tree is the concrete syntax of a dclass file: every token, and all of the whitespace
+// and comments between them, grouped into nodes for declarations, fields, parameters, and enum
+// values.  Printing a tree reproduces its source exactly, so tools may edit a tree, by changing
+// the text of tokens or the children of nodes, and print it to change only the edited source.
+//
+// A syntax tree only records the structure of the source; it is not checked for semantic errors
+// such as undefined types.  The File declared by a tree is parsed from its printed source.
+
+// A TokenKind classifies the tokens of a syntax tree.
+type TokenKind int
+
+const (
+	EOFToken     TokenKind = iota // end of file, which holds the trivia at the end of the file
+	IdentToken                    // identifier
+	KeywordToken                  // declaration keyword or data type, ie. "dclass" or "uint8"
+	NumberToken                   // number literal
+	StringToken                   // quoted string literal, quotes included
+	CharToken                     // quoted character literal, quotes included
+	BoolToken                     // true or false
+	PunctToken                    // operator or delimiter, ie. '/', '(', or ';'
+)
+
+// A TriviaKind identifies the kind of text between tokens.
+type TriviaKind int
+
+const (
+	SpaceTrivia   TriviaKind = iota // spaces, tabs, and line breaks
+	CommentTrivia                   // line or block comment, delimiters included
+)
+
+// Trivia is text between tokens which is not part of the syntax of a file.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// A SyntaxToken is a token of a syntax tree, with the trivia surrounding it.  The trailing trivia
+// of a token are the trivia after it on the same line, up to and including the line break.  All
+// other trivia before a token are its leading trivia.
+type SyntaxToken struct {
+	Kind     TokenKind
+	Text     string
+	Leading  []Trivia
+	Trailing []Trivia
+
+	pos Pos
+}
+
+// Pos returns the position of the token in the parsed source, or an invalid Pos for tokens
+// which were not parsed.
+func (t *SyntaxToken) Pos() Pos {
+	return t.pos
+}
+
+// String returns the source of the token, with its trivia.
+func (t *SyntaxToken) String() string {
+	var b bytes.Buffer
+	t.print(&b)
+	return b.String()
+}
+
+func (t *SyntaxToken) print(b *bytes.Buffer) {
+	for _, tr := range t.Leading {
+		b.WriteString(tr.Text)
+	}
+	b.WriteString(t.Text)
+	for _, tr := range t.Trailing {
+		b.WriteString(tr.Text)
+	}
+}
+
+func (t *SyntaxToken) isPunct(text string) bool {
+	return t.Kind == PunctToken && t.Text == text
+}
+
+func (t *SyntaxToken) isOperator() bool {
+	return t.Kind == PunctToken && len(t.Text) == 1 && isOperator(rune(t.Text[0]))
+}
+
+// A SyntaxKind identifies the kind of a SyntaxNode.
+type SyntaxKind int
+
+const (
+	FileSyntax      SyntaxKind = iota // dclass file, whose last child is its EOF token
+	KeywordSyntax                     // keyword declaration
+	StructSyntax                      // struct declaration, with a FieldSyntax child for each field
+	ClassSyntax                       // dclass declaration, with a FieldSyntax child for each field
+	EnumSyntax                        // enum declaration, with an EnumValueSyntax child for each value
+	ConstSyntax                       // const declaration
+	FieldSyntax                       // field of a struct or class, with a ParameterSyntax child for each argument
+	ParameterSyntax                   // argument of an atomic field
+	EnumValueSyntax                   // value of an enum
+)
+
+var syntaxKindName = map[SyntaxKind]string{
+	FileSyntax:      "file",
+	KeywordSyntax:   "keyword",
+	StructSyntax:    "struct",
+	ClassSyntax:     "dclass",
+	EnumSyntax:      "enum",
+	ConstSyntax:     "const",
+	FieldSyntax:     "field",
+	ParameterSyntax: "parameter",
+	EnumValueSyntax: "enum value",
+}
+
+func (k SyntaxKind) String() string {
+	return syntaxKindName[k]
+}
+
+// A SyntaxElement is a *SyntaxNode or a *SyntaxToken.
+type SyntaxElement interface {
+	String() string
+	print(b *bytes.Buffer)
+}
+
+// A SyntaxNode is a node of a syntax tree.  Its children are its tokens and nested nodes, in
+// source order.  The separators between the children of a list, such as the ',' between
+// parameters, are children of the node containing the list.
+type SyntaxNode struct {
+	Kind     SyntaxKind
+	Children []SyntaxElement
+}
+
+// ParseSyntax returns the syntax tree of the dclass source read from r.
+// If an error is encountered, a nil value is returned.
+func ParseSyntax(r io.Reader) (*SyntaxNode, error) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return ParseSyntaxNode(FileSyntax, buf.String())
+}
+
+// ParseSyntaxNode returns the node of the given kind parsed from src, such as a field to insert
+// in a class.  The trivia at the end of src are added to the trailing trivia of the node's last
+// token, unless the node is a file.
+func ParseSyntaxNode(kind SyntaxKind, src string) (*SyntaxNode, error) {
+	p, err := newSyntaxParser(src)
+	if err != nil {
+		return nil, ErrorList{err}
+	}
+
+	var n *SyntaxNode
+	switch kind {
+	case FileSyntax:
+		n, err = p.file()
+	case KeywordSyntax, StructSyntax, ClassSyntax, EnumSyntax, ConstSyntax:
+		if n, err = p.declaration(); err == nil && n.Kind != kind {
+			err = parseError("expected a "+kind.String()+" declaration but got "+n.Kind.String(),
+				n.Tokens()[0].pos)
+		}
+	case FieldSyntax:
+		n, err = p.field()
+	case ParameterSyntax:
+		n, err = p.list(ParameterSyntax, "parameter")
+	case EnumValueSyntax:
+		n, err = p.list(EnumValueSyntax, "enum value")
+	default:
+		return nil, Error("unknown syntax kind " + kind.String())
+	}
+	if err == nil && kind != FileSyntax {
+		if eof := p.next(); eof.Kind != EOFToken {
+			err = parseError("unexpected '"+eof.Text+"' after "+kind.String(), eof.pos)
+		} else if tokens := n.Tokens(); len(tokens) == 0 {
+			err = parseError("expected a "+kind.String()+" but got EOF", eof.pos)
+		} else {
+			last := tokens[len(tokens)-1]
+			last.Trailing = append(last.Trailing, eof.Trailing...)
+			last.Trailing = append(last.Trailing, eof.Leading...)
+		}
+	}
+	if err != nil {
+		return nil, ErrorList{err}
+	}
+	return n, nil
+}
+
+// String returns the source of the node.
+func (n *SyntaxNode) String() string {
+	var b bytes.Buffer
+	n.print(&b)
+	return b.String()
+}
+
+func (n *SyntaxNode) print(b *bytes.Buffer) {
+	for _, child := range n.Children {
+		child.print(b)
+	}
+}
+
+// WriteTo writes the source of the node to w.
+func (n *SyntaxNode) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	n.print(&b)
+	return b.WriteTo(w)
+}
+
+// Tokens returns the tokens of the node and its descendants, in source order.
+func (n *SyntaxNode) Tokens() []*SyntaxToken {
+	var tokens []*SyntaxToken
+	n.Inspect(func(e SyntaxElement) bool {
+		if t, ok := e.(*SyntaxToken); ok {
+			tokens = append(tokens, t)
+		}
+		return true
+	})
+	return tokens
+}
+
+// Nodes returns the child nodes of the node, such as the declarations of a file or the fields
+// of a class.
+func (n *SyntaxNode) Nodes() []*SyntaxNode {
+	var nodes []*SyntaxNode
+	for _, child := range n.Children {
+		if node, ok := child.(*SyntaxNode); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Inspect traverses the node and its descendants in source order, calling f for each element.
+// If f returns false for a node, its children are not traversed.
+func (n *SyntaxNode) Inspect(f func(SyntaxElement) bool) {
+	if !f(n) {
+		return
+	}
+	for _, child := range n.Children {
+		if node, ok := child.(*SyntaxNode); ok {
+			node.Inspect(f)
+		} else {
+			f(child)
+		}
+	}
+}
+
+// Name returns the token of the name declared by the node, or nil if the node has no name, such
+// as a file or an unnamed parameter.
+func (n *SyntaxNode) Name() *SyntaxToken {
+	var tokens []*SyntaxToken
+	for _, child := range n.Children {
+		if t, ok := child.(*SyntaxToken); ok {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	switch n.Kind {
+	case KeywordSyntax, StructSyntax, ClassSyntax, EnumSyntax:
+		if len(tokens) > 1 && tokens[1].Kind == IdentToken {
+			return tokens[1]
+		}
+	case ConstSyntax:
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i].isPunct("=") && tokens[i-1].Kind == IdentToken {
+				return tokens[i-1]
+			}
+		}
+	case EnumValueSyntax:
+		if tokens[0].Kind == IdentToken {
+			return tokens[0]
+		}
+	case FieldSyntax:
+		// Atomic and molecular fields start with their name
+		if len(n.Children) > 1 && tokens[0].Kind == IdentToken {
+			if t, ok := n.Children[1].(*SyntaxToken); ok && (t.isPunct("(") || t.isPunct(":")) {
+				return tokens[0]
+			}
+		}
+		fallthrough
+	case ParameterSyntax:
+		// The name of a parameter is the first identifier after its type which is not in a
+		// range or array size, or an operand of a transform
+		depth := 0
+		for i := 1; i < len(tokens); i++ {
+			t := tokens[i]
+			switch {
+			case t.isPunct("(") || t.isPunct("["):
+				depth++
+			case t.isPunct(")") || t.isPunct("]"):
+				depth--
+			case t.isPunct("="):
+				return nil
+			case depth == 0 && t.Kind == IdentToken && !tokens[i-1].isOperator():
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// syntaxParser builds a syntax tree from the tokens of a source.
+type syntaxParser struct {
+	tokens []*SyntaxToken
+	types  []tokenType // the lexer type of each token
+	i      int         // index of the next token
+}
+
+// newSyntaxParser lexes src into tokens with trivia.  Returns a lex error if src could not be
+// lexed.
+func newSyntaxParser(src string) (*syntaxParser, error) {
+	p := &syntaxParser{}
+	l := lex(src)
+	pos := Pos{1, 1}
+	end := 0 // end of the previous token
+
+	// advance moves pos past the text s
+	advance := func(s string) {
+		if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+			pos.Line += strings.Count(s, "\n")
+			pos.Column = len(s) - i
+		} else {
+			pos.Column += len(s)
+		}
+	}
+
+	var trivia []Trivia
+	for {
+		t := <-l.tokens
+		if t.pos > end {
+			trivia = append(trivia, Trivia{SpaceTrivia, src[end:t.pos]})
+			advance(src[end:t.pos])
+			end = t.pos
+		}
+		switch t.typ {
+		case tokenError:
+			return nil, lexError(t, pos)
+		case tokenComment:
+			trivia = append(trivia, Trivia{CommentTrivia, t.val})
+			advance(t.val)
+			end += len(t.val)
+			continue
+		}
+
+		if len(p.tokens) > 0 {
+			prev := p.tokens[len(p.tokens)-1]
+			prev.Trailing, trivia = splitTrailing(trivia)
+		}
+		p.tokens = append(p.tokens, &SyntaxToken{Kind: tokenKind(t.typ), Text: t.val, Leading: trivia, pos: pos})
+		p.types = append(p.types, t.typ)
+		advance(t.val)
+		end += len(t.val)
+		trivia = nil
+
+		if t.typ == tokenEOF {
+			return p, nil
+		}
+	}
+}
+
+// splitTrailing splits the trivia between two tokens into the trailing trivia of the first token,
+// which end with the first line break, and the leading trivia of the second.
+func splitTrailing(trivia []Trivia) (trailing, leading []Trivia) {
+	for i, tr := range trivia {
+		lineBreak := strings.IndexByte(tr.Text, '\n')
+		switch {
+		case lineBreak < 0:
+			continue
+		case tr.Kind == CommentTrivia:
+			// A block comment spanning lines leads the next token
+			return trivia[:i:i], trivia[i:]
+		}
+
+		trailing = append(trivia[:i:i], Trivia{SpaceTrivia, tr.Text[:lineBreak+1]})
+		if rest := tr.Text[lineBreak+1:]; rest != "" {
+			leading = append(leading, Trivia{SpaceTrivia, rest})
+		}
+		return trailing, append(leading, trivia[i+1:]...)
+	}
+	return trivia, nil
+}
+
+func tokenKind(typ tokenType) TokenKind {
+	switch {
+	case typ == tokenEOF:
+		return EOFToken
+	case typ == tokenIdentifier:
+		return IdentToken
+	case typ > tokenKeyDelim:
+		return KeywordToken
+	case typ == tokenNumber:
+		return NumberToken
+	case typ == tokenQuote:
+		return StringToken
+	case typ == tokenRawchar:
+		return CharToken
+	case typ == tokenBool:
+		return BoolToken
+	}
+	return PunctToken
+}
+
+// peek returns the type of the next token.
+func (p *syntaxParser) peek() tokenType {
+	return p.types[p.i]
+}
+
+// next consumes and returns the next token.  The EOF token is never consumed.
+func (p *syntaxParser) next() *SyntaxToken {
+	t := p.tokens[p.i]
+	if p.types[p.i] != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *syntaxParser) unexpected(in string) error {
+	t := p.tokens[p.i]
+	if p.peek() == tokenEOF {
+		return parseError("incomplete "+in+", found EOF", t.pos)
+	}
+	return parseError("unexpected '"+t.Text+"' in "+in, t.pos)
+}
+
+// file parses declarations until EOF.
+func (p *syntaxParser) file() (*SyntaxNode, error) {
+	n := &SyntaxNode{Kind: FileSyntax}
+	for p.peek() != tokenEOF {
+		decl, err := p.declaration()
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, decl)
+	}
+	n.Children = append(n.Children, p.next())
+	return n, nil
+}
+
+// declaration parses a keyword, struct, class, enum, or const declaration.
+func (p *syntaxParser) declaration() (*SyntaxNode, error) {
+	n := &SyntaxNode{}
+	switch p.peek() {
+	case tokenKeyword:
+		n.Kind = KeywordSyntax
+	case tokenStruct:
+		n.Kind = StructSyntax
+	case tokenDClass:
+		n.Kind = ClassSyntax
+	case tokenEnum:
+		n.Kind = EnumSyntax
+	case tokenConst:
+		n.Kind = ConstSyntax
+	default:
+		return nil, p.unexpected("file, expected a declaration")
+	}
+	n.Children = append(n.Children, p.next())
+
+	in := "'" + n.Kind.String() + "' declaration"
+	hasBody := n.Kind == StructSyntax || n.Kind == ClassSyntax || n.Kind == EnumSyntax
+	for {
+		switch p.peek() {
+		case tokenEndline:
+			n.Children = append(n.Children, p.next())
+			return n, nil
+		case tokenLeftCurly:
+			if !hasBody {
+				return nil, p.unexpected(in)
+			}
+			hasBody = false
+			if err := p.body(n); err != nil {
+				return nil, err
+			}
+		case tokenEOF, tokenRightCurly, tokenLeftParen, tokenRightParen, tokenLeftSquare,
+			tokenRightSquare, tokenKeyword, tokenDClass, tokenStruct, tokenEnum, tokenConst:
+			return nil, p.unexpected(in)
+		default:
+			n.Children = append(n.Children, p.next())
+		}
+	}
+}
+
+// body parses the block of a struct, class, or enum declaration into the node n.
+func (p *syntaxParser) body(n *SyntaxNode) error {
+	n.Children = append(n.Children, p.next()) // consume "{"
+	for p.peek() != tokenRightCurly {
+		if n.Kind == EnumSyntax {
+			value, err := p.list(EnumValueSyntax, "enum value")
+			if err != nil {
+				return err
+			}
+			n.Children = append(n.Children, value)
+			if p.peek() != tokenSeperator {
+				break
+			}
+			n.Children = append(n.Children, p.next())
+			continue
+		}
+
+		field, err := p.field()
+		if err != nil {
+			return err
+		}
+		n.Children = append(n.Children, field)
+	}
+	if p.peek() != tokenRightCurly {
+		return p.unexpected("'enum' declaration")
+	}
+	n.Children = append(n.Children, p.next())
+	return nil
+}
+
+// field parses a field of a struct or class, up to and including its ';'.
+func (p *syntaxParser) field() (*SyntaxNode, error) {
+	n := &SyntaxNode{Kind: FieldSyntax}
+	if p.peek() == tokenIdentifier && p.types[p.i+1] == tokenLeftParen {
+		// The arguments of an atomic field are parsed into parameters
+		n.Children = append(n.Children, p.next(), p.next())
+		for p.peek() != tokenRightParen {
+			param, err := p.list(ParameterSyntax, "parameter")
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, param)
+			if p.peek() != tokenSeperator {
+				break
+			}
+			n.Children = append(n.Children, p.next())
+		}
+		if p.peek() != tokenRightParen {
+			return nil, p.unexpected("arguments of field")
+		}
+		n.Children = append(n.Children, p.next())
+	}
+
+	depth := 0
+	for {
+		switch typ := p.peek(); {
+		case typ == tokenEndline && depth == 0:
+			n.Children = append(n.Children, p.next())
+			return n, nil
+		case typ == tokenEOF || typ == tokenRightCurly && depth == 0 || typ > tokenKeyDelim && typ < tokenTypeDelim:
+			return nil, p.unexpected("field")
+		case typ == tokenLeftParen || typ == tokenLeftSquare || typ == tokenLeftCurly:
+			depth++
+		case typ == tokenRightParen || typ == tokenRightSquare || typ == tokenRightCurly:
+			depth--
+		}
+		n.Children = append(n.Children, p.next())
+	}
+}
+
+// list parses an element of a list separated by ',', up to the ',' or the end of the list.
+func (p *syntaxParser) list(kind SyntaxKind, in string) (*SyntaxNode, error) {
+	n := &SyntaxNode{Kind: kind}
+	depth := 0
+	for {
+		switch typ := p.peek(); {
+		case depth == 0 && (typ == tokenSeperator || typ == tokenRightParen || typ == tokenRightCurly):
+			if len(n.Children) == 0 {
+				return nil, p.unexpected(in)
+			}
+			return n, nil
+		case typ == tokenEOF && len(n.Children) > 0 && depth == 0:
+			return n, nil // the end of a list parsed by ParseSyntaxNode
+		case typ == tokenEOF || typ == tokenEndline || typ > tokenKeyDelim && typ < tokenTypeDelim:
+			return nil, p.unexpected(in)
+		case typ == tokenLeftParen || typ == tokenLeftSquare || typ == tokenLeftCurly:
+			depth++
+		case typ == tokenRightParen || typ == tokenRightSquare || typ == tokenRightCurly:
+			depth--
+		}
+		n.Children = append(n.Children, p.next())
+	}
+}
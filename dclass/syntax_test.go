@@ -0,0 +1,207 @@
+package dclass
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const caseSyntax = `// Keywords
+keyword p2p; // trailing
+const uint8 Max = 9;
+enum Mode : uint8 { Idle = 1, Walk, };
+
+/* A point. */
+struct Point {
+	int16 / 10 x = -4.5;
+	int16 * Max (-100--10) y;
+	Mode[] modes;
+};
+
+dclass Base {
+	setName(string(1-32) name = "base", uint8) required broadcast db;
+	setPath(Point[0-8] path, Mode[2] mode) p2p ram;
+};
+dclass Child : Base {
+	blob data = "a;b";
+	char initial = ';';
+	setAll : setName, setPath;
+};
+`
+
+func TestSyntaxRoundTrip(t *testing.T) {
+	cases := []string{
+		caseSyntax,
+		casePrint,
+		"",
+		"\n\n  \n",
+		"keyword a;",
+		"keyword a;\r\n// comment\r\nkeyword b;\r\n",
+		"\t/* block\n * comment */ keyword /* inline */ a ;  \n\n\n",
+		"struct S {\n\n\tuint8 x;  // x\n\n  /* y */\n\tuint8 y;\n} ;\n// end\n",
+	}
+	for _, src := range cases {
+		tree, err := ParseSyntax(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("ParseSyntax(%q): %v", src, err)
+			continue
+		}
+		if out := tree.String(); out != src {
+			t.Errorf("ParseSyntax(%q) printed %q", src, out)
+		}
+
+		// Each token's position is that of its text in src
+		lines := strings.SplitAfter(src, "\n")
+		for _, tok := range tree.Tokens() {
+			pos := tok.Pos()
+			if pos.Line > len(lines) || !strings.HasPrefix(lines[pos.Line-1][pos.Column-1:], tok.Text) {
+				t.Errorf("ParseSyntax(%q): token %q has position %v", src, tok.Text, pos)
+			}
+		}
+	}
+}
+
+// describeSyntax returns a line for each node of the tree, with its kind, name, and source
+// without trivia.
+func describeSyntax(n *SyntaxNode, indent string, lines []string) []string {
+	var text []string
+	for _, tok := range n.Tokens() {
+		if tok.Kind != EOFToken {
+			text = append(text, tok.Text)
+		}
+	}
+	name := ""
+	if tok := n.Name(); tok != nil {
+		name = tok.Text
+	}
+	lines = append(lines, fmt.Sprintf("%s%s %s: %s", indent, n.Kind, name, strings.Join(text, " ")))
+	for _, child := range n.Nodes() {
+		lines = describeSyntax(child, indent+"\t", lines)
+	}
+	return lines
+}
+
+func TestSyntaxStructure(t *testing.T) {
+	expected := `file : keyword p2p ; const uint8 Max = 9 ; enum Mode : uint8 { Idle = 1 , Walk , } ; struct Point { int16 / 10 x = - 4.5 ; int16 * Max ( - 100 - - 10 ) y ; Mode [] modes ; } ; dclass Base { setName ( string ( 1 - 32 ) name = "base" , uint8 ) required broadcast db ; setPath ( Point [ 0 - 8 ] path , Mode [ 2 ] mode ) p2p ram ; } ; dclass Child : Base { blob data = "a;b" ; char initial = ';' ; setAll : setName , setPath ; } ;
+	keyword p2p: keyword p2p ;
+	const Max: const uint8 Max = 9 ;
+	enum Mode: enum Mode : uint8 { Idle = 1 , Walk , } ;
+		enum value Idle: Idle = 1
+		enum value Walk: Walk
+	struct Point: struct Point { int16 / 10 x = - 4.5 ; int16 * Max ( - 100 - - 10 ) y ; Mode [] modes ; } ;
+		field x: int16 / 10 x = - 4.5 ;
+		field y: int16 * Max ( - 100 - - 10 ) y ;
+		field modes: Mode [] modes ;
+	dclass Base: dclass Base { setName ( string ( 1 - 32 ) name = "base" , uint8 ) required broadcast db ; setPath ( Point [ 0 - 8 ] path , Mode [ 2 ] mode ) p2p ram ; } ;
+		field setName: setName ( string ( 1 - 32 ) name = "base" , uint8 ) required broadcast db ;
+			parameter name: string ( 1 - 32 ) name = "base"
+			parameter : uint8
+		field setPath: setPath ( Point [ 0 - 8 ] path , Mode [ 2 ] mode ) p2p ram ;
+			parameter path: Point [ 0 - 8 ] path
+			parameter mode: Mode [ 2 ] mode
+	dclass Child: dclass Child : Base { blob data = "a;b" ; char initial = ';' ; setAll : setName , setPath ; } ;
+		field data: blob data = "a;b" ;
+		field initial: char initial = ';' ;
+		field setAll: setAll : setName , setPath ;`
+
+	tree, err := ParseSyntax(strings.NewReader(caseSyntax))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := strings.Join(describeSyntax(tree, "", nil), "\n"); out != expected {
+		t.Errorf("unexpected syntax tree:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestSyntaxTrivia(t *testing.T) {
+	tree, err := ParseSyntax(strings.NewReader("struct S {\n\tuint8 x; // x\n\n\t// y\n\tuint8 y;\n};\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := tree.Nodes()[0].Nodes()
+	if s := fields[0].String(); s != "\tuint8 x; // x\n" {
+		t.Errorf("first field is %q", s)
+	}
+	if s := fields[1].String(); s != "\n\t// y\n\tuint8 y;\n" {
+		t.Errorf("second field is %q", s)
+	}
+	leading := fields[1].Tokens()[0].Leading
+	if len(leading) != 3 || leading[1].Kind != CommentTrivia || leading[1].Text != "// y" {
+		t.Errorf("second field has leading trivia %q", leading)
+	}
+}
+
+func TestSyntaxEdit(t *testing.T) {
+	src := `// The base class.
+dclass Base {
+	setName(string name) broadcast; // the name
+};
+
+dclass Child : Base {
+	setLevel(uint8 level) ram;
+};
+`
+	expected := `// The base class.
+dclass Entity {
+	setName(string name) broadcast; // the name
+	// The hit points.
+	setHp(int16 hp) ram;
+};
+
+dclass Child : Entity {
+	setLevel(uint8 level) ram;
+};
+`
+
+	tree, err := ParseSyntax(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rename Base, and add a field to it
+	for _, tok := range tree.Tokens() {
+		if tok.Kind == IdentToken && tok.Text == "Base" {
+			tok.Text = "Entity"
+		}
+	}
+	field, err := ParseSyntaxNode(FieldSyntax, "\t// The hit points.\n\tsetHp(int16 hp) ram;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := tree.Nodes()[0]
+	end := len(base.Children) - 2 // before "}" and ";"
+	base.Children = append(base.Children[:end], append([]SyntaxElement{field}, base.Children[end:]...)...)
+
+	if out := tree.String(); out != expected {
+		t.Errorf("edited tree printed:\n%s\nexpected:\n%s", out, expected)
+	}
+	if _, err := Parse(strings.NewReader(tree.String())); err != nil {
+		t.Errorf("edited tree does not parse: %v", err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	cases := []struct {
+		kind SyntaxKind
+		src  string
+		err  string
+	}{
+		{FileSyntax, "keyword a", "parse error(line: 1): incomplete 'keyword' declaration, found EOF"},
+		{FileSyntax, "foo;", "parse error(line: 1): unexpected 'foo' in file, expected a declaration"},
+		{FileSyntax, "struct S {\n\tuint8 x\n};", "parse error(line: 3): unexpected '}' in field"},
+		{FileSyntax, "struct S {\n\tuint8 x;\n", "lex error(line: 3): unclosed right paren"},
+		{FileSyntax, "dclass C {\n\tset(uint8 x;\n};", "lex error(line: 3): unclosed left paren"},
+		{FileSyntax, "const X = 1 { };", "parse error(line: 1): unexpected '{' in 'const' declaration"},
+		{StructSyntax, "dclass C {};", "parse error(line: 1): expected a struct declaration but got dclass"},
+		{FieldSyntax, "uint8 x; uint8 y;", "parse error(line: 1): unexpected 'uint8' after field"},
+		{ParameterSyntax, "", "parse error(line: 1): incomplete parameter, found EOF"},
+	}
+	for _, c := range cases {
+		_, err := ParseSyntaxNode(c.kind, c.src)
+		if err == nil {
+			t.Errorf("ParseSyntaxNode(%v, %q) did not fail", c.kind, c.src)
+		} else if err := err.(ErrorList)[0]; err.Error() != c.err {
+			t.Errorf("ParseSyntaxNode(%v, %q): error %q, expected %q", c.kind, c.src, err, c.err)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package dclass
 
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
 // A DataType declares the type of data stored by a Parameter.
 type DataType int
 
@@ -22,6 +29,55 @@ const (
 	StructType
 )
 
+var dataTypeName = map[DataType]string{
+	InvalidType: "invalid",
+	Int8Type:    "int8",
+	Int16Type:   "int16",
+	Int32Type:   "int32",
+	Int64Type:   "int64",
+	Uint8Type:   "uint8",
+	Uint16Type:  "uint16",
+	Uint32Type:  "uint32",
+	Uint64Type:  "uint64",
+	FloatType:   "float64",
+	StringType:  "string",
+	BlobType:    "blob",
+	CharType:    "char",
+	StructType:  "struct",
+}
+
+// implements Stringer interface
+func (t DataType) String() string {
+	return dataTypeName[t]
+}
+
+// IsInteger returns whether the DataType is a signed or unsigned integer type.
+func (t DataType) IsInteger() bool {
+	return Int8Type <= t && t <= Uint64Type
+}
+
+// IsSigned returns whether the DataType is a signed integer or floating point type.
+func (t DataType) IsSigned() bool {
+	return (Int8Type <= t && t <= Int64Type) || t == FloatType
+}
+
+// Size returns the number of bytes used to pack a value of the DataType, or 0 if the
+// type is not of a fixed size.
+func (t DataType) Size() int {
+	switch t {
+	case Int8Type, Uint8Type, CharType:
+		return 1
+	case Int16Type, Uint16Type:
+		return 2
+	case Int32Type, Uint32Type:
+		return 4
+	case Int64Type, Uint64Type, FloatType:
+		return 8
+	default:
+		return 0
+	}
+}
+
 // An Error is a dclass package specific error
 type Error string
 
@@ -39,6 +95,72 @@ func runtimeError(msg string) Error {
 	return Error("runtime error: " + msg)
 }
 
+// A PosError is an error found at a position within the source of a dclass File.
+type PosError struct {
+	Pos  Pos    // position of the error in the source file
+	Kind string // kind of error, ie. "parse error" or "semantic warning"
+	Msg  string // description of the error
+
+	Suggestions []string // names similar to an unknown name, which it may be a misspelling of
+}
+
+// implements Error interface
+func (err *PosError) Error() string {
+	return fmt.Sprintf("%s(line: %d): %s", err.Kind, err.Pos.Line, err.Msg)
+}
+
+// An ErrorList is a list of errors encountered while parsing a dclass File.
+type ErrorList []error
+
+// implements Error interface
+func (list ErrorList) Error() string {
+	msg := fmt.Sprintf("encountered %d errors while parsing dclass file...\n", len(list))
+
+	// Print max 10 errors...
+	for i := 0; i < 10 && i < len(list); i++ {
+		msg += fmt.Sprintf(" - %s\n", list[i].Error())
+	}
+
+	// ... and mention how many errors were not printed.
+	if len(list) > 10 {
+		msg += fmt.Sprintf("... an extra %d errors were not printed.\n", len(list)-10)
+	}
+
+	return msg
+}
+
+// Sort sorts the list by the position of each error.  Errors without a position are
+// moved to the end of the list.
+func (list ErrorList) Sort() {
+	pos := func(err error) Pos {
+		if err, ok := err.(*PosError); ok && err.Pos.IsValid() {
+			return err.Pos
+		}
+		return Pos{math.MaxInt32, 0}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := pos(list[i]), pos(list[j])
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+}
+
+// A Pos is a line and column position within the source of a dclass File.
+type Pos struct {
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
+}
+
+// implements Stringer interface
+func (pos Pos) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// IsValid returns whether the position is known.  Types and fields which were not parsed
+// from a file have no position.
+func (pos Pos) IsValid() bool {
+	return pos.Line > 0
+}
+
 type Hashable interface {
 	Hash() uint64
 }
@@ -66,61 +188,59 @@ type KeywordList interface {
 	NumKeywords() int
 }
 
-// type keywords is a string slice satisfying the KeywordList interface.
-type keywords []string
-
-// implementing KeywordList
-func (k *keywords) AddKeyword(keyword string) {
-	if !k.HasKeyword(keyword) {
-		k = append(k, keyword)
-	}
-}
+// A Transform defines a set of operations to perform on a parameter when being unpacked.
+// The inverse set of operations is performed when packing the data.
+type Transform []TransformOp
 
-// implementing KeywordList
-func (k *keywords) AddKeywords(list KeywordList) {
-	for _, keyword := range list.Keywords() {
-		k.AddKeyword(keyword)
-	}
+// A TransformOp is a single arithmetic operation of a Transform.
+type TransformOp struct {
+	Operator byte    // one of '+', '-', '*', '/', or '%'
+	Operand  float64 // the right-hand side of the operation
 }
 
-// implementing KeywordList
-func (k *keywords) CompareKeywords(list KeywordList) bool {
-	if len(k) != len(list.Keywords()) {
-		return false
-	}
-	for _, keyword := range k {
-		if !list.HasKeyword(keyword) {
-			return false
+// apply performs the transform's operations on a packed value, returning the unpacked value.
+func (trans Transform) apply(v float64) float64 {
+	for _, op := range trans {
+		switch op.Operator {
+		case '+':
+			v += op.Operand
+		case '-':
+			v -= op.Operand
+		case '*':
+			v *= op.Operand
+		case '/':
+			v /= op.Operand
+		case '%':
+			v = math.Mod(v, op.Operand)
 		}
 	}
-	return true
+	return v
 }
 
-// implementing KeywordList
-func (k *keywords) HasKeyword(keyword string) bool {
-	for _, word := range k {
-		if keyword == word {
-			return true
+// invert performs the inverse of the transform's operations in reverse order on an unpacked
+// value, returning the value to be packed.  Modulus has no inverse, so it is applied as is.
+func (trans Transform) invert(v float64) float64 {
+	for i := len(trans) - 1; i >= 0; i-- {
+		op := trans[i]
+		switch op.Operator {
+		case '+':
+			v -= op.Operand
+		case '-':
+			v += op.Operand
+		case '*':
+			v /= op.Operand
+		case '/':
+			v *= op.Operand
+		case '%':
+			v = math.Mod(v, op.Operand)
 		}
 	}
-	return false
-}
-
-// implementing KeywordList
-func (k *keywords) Keywords() []string {
-	return []string(k)
+	return v
 }
 
-// implementing KeywordList
-func (k *keywords) NumKeywords() int {
-	return len(k)
-}
-
-// A Transform defines a set of operations to perform on a parameter when being unpacked.
-// The inverse set of operations is performed when packing the data.
-type Transform struct{} // TODO: Define
-
-// A Range defines a constraint for a particular DataType
+// A Range defines a constraint for a particular DataType.  For numeric types the range constrains
+// the packed value; for strings and blobs it constrains the length in bytes and for arrays it
+// constrains the number of elements.
 type Range interface{}
 type RangeInt8 struct {
 	Min, Max int8
@@ -155,3 +275,136 @@ type RangeLength struct {
 type RangeArray struct {
 	RangeInt16
 }
+
+// newRange returns a Range constraining values of DataType typ to the (inclusive) bounds min and max.
+// Strings and blobs return a RangeLength.
+func newRange(typ DataType, min, max *big.Rat) (Range, error) {
+	if min.Cmp(max) > 0 {
+		return nil, Error("range minimum is greater than range maximum")
+	}
+	if typ == FloatType {
+		fmin, _ := min.Float64()
+		fmax, _ := max.Float64()
+		return RangeFloat{fmin, fmax}, nil
+	}
+
+	if !min.IsInt() || !max.IsInt() {
+		return nil, Error("range of " + typ.String() + " must have integer bounds")
+	}
+	lo, hi := min.Num(), max.Num()
+	switch typ {
+	case StringType, BlobType:
+		if !fitsType(Uint16Type, lo) || !fitsType(Uint16Type, hi) || hi.Int64() > math.MaxInt16 {
+			return nil, Error("length range of " + typ.String() + " is out of bounds")
+		}
+		return RangeLength{RangeInt16{int16(lo.Int64()), int16(hi.Int64())}}, nil
+	}
+	if !typ.IsInteger() && typ != CharType {
+		return nil, Error("type " + typ.String() + " cannot have a range")
+	}
+	if !fitsType(typ, lo) || !fitsType(typ, hi) {
+		return nil, Error("range bounds do not fit in type " + typ.String())
+	}
+
+	switch typ {
+	case Int8Type:
+		return RangeInt8{int8(lo.Int64()), int8(hi.Int64())}, nil
+	case Int16Type:
+		return RangeInt16{int16(lo.Int64()), int16(hi.Int64())}, nil
+	case Int32Type:
+		return RangeInt32{int32(lo.Int64()), int32(hi.Int64())}, nil
+	case Int64Type:
+		return RangeInt64{lo.Int64(), hi.Int64()}, nil
+	case Uint8Type, CharType:
+		return RangeUint8{uint8(lo.Uint64()), uint8(hi.Uint64())}, nil
+	case Uint16Type:
+		return RangeUint16{uint16(lo.Uint64()), uint16(hi.Uint64())}, nil
+	case Uint32Type:
+		return RangeUint32{uint32(lo.Uint64()), uint32(hi.Uint64())}, nil
+	default:
+		return RangeUint64{lo.Uint64(), hi.Uint64()}, nil
+	}
+}
+
+// RangeBounds returns the inclusive minimum and maximum of a Range as rational numbers.
+// Returns false if the range is nil.
+func RangeBounds(rng Range) (min, max *big.Rat, ok bool) {
+	return rangeBounds(rng)
+}
+
+// rangeBounds returns the minimum and maximum of a Range as rational numbers.
+// Returns false if the range is nil or not a known Range type.
+func rangeBounds(rng Range) (min, max *big.Rat, ok bool) {
+	min, max = new(big.Rat), new(big.Rat)
+	switch r := rng.(type) {
+	case RangeInt8:
+		min.SetInt64(int64(r.Min))
+		max.SetInt64(int64(r.Max))
+	case RangeInt16:
+		min.SetInt64(int64(r.Min))
+		max.SetInt64(int64(r.Max))
+	case RangeInt32:
+		min.SetInt64(int64(r.Min))
+		max.SetInt64(int64(r.Max))
+	case RangeInt64:
+		min.SetInt64(r.Min)
+		max.SetInt64(r.Max)
+	case RangeUint8:
+		min.SetUint64(uint64(r.Min))
+		max.SetUint64(uint64(r.Max))
+	case RangeUint16:
+		min.SetUint64(uint64(r.Min))
+		max.SetUint64(uint64(r.Max))
+	case RangeUint32:
+		min.SetUint64(uint64(r.Min))
+		max.SetUint64(uint64(r.Max))
+	case RangeUint64:
+		min.SetUint64(r.Min)
+		max.SetUint64(r.Max)
+	case RangeFloat:
+		min.SetFloat64(r.Min)
+		max.SetFloat64(r.Max)
+	case RangeLength:
+		return rangeBounds(r.RangeInt16)
+	case RangeArray:
+		return rangeBounds(r.RangeInt16)
+	default:
+		return nil, nil, false
+	}
+	return min, max, true
+}
+
+// inRange returns whether the value v satisfies the Range.  A nil range accepts any value.
+func inRange(rng Range, v *big.Rat) bool {
+	min, max, ok := rangeBounds(rng)
+	if !ok {
+		return true
+	}
+	return min.Cmp(v) <= 0 && v.Cmp(max) <= 0
+}
+
+// fitsType returns whether the integer v can be represented by the integer DataType typ.
+func fitsType(typ DataType, v *big.Int) bool {
+	var min, max *big.Int
+	switch typ {
+	case Int8Type:
+		min, max = big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)
+	case Int16Type:
+		min, max = big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)
+	case Int32Type:
+		min, max = big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)
+	case Int64Type:
+		min, max = big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)
+	case Uint8Type, CharType:
+		min, max = new(big.Int), big.NewInt(math.MaxUint8)
+	case Uint16Type:
+		min, max = new(big.Int), big.NewInt(math.MaxUint16)
+	case Uint32Type:
+		min, max = new(big.Int), big.NewInt(math.MaxUint32)
+	case Uint64Type:
+		min, max = new(big.Int), new(big.Int).SetUint64(math.MaxUint64)
+	default:
+		return false
+	}
+	return min.Cmp(v) <= 0 && v.Cmp(max) <= 0
+}
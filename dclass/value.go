@@ -0,0 +1,466 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// A ValueKind declares the kind of data held by a Value.
+type ValueKind int
+
+const (
+	InvalidValue    ValueKind = iota
+	IntValue                  // a signed integer, including enums of a signed type
+	UintValue                 // an unsigned integer or char, including enums of an unsigned type
+	FloatValue                // a float64, or the transformed value of an integer
+	StringValue               // a string
+	BlobValue                 // a blob
+	ArrayValue                // the elements of an array
+	StructValue               // the members of a struct
+	ArgumentsValue            // the arguments of an atomic field
+	ComponentsValue           // the components of a molecular field
+)
+
+var valueKindName = map[ValueKind]string{
+	InvalidValue:    "invalid",
+	IntValue:        "int",
+	UintValue:       "uint",
+	FloatValue:      "float",
+	StringValue:     "string",
+	BlobValue:       "blob",
+	ArrayValue:      "array",
+	StructValue:     "struct",
+	ArgumentsValue:  "arguments",
+	ComponentsValue: "components",
+}
+
+// implements Stringer interface
+func (k ValueKind) String() string {
+	return valueKindName[k]
+}
+
+// A Value is a dynamically typed value of a field, for use when the dclass File is not known
+// at compile time.  UnpackValue produces a Value from packed data and PackValue consumes one.
+//
+// Arrays, structs, arguments, and components hold their nested values in Elems.  Names holds
+// the names of the members of a struct, or of the arguments or components of a field, and is
+// used to find nested values by Path; it may be nil for values which are only packed.
+type Value struct {
+	Kind  ValueKind
+	Int   int64    // value of an IntValue
+	Uint  uint64   // value of a UintValue
+	Float float64  // value of a FloatValue
+	Str   string   // value of a StringValue
+	Blob  []byte   // value of a BlobValue
+	Elems []*Value // nested values of an ArrayValue, StructValue, ArgumentsValue, or ComponentsValue
+	Names []string // names of the nested values of a StructValue, ArgumentsValue, or ComponentsValue
+}
+
+// UnpackValue unpacks the packed value of the field in data.  The data must contain exactly one
+// value of the field.
+func UnpackValue(f Field, data []byte) (*Value, error) {
+	buf := bytes.NewBuffer(data)
+	v, err := unpackFieldValue(f, buf)
+	if err != nil {
+		return nil, err
+	}
+	if buf.Len() > 0 {
+		return nil, Error("unexpected data after value of field '" + f.Name() + "'")
+	}
+	return v, nil
+}
+
+// unpackFieldValue reads a packed value of any field from data.
+func unpackFieldValue(f Field, data *bytes.Buffer) (*Value, error) {
+	if p, ok := f.(*Parameter); ok {
+		return unpackParameterValue(p, data)
+	}
+
+	v := &Value{Kind: ArgumentsValue}
+	if _, ok := f.(*MolecularField); ok {
+		v.Kind = ComponentsValue
+	}
+	for _, nested := range f.NestedFields() {
+		elem, err := unpackFieldValue(nested, data)
+		if err != nil {
+			return nil, err
+		}
+		v.Elems = append(v.Elems, elem)
+		v.Names = append(v.Names, nested.Name())
+	}
+	return v, nil
+}
+
+// unpackParameterValue reads a packed value of the parameter, which may be an array, from data.
+func unpackParameterValue(p *Parameter, data *bytes.Buffer) (*Value, error) {
+	if !p.isArray {
+		return unpackElementValue(p, data)
+	}
+
+	elems := data
+	if p.arraySize == 0 {
+		n, err := unpackLength(data)
+		if err != nil {
+			return nil, err
+		}
+		elems = bytes.NewBuffer(data.Next(n))
+	}
+
+	v := &Value{Kind: ArrayValue}
+	for i := 0; (p.arraySize == 0 && elems.Len() > 0) || i < p.arraySize; i++ {
+		elem, err := unpackElementValue(p, elems)
+		if err != nil {
+			return nil, err
+		}
+		v.Elems = append(v.Elems, elem)
+	}
+	return v, nil
+}
+
+// unpackElementValue reads a single packed element of the parameter from data.
+func unpackElementValue(p *Parameter, data *bytes.Buffer) (*Value, error) {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return nil, Error("struct " + p.typeName + " is not defined")
+		}
+		v := &Value{Kind: StructValue}
+		for _, f := range p.structType.fields {
+			elem, err := unpackParameterValue(f.(*Parameter), data)
+			if err != nil {
+				return nil, err
+			}
+			v.Elems = append(v.Elems, elem)
+			v.Names = append(v.Names, f.Name())
+		}
+		return v, nil
+	case StringType, BlobType:
+		n, err := unpackLength(data)
+		if err != nil {
+			return nil, err
+		}
+		if p.dataType == StringType {
+			return &Value{Kind: StringValue, Str: string(data.Next(n))}, nil
+		}
+		return &Value{Kind: BlobValue, Blob: append([]byte(nil), data.Next(n)...)}, nil
+	case FloatType:
+		if data.Len() < 8 {
+			return nil, Error("unexpected end of data reading float64")
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(data.Next(8)))
+		return &Value{Kind: FloatValue, Float: p.Transform.apply(f)}, nil
+	}
+
+	if !p.dataType.IsInteger() && p.dataType != CharType {
+		return nil, Error("cannot unpack parameter '" + p.name + "' of type " + p.TypeName())
+	}
+	n, err := unpackInt(p.dataType, data)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(p.Transform) > 0:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return &Value{Kind: FloatValue, Float: p.Transform.apply(f)}, nil
+	case p.dataType.IsSigned():
+		return &Value{Kind: IntValue, Int: n.Int64()}, nil
+	default:
+		return &Value{Kind: UintValue, Uint: n.Uint64()}, nil
+	}
+}
+
+// PackValue packs the Value of the field.  Numbers are checked against the type, range, and
+// enum of each parameter, and arrays against the parameter's array size or range.
+func PackValue(f Field, v *Value) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := packFieldValue(f, v, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// packFieldValue packs the Value of any field into buf.
+func packFieldValue(f Field, v *Value, buf *bytes.Buffer) error {
+	if p, ok := f.(*Parameter); ok {
+		return packParameterValue(p, v, buf)
+	}
+
+	kind := ArgumentsValue
+	if _, ok := f.(*MolecularField); ok {
+		kind = ComponentsValue
+	}
+	nested := f.NestedFields()
+	if err := checkValue(v, kind, len(nested), f.Name()); err != nil {
+		return err
+	}
+	for i, field := range nested {
+		if err := packFieldValue(field, v.Elems[i], buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packParameterValue packs the Value of the parameter, which may be an array, into buf.
+func packParameterValue(p *Parameter, v *Value, buf *bytes.Buffer) error {
+	if !p.isArray {
+		return packElementValue(p, v, buf)
+	}
+
+	if v == nil || v.Kind != ArrayValue {
+		return valueKindError(v, ArrayValue, p.name)
+	}
+	count := len(v.Elems)
+	if p.arraySize > 0 {
+		if count != p.arraySize {
+			return Error("array value of parameter '" + p.name + "' has " + strconv.Itoa(count) +
+				" elements, expected " + strconv.Itoa(p.arraySize))
+		}
+		for _, elem := range v.Elems {
+			if err := packElementValue(p, elem, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !inRange(p.ArrayRange, big.NewRat(int64(count), 1)) {
+		return Error("array value has " + strconv.Itoa(count) + " elements, outside of the range of parameter '" +
+			p.name + "'")
+	}
+	var elems bytes.Buffer
+	for _, elem := range v.Elems {
+		if err := packElementValue(p, elem, &elems); err != nil {
+			return err
+		}
+	}
+	if err := packLength(elems.Len(), buf); err != nil {
+		return err
+	}
+	buf.Write(elems.Bytes())
+	return nil
+}
+
+// packElementValue packs the Value of a single element of the parameter into buf.
+func packElementValue(p *Parameter, v *Value, buf *bytes.Buffer) error {
+	switch p.dataType {
+	case StructType:
+		if p.structType == nil {
+			return Error("struct " + p.typeName + " is not defined")
+		}
+		if err := checkValue(v, StructValue, len(p.structType.fields), p.name); err != nil {
+			return err
+		}
+		for i, f := range p.structType.fields {
+			if err := packParameterValue(f.(*Parameter), v.Elems[i], buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	case StringType, BlobType:
+		switch {
+		case v != nil && v.Kind == StringValue:
+			return packString(p, v.Str, buf)
+		case v != nil && v.Kind == BlobValue:
+			return packString(p, string(v.Blob), buf)
+		}
+		return valueKindError(v, StringValue, p.name)
+	}
+
+	if v == nil {
+		return valueKindError(v, IntValue, p.name)
+	}
+	switch v.Kind {
+	case IntValue:
+		return packNumber(p, new(big.Rat).SetInt64(v.Int), buf)
+	case UintValue:
+		return packNumber(p, new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint)), buf)
+	case FloatValue:
+		if math.IsInf(v.Float, 0) || math.IsNaN(v.Float) {
+			return packNonFinite(p, v.Float, buf)
+		}
+		return packNumber(p, new(big.Rat).SetFloat64(v.Float), buf)
+	}
+	return valueKindError(v, IntValue, p.name)
+}
+
+// checkValue checks that a Value of a struct, atomic field, or molecular field has the
+// expected kind and number of nested values.
+func checkValue(v *Value, kind ValueKind, n int, name string) error {
+	if v == nil || v.Kind != kind {
+		return valueKindError(v, kind, name)
+	}
+	if len(v.Elems) != n {
+		return Error(kind.String() + " value of '" + name + "' has " + strconv.Itoa(len(v.Elems)) +
+			" values, expected " + strconv.Itoa(n))
+	}
+	return nil
+}
+
+// valueKindError creates an error for a Value which has the wrong kind for a field.
+func valueKindError(v *Value, expected ValueKind, name string) Error {
+	kind := InvalidValue
+	if v != nil {
+		kind = v.Kind
+	}
+	return Error("cannot pack " + kind.String() + " value into '" + name + "', expected " + expected.String())
+}
+
+// Path returns the nested value found by following a path of names and indexes, for example
+// "inventory[3].count".  Names select the members of a struct or the arguments or components of
+// a field, and indexes select the elements of an array or any other nested value by position.
+func (v *Value) Path(path string) (*Value, error) {
+	cur := v
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[':
+			end := i + 1
+			for end < len(path) && path[end] != ']' {
+				end++
+			}
+			if end == len(path) {
+				return nil, Error("missing ']' in path '" + path + "'")
+			}
+			index, err := strconv.Atoi(path[i+1 : end])
+			if err != nil || index < 0 {
+				return nil, Error("invalid index '" + path[i+1:end] + "' in path '" + path + "'")
+			}
+			if index >= len(cur.Elems) {
+				return nil, Error("index " + strconv.Itoa(index) + " in path '" + path + "' is out of range, " +
+					cur.Kind.String() + " value has " + strconv.Itoa(len(cur.Elems)) + " values")
+			}
+			cur = cur.Elems[index]
+			i = end + 1
+		case path[i] == '.' && i > 0:
+			if i+1 == len(path) || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, Error("expected a name after '.' at offset " + strconv.Itoa(i) + " of path '" + path + "'")
+			}
+			i++
+		default:
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			name := path[i:end]
+			if name == "" {
+				return nil, Error("expected a name at offset " + strconv.Itoa(i) + " of path '" + path + "'")
+			}
+
+			next := -1
+			for j, n := range cur.Names {
+				if n == name && j < len(cur.Elems) {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				return nil, Error(cur.Kind.String() + " value has no member '" + name + "' in path '" + path + "'")
+			}
+			cur = cur.Elems[next]
+			i = end
+		}
+	}
+	return cur, nil
+}
+
+// Equal returns whether two values are of the same kind and hold the same data.  The Names of
+// nested values are not compared.
+func (v *Value) Equal(o *Value) bool {
+	if v == nil || o == nil {
+		return v == o
+	}
+	if v.Kind != o.Kind {
+		return false
+	}
+
+	switch v.Kind {
+	case IntValue:
+		return v.Int == o.Int
+	case UintValue:
+		return v.Uint == o.Uint
+	case FloatValue:
+		return v.Float == o.Float || (math.IsNaN(v.Float) && math.IsNaN(o.Float))
+	case StringValue:
+		return v.Str == o.Str
+	case BlobValue:
+		return bytes.Equal(v.Blob, o.Blob)
+	}
+
+	if len(v.Elems) != len(o.Elems) {
+		return false
+	}
+	for i := range v.Elems {
+		if !v.Elems[i].Equal(o.Elems[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns a deep copy of the value, which can be modified without changing the original.
+func (v *Value) Copy() *Value {
+	if v == nil {
+		return nil
+	}
+
+	c := *v
+	if v.Blob != nil {
+		c.Blob = append([]byte(nil), v.Blob...)
+	}
+	if v.Names != nil {
+		c.Names = append([]string(nil), v.Names...)
+	}
+	if v.Elems != nil {
+		c.Elems = make([]*Value, len(v.Elems))
+		for i, elem := range v.Elems {
+			c.Elems[i] = elem.Copy()
+		}
+	}
+	return &c
+}
+
+// String formats the value like FormatData, without enum names: arrays and structs are written
+// in curly braces `{1, 2}` and the arguments or components of a field in parens `(1, 2)`.
+func (v *Value) String() string {
+	var out bytes.Buffer
+	v.format(&out)
+	return out.String()
+}
+
+func (v *Value) format(out *bytes.Buffer) {
+	if v == nil {
+		out.WriteString("<nil>")
+		return
+	}
+
+	switch v.Kind {
+	case IntValue:
+		out.WriteString(strconv.FormatInt(v.Int, 10))
+	case UintValue:
+		out.WriteString(strconv.FormatUint(v.Uint, 10))
+	case FloatValue:
+		out.WriteString(strconv.FormatFloat(v.Float, 'g', -1, 64))
+	case StringValue:
+		out.WriteString(strconv.Quote(v.Str))
+	case BlobValue:
+		out.WriteString(strconv.Quote(string(v.Blob)))
+	case ArrayValue, StructValue, ArgumentsValue, ComponentsValue:
+		open, close := byte('{'), byte('}')
+		if v.Kind == ArgumentsValue || v.Kind == ComponentsValue {
+			open, close = '(', ')'
+		}
+		out.WriteByte(open)
+		for i, elem := range v.Elems {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			elem.format(out)
+		}
+		out.WriteByte(close)
+	default:
+		out.WriteString("<" + v.Kind.String() + ">")
+	}
+}
@@ -0,0 +1,149 @@
+package dclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+const caseValue = `
+enum Kind : int8 {
+	Sword = -1,
+	Shield = 1,
+};
+struct Item {
+	Kind kind;
+	uint16 count;
+	string name;
+};
+dclass Avatar {
+	setInventory(Item[] inventory, char[2] tag, int32 / 100 gold, blob);
+	setLevel(uint8 level);
+	setState : setInventory, setLevel;
+};
+`
+
+func TestValue(t *testing.T) {
+	dcf := mustParse(t, caseValue)
+	avatar := dcf.ClassByName["Avatar"].(*Class)
+	state := avatar.FieldByName("setState")
+
+	text := `(({{Sword, 1, "a"}, {Shield, 2, "b"}, {Sword, 3, "c"}, {Shield, 4, "d"}}, {'x', 'y'}, 12.5, "ab"), (7))`
+	data, err := state.ParseString(text)
+	if err != nil {
+		t.Fatalf("unexpected error parsing value: %s", err)
+	}
+
+	v, err := UnpackValue(state, data.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error unpacking value: %s", err)
+	}
+	expected := `(({{-1, 1, "a"}, {1, 2, "b"}, {-1, 3, "c"}, {1, 4, "d"}}, {120, 121}, 12.5, "ab"), (7))`
+	if v.String() != expected {
+		t.Errorf("unpacked value %s, expected %s", v, expected)
+	}
+
+	paths := []struct {
+		path     string
+		expected string
+	}{
+		{"setInventory.inventory[3].count", "4"},
+		{"setInventory.inventory[1]", `{1, 2, "b"}`},
+		{"setInventory.tag[1]", "121"},
+		{"setInventory.gold", "12.5"},
+		{"setInventory[3]", `"ab"`},
+		{"[1].level", "7"},
+		{"setInventory.inventory[0].kind", "-1"},
+	}
+	for _, test := range paths {
+		nested, err := v.Path(test.path)
+		if err != nil {
+			t.Errorf("unexpected error finding path %s: %s", test.path, err)
+		} else if nested.String() != test.expected {
+			t.Errorf("found %s at path %s, expected %s", nested, test.path, test.expected)
+		}
+	}
+	for _, path := range []string{"setInventory.inventory[4]", "setInventory.missing", "setInventory.inventory[x]",
+		"setInventory..gold", "setLevel.level[0"} {
+		if _, err := v.Path(path); err == nil {
+			t.Errorf("expected error finding path %s", path)
+		}
+	}
+
+	// a modified copy packs to different data, and the original is unchanged
+	c := v.Copy()
+	if !c.Equal(v) {
+		t.Errorf("copy %s is not equal to the original %s", c, v)
+	}
+	count, _ := c.Path("setInventory.inventory[3].count")
+	count.Uint = 40
+	if c.Equal(v) {
+		t.Errorf("modified copy %s is equal to the original", c)
+	}
+
+	packed, err := PackValue(state, v)
+	if err != nil {
+		t.Fatalf("unexpected error packing value: %s", err)
+	}
+	if !bytes.Equal(packed, data.Bytes()) {
+		t.Errorf("packed value %s, expected %s", state.FormatData(*bytes.NewBuffer(packed), false), text)
+	}
+	packed, err = PackValue(state, c)
+	if err != nil {
+		t.Fatalf("unexpected error packing modified value: %s", err)
+	}
+	if s := state.FormatData(*bytes.NewBuffer(packed), false); !strings.Contains(s, `{Shield, 40, "d"}`) {
+		t.Errorf("packed modified value %s, expected count 40", s)
+	}
+
+	// values constructed by hand do not need names, and are checked when packed
+	level := avatar.FieldByName("setLevel")
+	errors := []struct {
+		value    *Value
+		expected string
+	}{
+		{&Value{Kind: ArgumentsValue, Elems: []*Value{{Kind: IntValue, Int: 300}}}, "overflows uint8"},
+		{&Value{Kind: ArgumentsValue, Elems: []*Value{{Kind: StringValue, Str: "1"}}}, "cannot pack string value"},
+		{&Value{Kind: ArgumentsValue}, "has 0 values, expected 1"},
+		{&Value{Kind: StructValue}, "expected arguments"},
+	}
+	for _, test := range errors {
+		if _, err := PackValue(level, test.value); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("unexpected error packing %s: %v, expected %s", test.value, err, test.expected)
+		}
+	}
+	if packed, err := PackValue(level, &Value{Kind: ArgumentsValue, Elems: []*Value{{Kind: FloatValue, Float: 9}}}); err != nil {
+		t.Errorf("unexpected error packing whole float: %s", err)
+	} else if !bytes.Equal(packed, []byte{9}) {
+		t.Errorf("packed whole float as %v, expected [9]", packed)
+	}
+}
+
+func TestValueNonFinite(t *testing.T) {
+	dcf := mustParse(t, "dclass A { setF(float64 a, float64 b, int32 / 10 c); };")
+	setF := dcf.ClassByName["A"].(*Class).FieldByName("setF")
+
+	// infinities and NaN round trip through float64 parameters
+	data := make([]byte, 20)
+	binary.LittleEndian.PutUint64(data[0:], math.Float64bits(math.NaN()))
+	binary.LittleEndian.PutUint64(data[8:], math.Float64bits(math.Inf(1)))
+	v, err := UnpackValue(setF, data)
+	if err != nil {
+		t.Fatalf("unexpected error unpacking value: %s", err)
+	}
+	packed, err := PackValue(setF, v)
+	if err != nil {
+		t.Fatalf("unexpected error packing value %s: %s", v, err)
+	}
+	if !bytes.Equal(packed, data) {
+		t.Errorf("packed value %s as %v, expected %v", v, packed, data)
+	}
+
+	// but cannot be packed into integers
+	v.Elems[2] = &Value{Kind: FloatValue, Float: math.Inf(-1)}
+	if _, err := PackValue(setF, v); err == nil || !strings.Contains(err.Error(), "is not a finite number") {
+		t.Errorf("unexpected error packing -Inf into an integer: %v", err)
+	}
+}